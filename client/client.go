@@ -0,0 +1,257 @@
+// Package client is a Go client for nebula. It fetches the ring from a
+// coordinator and sends reads straight to the store that holds a key,
+// saving the hop through the coordinator. Reads the stores cannot serve,
+// because they are down or no longer own the key, go through the
+// coordinator. Writes always go through the coordinator, which replicates
+// them, keeps hints for replicas that are down and audits them.
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// ErrNotFound is returned by Get for keys that do not exist
+var ErrNotFound = errors.New("key not found")
+
+const (
+	ringFetchTimeout   = 5 * time.Second
+	watchRetryInterval = time.Second
+)
+
+// Client sends requests to a nebula cluster, it is safe for concurrent use
+type Client struct {
+	conn        *grpc.ClientConn
+	coordinator pb_coordinator.CoordinatorAPIClient
+	dialOpts    []grpc.DialOption
+
+	mu     sync.RWMutex
+	ring   *ring
+	stores map[string]*grpc.ClientConn
+
+	refresh chan struct{}
+	cancel  context.CancelFunc
+	done    sync.WaitGroup
+}
+
+// New connects to the coordinator at address, fetches its ring and keeps
+// following it. opts are used to dial the coordinator and the stores, they
+// must set the transport credentials, Insecure() for plaintext connections.
+func New(address string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.Dial(address, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn:        conn,
+		coordinator: pb_coordinator.NewCoordinatorAPIClient(conn),
+		dialOpts:    opts,
+		stores:      make(map[string]*grpc.ClientConn),
+		refresh:     make(chan struct{}, 1),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ringFetchTimeout)
+	res, err := c.coordinator.GetRing(ctx, &pb_coordinator.GetRingRequest{})
+	cancel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.setRing(res)
+
+	ctx, c.cancel = context.WithCancel(context.Background())
+	c.done.Add(2)
+	go c.watch(ctx)
+	go c.refresher(ctx)
+
+	return c, nil
+}
+
+// Insecure is the dial option of plaintext connections, for clusters on a
+// trusted network that do not serve TLS
+func Insecure() grpc.DialOption {
+	return grpc.WithTransportCredentials(insecure.NewCredentials())
+}
+
+// Close stops following the ring and closes all connections
+func (c *Client) Close() error {
+	c.cancel()
+	c.done.Wait()
+
+	c.mu.Lock()
+	for _, conn := range c.stores {
+		conn.Close()
+	}
+	c.stores = nil
+	c.mu.Unlock()
+
+	return c.conn.Close()
+}
+
+// Get returns the value of key, or ErrNotFound if it does not exist
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	// reads that need more than one replica are left to the coordinator
+	if r := c.route(key); r != nil && r.readQuorum == 1 {
+		res, err := r.stores[0].Get(ctx, &pb_store.GetRequest{Key: key, RingVersion: r.version})
+		if err == nil {
+			switch res.Status {
+			case pb_store.StatusType_OK:
+				return res.Value, nil
+			case pb_store.StatusType_CACHE_MISS:
+				return "", ErrNotFound
+			case pb_store.StatusType_WRONG_OWNER:
+				c.refreshRing()
+			}
+		}
+	}
+
+	res, err := c.coordinator.Get(ctx, &pb_coordinator.GetRequest{Key: key})
+	if err != nil {
+		return "", err
+	}
+	if res.Status == pb_coordinator.StatusType_CACHE_MISS {
+		return "", ErrNotFound
+	}
+	return res.Value, nil
+}
+
+// Put sets the value of key
+func (c *Client) Put(ctx context.Context, key string, value string) error {
+	_, err := c.coordinator.Put(ctx, &pb_coordinator.PutRequest{Key: key, Value: value})
+	return err
+}
+
+// Delete deletes key, deleting a key that does not exist is not an error
+func (c *Client) Delete(ctx context.Context, key string) error {
+	_, err := c.coordinator.Delete(ctx, &pb_coordinator.DeleteRequest{Key: key})
+	return err
+}
+
+// route is where the reads of a key go
+type route struct {
+	version    uint64
+	readQuorum int
+	stores     []pb_store.KeyValueStoreClient
+}
+
+// returns the route of key, nil when the ring has no stores
+func (c *Client) route(key string) *route {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// stores only take client requests that carry a ring version
+	if c.ring == nil || c.ring.version == 0 {
+		return nil
+	}
+
+	addresses := c.ring.replicasOf(key)
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	r := &route{
+		version:    c.ring.version,
+		readQuorum: c.ring.readQuorum,
+	}
+	for _, address := range addresses {
+		conn, ok := c.stores[address]
+		if !ok {
+			return nil
+		}
+		r.stores = append(r.stores, pb_store.NewKeyValueStoreClient(conn))
+	}
+	return r
+}
+
+// asks for the ring to be fetched again, after a store said it does not own a key
+func (c *Client) refreshRing() {
+	select {
+	case c.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// fetches the ring whenever a refresh is asked for
+func (c *Client) refresher(ctx context.Context) {
+	defer c.done.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.refresh:
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, ringFetchTimeout)
+		res, err := c.coordinator.GetRing(fetchCtx, &pb_coordinator.GetRingRequest{})
+		cancel()
+		if err == nil {
+			c.setRing(res)
+		}
+	}
+}
+
+// follows changes of the ring until ctx is done
+func (c *Client) watch(ctx context.Context) {
+	defer c.done.Done()
+
+	for ctx.Err() == nil {
+		c.mu.RLock()
+		version := c.ring.version
+		c.mu.RUnlock()
+
+		res, err := c.coordinator.WatchRing(ctx, &pb_coordinator.WatchRingRequest{Version: version})
+		if err != nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(watchRetryInterval):
+			}
+			continue
+		}
+		c.setRing(res)
+	}
+}
+
+// replaces the ring, connecting to new stores and closing the connections
+// to stores that left
+func (c *Client) setRing(res *pb_coordinator.GetRingResponse) {
+	r := newRing(res)
+	addresses := r.addresses()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stores == nil {
+		// the client is closed
+		return
+	}
+
+	for address := range addresses {
+		if _, ok := c.stores[address]; ok {
+			continue
+		}
+		conn, err := grpc.Dial(address, c.dialOpts...)
+		if err != nil {
+			// requests for the store's keys go through the coordinator
+			continue
+		}
+		c.stores[address] = conn
+	}
+
+	for address, conn := range c.stores {
+		if !addresses[address] {
+			conn.Close()
+			delete(c.stores, address)
+		}
+	}
+
+	c.ring = r
+}
@@ -0,0 +1,276 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+	"google.golang.org/grpc"
+)
+
+// coordinator serves a ring that tests may change and counts the
+// requests it is sent
+type coordinator struct {
+	pb_coordinator.UnimplementedCoordinatorAPIServer
+
+	mu      sync.Mutex
+	ring    *pb_coordinator.GetRingResponse
+	changed chan struct{}
+
+	rings, gets, puts, deletes atomic.Int32
+}
+
+func (c *coordinator) setRing(ring *pb_coordinator.GetRingResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ring = ring
+	if c.changed != nil {
+		close(c.changed)
+	}
+	c.changed = make(chan struct{})
+}
+
+func (c *coordinator) GetRing(ctx context.Context, in *pb_coordinator.GetRingRequest) (*pb_coordinator.GetRingResponse, error) {
+	c.rings.Add(1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ring, nil
+}
+
+func (c *coordinator) WatchRing(ctx context.Context, in *pb_coordinator.WatchRingRequest) (*pb_coordinator.GetRingResponse, error) {
+	for {
+		c.mu.Lock()
+		ring, changed := c.ring, c.changed
+		c.mu.Unlock()
+
+		if ring.Version != in.Version {
+			return ring, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (c *coordinator) Get(ctx context.Context, in *pb_coordinator.GetRequest) (*pb_coordinator.GetResponse, error) {
+	c.gets.Add(1)
+	return &pb_coordinator.GetResponse{Status: pb_coordinator.StatusType_OK, Value: "from coordinator"}, nil
+}
+
+func (c *coordinator) Put(ctx context.Context, in *pb_coordinator.PutRequest) (*pb_coordinator.PutResponse, error) {
+	c.puts.Add(1)
+	return &pb_coordinator.PutResponse{Status: pb_coordinator.StatusType_OK}, nil
+}
+
+func (c *coordinator) Delete(ctx context.Context, in *pb_coordinator.DeleteRequest) (*pb_coordinator.DeleteResponse, error) {
+	c.deletes.Add(1)
+	return &pb_coordinator.DeleteResponse{Status: pb_coordinator.StatusType_OK}, nil
+}
+
+// store answers every read with status and counts the reads it is sent,
+// writes are left unimplemented so that they fail if a client sends any
+type store struct {
+	pb_store.UnimplementedKeyValueStoreServer
+	status pb_store.StatusType
+	gets   atomic.Int32
+}
+
+func (s *store) Get(ctx context.Context, in *pb_store.GetRequest) (*pb_store.GetResponse, error) {
+	s.gets.Add(1)
+	if s.status == pb_store.StatusType_OK {
+		return &pb_store.GetResponse{Status: s.status, Value: "from store"}, nil
+	}
+	return &pb_store.GetResponse{Status: s.status}, nil
+}
+
+// serves the services registered by register and returns their address
+func serve(t *testing.T, register func(*grpc.Server)) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	register(server)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	return lis.Addr().String()
+}
+
+// returns a ring of version whose stores are at addresses
+func ringOf(version uint64, readQuorum uint32, addresses ...string) *pb_coordinator.GetRingResponse {
+	ring := &pb_coordinator.GetRingResponse{Version: version, Replicas: 1, ReadQuorum: readQuorum, WriteQuorum: 1}
+	for i, address := range addresses {
+		ring.Stores = append(ring.Stores, &pb_coordinator.RingStore{Name: address, Address: address, Positions: []uint64{uint64(i) << 62}})
+	}
+	return ring
+}
+
+// returns a client of a coordinator whose ring of version holds only s
+func newClient(t *testing.T, s *store, version uint64, readQuorum uint32) (*Client, *coordinator) {
+	t.Helper()
+
+	storeAddress := serve(t, func(server *grpc.Server) { pb_store.RegisterKeyValueStoreServer(server, s) })
+	coord := &coordinator{}
+	coord.setRing(ringOf(version, readQuorum, storeAddress))
+	address := serve(t, func(server *grpc.Server) { pb_coordinator.RegisterCoordinatorAPIServer(server, coord) })
+
+	c, err := New(address, Insecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, coord
+}
+
+// waits until cond holds or a second passed
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      pb_store.StatusType
+		version     uint64
+		readQuorum  uint32
+		want        string
+		wantErr     error
+		storeGets   int32
+		coordGets   int32
+		refreshRing bool
+	}{
+		{
+			name:       "from the owning store",
+			status:     pb_store.StatusType_OK,
+			version:    1,
+			readQuorum: 1,
+			want:       "from store",
+			storeGets:  1,
+		},
+		{
+			name:       "missing key",
+			status:     pb_store.StatusType_CACHE_MISS,
+			version:    1,
+			readQuorum: 1,
+			wantErr:    ErrNotFound,
+			storeGets:  1,
+		},
+		{
+			name:        "wrong owner falls back to the coordinator",
+			status:      pb_store.StatusType_WRONG_OWNER,
+			version:     1,
+			readQuorum:  1,
+			want:        "from coordinator",
+			storeGets:   1,
+			coordGets:   1,
+			refreshRing: true,
+		},
+		{
+			name:       "read quorum of several replicas",
+			status:     pb_store.StatusType_OK,
+			version:    1,
+			readQuorum: 2,
+			want:       "from coordinator",
+			coordGets:  1,
+		},
+		{
+			name:       "ring without a version",
+			status:     pb_store.StatusType_OK,
+			readQuorum: 1,
+			want:       "from coordinator",
+			coordGets:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &store{status: tt.status}
+			c, coord := newClient(t, s, tt.version, tt.readQuorum)
+
+			got, err := c.Get(context.Background(), "key")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get() = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Get() = %q, want %q", got, tt.want)
+			}
+			if n := s.gets.Load(); n != tt.storeGets {
+				t.Errorf("the store got %d reads, want %d", n, tt.storeGets)
+			}
+			if n := coord.gets.Load(); n != tt.coordGets {
+				t.Errorf("the coordinator got %d reads, want %d", n, tt.coordGets)
+			}
+			if tt.refreshRing {
+				eventually(t, "the ring to be fetched again", func() bool { return coord.rings.Load() == 2 })
+			}
+		})
+	}
+}
+
+func TestWrites(t *testing.T) {
+	s := &store{status: pb_store.StatusType_OK}
+	c, coord := newClient(t, s, 1, 1)
+
+	if err := c.Put(context.Background(), "key", "value"); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	if err := c.Delete(context.Background(), "key"); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+
+	if puts, deletes := coord.puts.Load(), coord.deletes.Load(); puts != 1 || deletes != 1 {
+		t.Errorf("the coordinator got %d puts and %d deletes, want 1 of each", puts, deletes)
+	}
+}
+
+func TestWatchRing(t *testing.T) {
+	old := &store{status: pb_store.StatusType_OK}
+	c, coord := newClient(t, old, 1, 1)
+
+	moved := &store{status: pb_store.StatusType_OK}
+	address := serve(t, func(server *grpc.Server) { pb_store.RegisterKeyValueStoreServer(server, moved) })
+	coord.setRing(ringOf(2, 1, address))
+
+	eventually(t, "the client to follow the ring", func() bool {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		return c.ring.version == 2
+	})
+
+	if _, err := c.Get(context.Background(), "key"); err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if n := moved.gets.Load(); n != 1 {
+		t.Errorf("the store of the new ring got %d reads, want 1", n)
+	}
+	if n := old.gets.Load(); n != 0 {
+		t.Errorf("the store that left the ring got %d reads, want 0", n)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.stores) != 1 {
+		t.Errorf("the client holds %d store connections, want 1", len(c.stores))
+	}
+}
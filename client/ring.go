@@ -0,0 +1,79 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+)
+
+// ring is the client's copy of the coordinator's ring
+type ring struct {
+	version    uint64
+	replicas   int
+	readQuorum int
+
+	positions []uint64
+	owners    map[uint64]string
+}
+
+func newRing(res *pb_coordinator.GetRingResponse) *ring {
+	r := &ring{
+		version:    res.Version,
+		replicas:   int(res.Replicas),
+		readQuorum: int(res.ReadQuorum),
+		owners:     make(map[uint64]string),
+	}
+
+	for _, s := range res.Stores {
+		for _, p := range s.Positions {
+			r.positions = append(r.positions, p)
+			r.owners[p] = s.Address
+		}
+	}
+	sort.Slice(r.positions, func(i, j int) bool {
+		return r.positions[i] < r.positions[j]
+	})
+
+	return r
+}
+
+// returns the addresses of the replicas of key, in order of preference
+// they are the first distinct stores clockwise from the key, like the coordinator picks them
+func (r *ring) replicasOf(key string) []string {
+	if len(r.positions) == 0 {
+		return nil
+	}
+
+	hash := hashKey(key)
+	index := sort.Search(len(r.positions), func(i int) bool {
+		return r.positions[i] >= hash
+	})
+
+	stores := make([]string, 0, r.replicas)
+	seen := make(map[string]bool)
+	for i := 0; i < len(r.positions) && len(stores) < r.replicas; i++ {
+		address := r.owners[r.positions[(index+i)%len(r.positions)]]
+		if !seen[address] {
+			seen[address] = true
+			stores = append(stores, address)
+		}
+	}
+	return stores
+}
+
+// returns the addresses of all stores of the ring
+func (r *ring) addresses() map[string]bool {
+	addresses := make(map[string]bool)
+	for _, address := range r.owners {
+		addresses[address] = true
+	}
+	return addresses
+}
+
+// hashKey hashes a key to its position on the ring, like the coordinator does
+func hashKey(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -3,25 +3,62 @@ package main
 import (
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	pb "github.com/priyansh32/nebula/internal/api/coordinator"
+	"github.com/priyansh32/nebula/internal/auth"
+	"github.com/priyansh32/nebula/internal/tlsconfig"
+	"github.com/priyansh32/nebula/internal/tracing"
+	"go.opentelemetry.io/otel"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
+var tracer = otel.Tracer("github.com/priyansh32/nebula/cmd/cli")
+
+// flushes the spans of the session, set once tracing is started
+var stopTracing func(context.Context) error
+
 func main() {
 
-	if len(os.Args) != 2 {
-		fmt.Println("Usage: cli <coordinator-address>")
+	cfg := tracing.DefaultConfig()
+	cfg.ServiceName = "nebula-cli"
+	flag.StringVar(&cfg.Exporter, "trace-exporter", "", "where spans are exported to: otlp, stdout or file, tracing is disabled if empty")
+	flag.StringVar(&cfg.Endpoint, "trace-endpoint", "", "OTLP collector address for the otlp exporter or the file spans are written to for the file exporter")
+	flag.Float64Var(&cfg.SampleRatio, "trace-sample-ratio", cfg.SampleRatio, "share of commands that start a trace")
+	var tlsCfg tlsconfig.Config
+	flag.StringVar(&tlsCfg.CAFile, "tls-ca", "", "PEM certificates of the CAs the certificate of the coordinator is verified with, TLS is disabled without it or -tls-cert")
+	flag.StringVar(&tlsCfg.CertFile, "tls-cert", "", "PEM certificate presented to a coordinator that verifies clients")
+	flag.StringVar(&tlsCfg.KeyFile, "tls-key", "", "PEM key of -tls-cert")
+	flag.StringVar(&tlsCfg.ServerName, "tls-server-name", "", "name the certificate of the coordinator is verified for (default the host dialed)")
+	token := flag.String("token", os.Getenv("NEBULA_TOKEN"), "bearer token sent with every command (default $NEBULA_TOKEN)")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Println("Usage: cli [flags] <coordinator-address>")
 		return
 	}
 
-	address := os.Args[1]
+	address := flag.Arg(0)
+
+	var err error
+	stopTracing, err = tracing.Start(cfg)
+	if err != nil {
+		fmt.Println("Error starting tracing: ", err.Error())
+		os.Exit(1)
+	}
+
+	creds, err := tlsconfig.Load(tlsCfg)
+	if err != nil {
+		fmt.Println("Error loading TLS certificates: ", err.Error())
+		os.Exit(1)
+	}
 
-	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial(address, creds.DialOption(), auth.TokenDialOption(*token), tracing.DialOption())
 	if err != nil {
 		fmt.Println("Error connecting to coordinator: ", err.Error())
 		os.Exit(1)
@@ -36,12 +73,16 @@ func main() {
 		fmt.Print("NEBULA> ")
 		scanner.Scan()
 		command := scanner.Text()
-		makerequest(command, client)
+
+		// every command is the root of a trace spanning the coordinator and the stores
+		ctx, span := tracer.Start(context.Background(), "cli "+strings.Split(command, " ")[0])
+		makerequest(ctx, command, client)
+		span.End()
 	}
 
 }
 
-func makerequest(command string, client pb.CoordinatorAPIClient) {
+func makerequest(ctx context.Context, command string, client pb.CoordinatorAPIClient) {
 	// split the command into tokens
 	tokens := strings.Split(command, " ")
 
@@ -59,7 +100,7 @@ func makerequest(command string, client pb.CoordinatorAPIClient) {
 			fmt.Println("Missing arguments: ADDSTORE <address> <name>")
 			return
 		}
-		res, err := client.AddStore(context.Background(), &pb.AddStoreRequest{
+		res, err := client.AddStore(ctx, &pb.AddStoreRequest{
 			Address: tokens[1],
 			Name:    tokens[2],
 		})
@@ -74,7 +115,7 @@ func makerequest(command string, client pb.CoordinatorAPIClient) {
 			fmt.Println("Missing arguments: PUT <key> <value>")
 			return
 		}
-		res, err := client.Put(context.Background(), &pb.PutRequest{
+		res, err := client.Put(ctx, &pb.PutRequest{
 			Key:   tokens[1],
 			Value: tokens[2],
 		})
@@ -89,7 +130,7 @@ func makerequest(command string, client pb.CoordinatorAPIClient) {
 			fmt.Println("Missing arguments: GET <key>")
 			return
 		}
-		res, err := client.Get(context.Background(), &pb.GetRequest{
+		res, err := client.Get(ctx, &pb.GetRequest{
 			Key: tokens[1],
 		})
 		if err != nil {
@@ -107,7 +148,7 @@ func makerequest(command string, client pb.CoordinatorAPIClient) {
 			fmt.Println("Missing arguments: DELETE <key>")
 			return
 		}
-		res, err := client.Delete(context.Background(), &pb.DeleteRequest{
+		res, err := client.Delete(ctx, &pb.DeleteRequest{
 			Key: tokens[1],
 		})
 		if err != nil {
@@ -121,7 +162,7 @@ func makerequest(command string, client pb.CoordinatorAPIClient) {
 			fmt.Println("Missing arguments: REMOVESTORE <name>")
 			return
 		}
-		res, err := client.RemoveStore(context.Background(), &pb.RemoveStoreRequest{
+		res, err := client.RemoveStore(ctx, &pb.RemoveStoreRequest{
 			Name: tokens[1],
 		})
 		if err != nil {
@@ -129,10 +170,238 @@ func makerequest(command string, client pb.CoordinatorAPIClient) {
 			return
 		}
 		fmt.Println("Status: ", res.Status)
+	case "STORES":
+		res, err := client.ListStores(ctx, &pb.ListStoresRequest{})
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		if len(res.Stores) == 0 {
+			fmt.Println("No stores")
+			return
+		}
+		for _, s := range res.Stores {
+			fmt.Printf("%-20s %-22s %-8s vnodes: %d\n", s.Name, s.Address, s.State, s.Vnodes)
+		}
+	case "RING":
+		res, err := client.DescribeRing(ctx, &pb.DescribeRingRequest{})
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		fmt.Println("Ring version: ", res.Version, " vnodes: ", res.Vnodes)
+		for _, s := range res.Stores {
+			fmt.Printf("%-20s %-22s vnodes: %-5d owns: %6.2f%%\n", s.Name, s.Address, len(s.Positions), s.OwnershipPercent)
+			for _, p := range s.Positions {
+				fmt.Printf("    %020d\n", p)
+			}
+		}
+	case "LOCATE":
+		// check if the command has the correct number of arguments
+		if len(tokens) != 2 {
+			fmt.Println("Missing arguments: LOCATE <key>")
+			return
+		}
+		res, err := client.LocateKey(ctx, &pb.LocateKeyRequest{
+			Key: tokens[1],
+		})
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		if len(res.Replicas) == 0 {
+			fmt.Println("No stores")
+			return
+		}
+		fmt.Printf("Hash: %020d owner: %s\n", res.Hash, res.Owner)
+		for i, r := range res.Replicas {
+			fmt.Printf("%d. %-20s %-22s %s", i+1, r.Name, r.Address, r.State)
+			if r.ServedBy != "" && r.ServedBy != r.Name {
+				fmt.Printf(" served by %s", r.ServedBy)
+			}
+			fmt.Println()
+		}
+	case "INFO":
+		res, err := client.ClusterStats(ctx, &pb.ClusterStatsRequest{})
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		printStats("cluster", res.Total)
+		for _, s := range res.Stores {
+			fmt.Println()
+			if s.Error != "" {
+				fmt.Printf("%s (%s) %s: %s\n", s.Name, s.Address, s.State, s.Error)
+				continue
+			}
+			printStats(fmt.Sprintf("%s (%s) %s", s.Name, s.Address, s.State), s)
+		}
+	case "SLOWLOG":
+		// SLOWLOG [count] or SLOWLOG RESET
+		req := &pb.SlowLogRequest{}
+		if len(tokens) > 2 {
+			fmt.Println("Too many arguments: SLOWLOG [count | RESET]")
+			return
+		}
+		if len(tokens) == 2 {
+			if tokens[1] == "RESET" {
+				req.Clear = true
+			} else {
+				count, err := strconv.ParseUint(tokens[1], 10, 32)
+				if err != nil {
+					fmt.Println("Invalid count: SLOWLOG [count | RESET]")
+					return
+				}
+				req.Count = uint32(count)
+			}
+		}
+		res, err := client.SlowLog(ctx, req)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		if req.Clear {
+			fmt.Println("Status: ", pb.StatusType_OK)
+			return
+		}
+		printSlowLog("coordinator", res.Entries, res.Latencies)
+		for _, s := range res.Stores {
+			fmt.Println()
+			if s.Error != "" {
+				fmt.Printf("%s (%s) %s: %s\n", s.Name, s.Address, s.State, s.Error)
+				continue
+			}
+			printSlowLog(fmt.Sprintf("%s (%s) %s", s.Name, s.Address, s.State), s.Entries, s.Latencies)
+		}
+	case "HOTKEYS":
+		// HOTKEYS [count]
+		req := &pb.HotKeysRequest{Count: 10}
+		if len(tokens) > 2 {
+			fmt.Println("Too many arguments: HOTKEYS [count]")
+			return
+		}
+		if len(tokens) == 2 {
+			count, err := strconv.ParseUint(tokens[1], 10, 32)
+			if err != nil {
+				fmt.Println("Invalid count: HOTKEYS [count]")
+				return
+			}
+			req.Count = uint32(count)
+		}
+		res, err := client.HotKeys(ctx, req)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		fmt.Println("cluster")
+		printHotKeys(res.Keys)
+		for _, s := range res.Stores {
+			fmt.Println()
+			if s.Error != "" {
+				fmt.Printf("%s (%s) %s: %s\n", s.Name, s.Address, s.State, s.Error)
+				continue
+			}
+			fmt.Printf("%s (%s) %s, half life %s\n", s.Name, s.Address, s.State, time.Duration(s.HalfLifeMs)*time.Millisecond)
+			printHotKeys(s.Keys)
+		}
+	case "AUDIT":
+		// AUDIT [count]
+		req := &pb.AuditLogRequest{Limit: 20}
+		if len(tokens) > 2 {
+			fmt.Println("Too many arguments: AUDIT [count]")
+			return
+		}
+		if len(tokens) == 2 {
+			count, err := strconv.ParseUint(tokens[1], 10, 32)
+			if err != nil {
+				fmt.Println("Invalid count: AUDIT [count]")
+				return
+			}
+			req.Limit = uint32(count)
+		}
+		res, err := client.AuditLog(ctx, req)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		if len(res.Records) == 0 {
+			fmt.Println("no audit records")
+		}
+		for _, r := range res.Records {
+			principal := r.Principal
+			if principal == "" {
+				principal = "-"
+			}
+			fmt.Printf("%d) %s %s %s %s from %s: %s", r.Seq, time.UnixMilli(r.TimestampMs).Format(time.RFC3339), principal, r.Method, r.Target, r.Peer, r.Outcome)
+			if r.Error != "" {
+				fmt.Printf(" (%s)", r.Error)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("head: %d %s\n", res.HeadSeq, res.HeadHash)
 	case "EXIT":
+		stopTracing(context.Background())
 		os.Exit(0)
 	default:
 		fmt.Println("Invalid command")
 	}
 
 }
+
+func printStats(title string, s *pb.StoreStats) {
+	hitRatio := 0.0
+	if s.Hits+s.Misses > 0 {
+		hitRatio = float64(s.Hits) / float64(s.Hits+s.Misses) * 100
+	}
+
+	fmt.Println(title)
+	fmt.Printf("  entries:     %d / %d (%d tombstones)\n", s.Entries, s.Capacity, s.Tombstones)
+	fmt.Printf("  memory:      %d bytes\n", s.MemoryBytes)
+	fmt.Printf("  hits:        %d\n", s.Hits)
+	fmt.Printf("  misses:      %d\n", s.Misses)
+	fmt.Printf("  hit ratio:   %.2f%%\n", hitRatio)
+	fmt.Printf("  puts:        %d\n", s.Puts)
+	fmt.Printf("  deletes:     %d\n", s.Deletes)
+	fmt.Printf("  evictions:   %d\n", s.Evictions)
+	fmt.Printf("  expirations: %d\n", s.Expirations)
+	fmt.Printf("  ops/sec:     %.2f\n", s.OpsPerSec)
+}
+
+func printSlowLog(title string, entries []*pb.SlowLogEntry, latencies []*pb.CommandLatency) {
+	fmt.Println(title)
+	for _, l := range latencies {
+		avg := int64(0)
+		if l.Calls > 0 {
+			avg = l.TotalUs / int64(l.Calls)
+		}
+		fmt.Printf("  %-8s calls: %-8d slow: %-6d avg: %6dµs max: %6dµs\n", l.Command, l.Calls, l.Slow, avg, l.MaxUs)
+	}
+	if len(entries) == 0 {
+		fmt.Println("  no slow requests")
+		return
+	}
+	for _, e := range entries {
+		fmt.Printf("  %d) %s %s %s %dµs", e.Id, time.UnixMilli(e.TimestampMs).Format(time.RFC3339), e.Command, e.Key, e.DurationUs)
+		if len(e.Stores) > 0 {
+			fmt.Printf(" stores: %s", strings.Join(e.Stores, ","))
+		}
+		fmt.Printf(" request: %s\n", e.RequestId)
+		for _, p := range e.Phases {
+			fmt.Printf("       %-12s %-12s %dµs\n", p.Name, p.Store, p.DurationUs)
+		}
+	}
+}
+
+func printHotKeys(keys []*pb.HotKey) {
+	if len(keys) == 0 {
+		fmt.Println("  no keys accessed")
+		return
+	}
+	for i, k := range keys {
+		fmt.Printf("  %d. %-30s ~%d", i+1, k.Key, k.Count)
+		if len(k.Stores) > 0 {
+			fmt.Printf(" on %s", strings.Join(k.Stores, ","))
+		}
+		fmt.Println()
+	}
+}
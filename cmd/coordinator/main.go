@@ -1,26 +1,153 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/priyansh32/nebula/internal/coordinator"
+	"github.com/priyansh32/nebula/internal/gossip"
 )
 
 func main() {
 
-	if len(os.Args) != 3 {
-		fmt.Println("Usage: coordinator <port> <replication-factor>")
+	cfg := coordinator.DefaultConfig()
+
+	flag.DurationVar(&cfg.Health.Interval, "health-interval", cfg.Health.Interval, "interval between store health probes")
+	flag.DurationVar(&cfg.Health.Timeout, "health-timeout", cfg.Health.Timeout, "timeout of a single store health probe")
+	flag.IntVar(&cfg.Health.SuspectThreshold, "suspect-after", cfg.Health.SuspectThreshold, "consecutive failed probes before a store is suspect")
+	flag.IntVar(&cfg.Health.DownThreshold, "down-after", cfg.Health.DownThreshold, "consecutive failed probes before a store is down")
+	flag.DurationVar(&cfg.Lease.TTL, "lease-ttl", cfg.Lease.TTL, "how long a self-registered store stays in the ring without a heartbeat")
+	gossipBind := flag.String("gossip-bind", "", "UDP address to gossip on, gossip is disabled if empty. Gossip is only encrypted and authenticated with -gossip-key")
+	gossipAdvertise := flag.String("gossip-advertise", "", "address other nodes should use to gossip with this coordinator (default -gossip-bind)")
+	gossipJoin := flag.String("gossip-join", "", "comma separated gossip addresses of nodes to join through")
+	gossipKey := flag.String("gossip-key", "", "base64 AES key of 16, 24 or 32 bytes shared by every node, gossip is encrypted and authenticated with it, required with TLS or -auth-rules (default $NEBULA_GOSSIP_KEY)")
+	gossipName := flag.String("gossip-name", "", "name of the coordinator in the gossip cluster (default coordinator-<port>)")
+	advertise := flag.String("advertise", "", "address other coordinators use to reach this coordinator (default localhost:<port>)")
+	raftID := flag.String("raft-id", "", "raft node id, raft is disabled if empty")
+	raftBind := flag.String("raft-bind", "", "TCP address raft listens on, with the certificates of -tls-cert if TLS is enabled")
+	raftAdvertise := flag.String("raft-advertise", "", "address other coordinators use to reach raft (default -raft-bind)")
+	raftDir := flag.String("raft-dir", "", "directory for the raft log and snapshots (default raft-<raft-id>)")
+	raftBootstrap := flag.Bool("raft-bootstrap", false, "start a new raft cluster with this coordinator as the only member")
+	raftJoin := flag.String("raft-join", "", "address of any coordinator of an existing raft cluster to join")
+	flag.StringVar(&cfg.Handoff.Path, "hints-file", "", "file hinted writes for unavailable stores are kept in, hints are kept in memory if empty")
+	flag.IntVar(&cfg.Handoff.MaxHints, "max-hints", cfg.Handoff.MaxHints, "maximum number of hinted writes kept")
+	flag.DurationVar(&cfg.Handoff.SyncInterval, "hints-sync-interval", cfg.Handoff.SyncInterval, "how often the hint file is synced to disk, hints taken since the last sync are lost if the machine crashes, 0 syncs every hint before acknowledging the write")
+	flag.IntVar(&cfg.Replication.Replicas, "replicas", cfg.Replication.Replicas, "number of stores each key is kept on")
+	flag.IntVar(&cfg.Replication.ReadQuorum, "read-quorum", cfg.Replication.ReadQuorum, "replicas that must answer a read")
+	flag.IntVar(&cfg.Replication.WriteQuorum, "write-quorum", cfg.Replication.WriteQuorum, "replicas that must acknowledge a write")
+	flag.BoolVar(&cfg.Replication.SyncReadRepair, "sync-read-repair", false, "repair stale replicas before answering a read instead of in the background")
+	flag.DurationVar(&cfg.AntiEntropy.Interval, "anti-entropy-interval", cfg.AntiEntropy.Interval, "interval between anti-entropy rounds among replicas, 0 disables them")
+	flag.DurationVar(&cfg.StoreTimeout, "store-timeout", cfg.StoreTimeout, "timeout of a single request to a store, 0 disables it")
+	flag.IntVar(&cfg.Resilience.MaxAttempts, "store-attempts", cfg.Resilience.MaxAttempts, "times a failed request to a store is tried")
+	flag.DurationVar(&cfg.Resilience.BaseBackoff, "retry-backoff", cfg.Resilience.BaseBackoff, "backoff before the first retry, doubled for every later one")
+	flag.DurationVar(&cfg.Resilience.MaxBackoff, "max-retry-backoff", cfg.Resilience.MaxBackoff, "maximum backoff between retries")
+	flag.IntVar(&cfg.Resilience.BreakerThreshold, "breaker-threshold", cfg.Resilience.BreakerThreshold, "consecutive failures that open the circuit breaker of a store")
+	flag.DurationVar(&cfg.Resilience.BreakerCooldown, "breaker-cooldown", cfg.Resilience.BreakerCooldown, "how long an open circuit breaker rejects requests before probing the store")
+	flag.BoolVar(&cfg.Hedge.Enabled, "hedged-reads", false, "with a read quorum of 1, ask another replica when the first one is slow to answer")
+	flag.Float64Var(&cfg.Hedge.Percentile, "hedge-percentile", cfg.Hedge.Percentile, "percentile of recent read latencies to wait before hedging a read")
+	flag.DurationVar(&cfg.Hedge.MinDelay, "hedge-min-delay", cfg.Hedge.MinDelay, "minimum delay before hedging a read")
+	flag.BoolVar(&cfg.NearCache.Enabled, "near-cache", false, "cache the values of hot keys in the coordinator")
+	flag.IntVar(&cfg.NearCache.Size, "near-cache-size", cfg.NearCache.Size, "number of keys the near cache holds")
+	flag.DurationVar(&cfg.NearCache.TTL, "near-cache-ttl", cfg.NearCache.TTL, "how long a value is served from the near cache")
+	flag.StringVar(&cfg.NearCache.Admission, "near-cache-admission", cfg.NearCache.Admission, "keys the near cache holds: hot for the hottest keys of the cluster or all")
+	flag.IntVar(&cfg.NearCache.HotKeys, "near-cache-hot-keys", cfg.NearCache.HotKeys, "number of the hottest keys of the cluster cached with the hot admission")
+	flag.DurationVar(&cfg.NearCache.HotInterval, "near-cache-hot-interval", cfg.NearCache.HotInterval, "interval between updates of the hottest keys of the cluster")
+	flag.StringVar(&cfg.MetricsAddress, "metrics-address", "", "HTTP address prometheus metrics are served on at /metrics, metrics are disabled if empty")
+	flag.StringVar(&cfg.Tracing.Exporter, "trace-exporter", "", "where spans are exported to: otlp, stdout or file, tracing is disabled if empty")
+	flag.StringVar(&cfg.Tracing.Endpoint, "trace-endpoint", "", "OTLP collector address for the otlp exporter or the file spans are written to for the file exporter")
+	flag.Float64Var(&cfg.Tracing.SampleRatio, "trace-sample-ratio", cfg.Tracing.SampleRatio, "share of requests that start a trace")
+	logLevel := flag.String("log-level", "info", "minimum level of logs, optionally per component, e.g. info,requests=debug")
+	logFormat := flag.String("log-format", "text", "format of logs: text or json")
+	flag.BoolVar(&cfg.Logging.Values, "log-values", false, "log keys and their values instead of hashing keys and redacting values")
+	flag.Uint64Var(&cfg.Logging.SampleEvery, "log-sample", cfg.Logging.SampleEvery, "keep one in this many logs of every request")
+	flag.DurationVar(&cfg.SlowLog.Threshold, "slowlog-threshold", cfg.SlowLog.Threshold, "requests taking longer than this are kept in the slow log, 0 keeps all and a negative value none")
+	flag.IntVar(&cfg.SlowLog.Size, "slowlog-size", cfg.SlowLog.Size, "number of slow requests kept")
+	flag.BoolVar(&cfg.SlowLog.HashKeys, "slowlog-hash-keys", false, "keep a hash of the keys of slow requests instead of the keys")
+	flag.StringVar(&cfg.TLS.CertFile, "tls-cert", "", "PEM certificate the coordinator serves and dials stores with, TLS is disabled without it or -tls-ca")
+	flag.StringVar(&cfg.TLS.KeyFile, "tls-key", "", "PEM key of -tls-cert")
+	flag.StringVar(&cfg.TLS.CAFile, "tls-ca", "", "PEM certificates of the CAs the certificates of stores, clients and other coordinators are verified with (default the system CAs)")
+	flag.BoolVar(&cfg.TLS.ClientAuth, "tls-client-auth", false, "require clients to present a certificate signed by a CA of -tls-ca")
+	flag.StringVar(&cfg.TLS.ServerName, "tls-server-name", "", "name the certificates of stores and other coordinators are verified for (default the host dialed)")
+	flag.StringVar(&cfg.Auth.RulesFile, "auth-rules", "", "JSON file of the roles and users allowed to send requests, reloaded when it changes, requests are not authenticated if empty")
+	flag.StringVar(&cfg.Auth.Token, "auth-token", "", "bearer token with a cluster role the coordinator sends to stores and when joining a raft cluster, if they authenticate requests")
+	flag.StringVar(&cfg.Audit.Path, "audit-log", "", "file membership changes and the writes of -audit-prefix keys are recorded in, auditing is disabled if empty")
+	flag.Func("audit-prefix", "audit the writes and deletes of keys with this prefix, may be repeated, an empty prefix audits every key", func(p string) error {
+		cfg.Audit.Prefixes = append(cfg.Audit.Prefixes, p)
+		return nil
+	})
+	flag.StringVar(&cfg.Encryption.KeysFile, "encryption-keys-file", "", "file of the id:base64-key AES keys the hint file is encrypted with, the first one encrypts, keys are also read from $NEBULA_ENCRYPTION_KEYS")
+	flag.StringVar(&cfg.Redis.Address, "redis-address", "", "TCP address the redis protocol is served on, with TLS if the coordinator serves TLS, it is not served if empty")
+	flag.StringVar(&cfg.StateFile, "state-file", "", "file the ring is persisted to so that it survives restarts")
+	flag.Parse()
+
+	cfg.Encryption.Keys = os.Getenv("NEBULA_ENCRYPTION_KEYS")
+
+	args := flag.Args()
+
+	if len(args) != 2 {
+		fmt.Println("Usage: coordinator [flags] <port> <replication-factor>")
 		os.Exit(1)
 	}
 
-	coordinatorPort := os.Args[1]
-	replicationFactor, err := strconv.Atoi(os.Args[2])
+	if err := cfg.Logging.SetLevels(*logLevel); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := cfg.Logging.SetFormat(*logFormat); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	coordinatorPort := args[0]
+	replicationFactor, err := strconv.Atoi(args[1])
 	if err != nil {
 		fmt.Println("Replication factor must be an integer")
 		os.Exit(1)
 	}
 
-	coordinator.InitCoordinator(coordinatorPort, replicationFactor) // Blocking call
+	if *gossipBind != "" {
+		g := gossip.DefaultConfig()
+		g.Name = *gossipName
+		if g.Name == "" {
+			g.Name = "coordinator-" + coordinatorPort
+		}
+		g.Role = gossip.RoleCoordinator
+		g.BindAddress = *gossipBind
+		g.AdvertiseAddress = *gossipAdvertise
+		if g.AdvertiseAddress == "" {
+			g.AdvertiseAddress = *gossipBind
+		}
+		if *gossipJoin != "" {
+			g.Seeds = strings.Split(*gossipJoin, ",")
+		}
+		g.Key = *gossipKey
+		if g.Key == "" {
+			g.Key = os.Getenv("NEBULA_GOSSIP_KEY")
+		}
+		cfg.Gossip = &g
+	}
+
+	if *advertise == "" {
+		*advertise = "localhost:" + coordinatorPort
+	}
+
+	if *raftID != "" {
+		cfg.Raft = &coordinator.RaftConfig{
+			NodeID:           *raftID,
+			BindAddress:      *raftBind,
+			AdvertiseAddress: *raftAdvertise,
+			APIAddress:       *advertise,
+			DataDir:          *raftDir,
+			Bootstrap:        *raftBootstrap,
+			Join:             *raftJoin,
+		}
+		if cfg.Raft.DataDir == "" {
+			cfg.Raft.DataDir = "raft-" + *raftID
+		}
+	}
+
+	coordinator.InitCoordinator(coordinatorPort, replicationFactor, cfg) // Blocking call
 }
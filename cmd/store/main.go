@@ -1,22 +1,117 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/priyansh32/nebula/internal/gossip"
+	"github.com/priyansh32/nebula/internal/logging"
+	"github.com/priyansh32/nebula/internal/slowlog"
 	"github.com/priyansh32/nebula/internal/store"
+	"github.com/priyansh32/nebula/internal/tlsconfig"
+	"github.com/priyansh32/nebula/internal/tracing"
 )
 
 func main() {
 
-	args := os.Args[1:]
+	coordinatorAddress := flag.String("coordinator", "", "address of the coordinator to register with, registration is skipped if empty")
+	name := flag.String("name", "", "name of the store in the cluster")
+	advertise := flag.String("advertise", "", "address the coordinator should use to reach this store (default localhost:<port>)")
+	gossipBind := flag.String("gossip-bind", "", "UDP address to gossip on, gossip is disabled if empty. Gossip is only encrypted and authenticated with -gossip-key")
+	gossipAdvertise := flag.String("gossip-advertise", "", "address other nodes should use to gossip with this store (default -gossip-bind)")
+	gossipJoin := flag.String("gossip-join", "", "comma separated gossip addresses of nodes to join through")
+	gossipKey := flag.String("gossip-key", "", "base64 AES key of 16, 24 or 32 bytes shared by every node, gossip is encrypted and authenticated with it, required with TLS or -auth-rules (default $NEBULA_GOSSIP_KEY)")
+	vnodes := flag.Int("vnodes", 8, "number of ring positions the store claims when gossiping")
+	traceExporter := flag.String("trace-exporter", "", "where spans are exported to: otlp, stdout or file, tracing is disabled if empty")
+	traceEndpoint := flag.String("trace-endpoint", "", "OTLP collector address for the otlp exporter or the file spans are written to for the file exporter")
+	traceSampleRatio := flag.Float64("trace-sample-ratio", 1, "share of requests that start a trace")
+	logLevel := flag.String("log-level", "info", "minimum level of logs, optionally per component, e.g. info,requests=debug")
+	logFormat := flag.String("log-format", "text", "format of logs: text or json")
+	logValues := flag.Bool("log-values", false, "log keys and their values instead of hashing keys and redacting values")
+	logSample := flag.Uint64("log-sample", 1, "keep one in this many logs of every request")
+	slowCfg := slowlog.DefaultConfig()
+	flag.DurationVar(&slowCfg.Threshold, "slowlog-threshold", slowCfg.Threshold, "requests taking longer than this are kept in the slow log, 0 keeps all and a negative value none")
+	flag.IntVar(&slowCfg.Size, "slowlog-size", slowCfg.Size, "number of slow requests kept")
+	flag.BoolVar(&slowCfg.HashKeys, "slowlog-hash-keys", false, "keep a hash of the keys of slow requests instead of the keys")
+	hotCfg := store.DefaultHotKeysConfig()
+	flag.IntVar(&hotCfg.TopK, "hotkeys-top", hotCfg.TopK, "number of most accessed keys tracked")
+	flag.DurationVar(&hotCfg.HalfLife, "hotkeys-half-life", hotCfg.HalfLife, "period after which past accesses of keys count half")
+	var tlsCfg tlsconfig.Config
+	flag.StringVar(&tlsCfg.CertFile, "tls-cert", "", "PEM certificate the store serves and dials the coordinator and other stores with, TLS is disabled without it or -tls-ca")
+	flag.StringVar(&tlsCfg.KeyFile, "tls-key", "", "PEM key of -tls-cert")
+	flag.StringVar(&tlsCfg.CAFile, "tls-ca", "", "PEM certificates of the CAs the certificates of the coordinator, clients and other stores are verified with (default the system CAs)")
+	flag.BoolVar(&tlsCfg.ClientAuth, "tls-client-auth", false, "require clients to present a certificate signed by a CA of -tls-ca")
+	flag.StringVar(&tlsCfg.ServerName, "tls-server-name", "", "name the certificates of the coordinator and other stores are verified for (default the host dialed)")
+	var authCfg store.AuthConfig
+	flag.StringVar(&authCfg.RulesFile, "auth-rules", "", "JSON file of the roles and users allowed to send requests, reloaded when it changes, requests are not authenticated if empty")
+	flag.StringVar(&authCfg.Token, "auth-token", "", "bearer token with a cluster role the store sends to the coordinator and other stores, if they authenticate requests")
+	metricsAddress := flag.String("metrics-address", "", "HTTP address prometheus metrics are served on at /metrics, metrics are disabled if empty")
+	flag.Parse()
+
+	args := flag.Args()
 
 	if len(args) < 1 {
-		log.Fatalf("Usage: store <port>")
+		log.Fatalf("Usage: store [flags] <port>")
 	}
 
 	port := args[0]
 	capacity := store.DEFAULT_CAPACITY
 
-	store.InitStoreServer(":"+port, uint32(capacity)) // blocking call
+	if (*coordinatorAddress != "" || *gossipBind != "") && *name == "" {
+		log.Fatalf("-name is required when -coordinator or -gossip-bind is set")
+	}
+	if *advertise == "" {
+		*advertise = "localhost:" + port
+	}
+
+	cfg := store.Config{MetricsAddress: *metricsAddress, SlowLog: &slowCfg, HotKeys: &hotCfg, TLS: tlsCfg, Auth: authCfg}
+	cfg.Tracing = tracing.Config{
+		Exporter:    *traceExporter,
+		Endpoint:    *traceEndpoint,
+		SampleRatio: *traceSampleRatio,
+	}
+
+	cfg.Logging = logging.DefaultConfig()
+	cfg.Logging.Values = *logValues
+	cfg.Logging.SampleEvery = *logSample
+	if err := cfg.Logging.SetLevels(*logLevel); err != nil {
+		log.Fatalf("%s", err)
+	}
+	if err := cfg.Logging.SetFormat(*logFormat); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if *coordinatorAddress != "" {
+		cfg.Registration = &store.Registration{
+			Coordinator: *coordinatorAddress,
+			Name:        *name,
+			Address:     *advertise,
+			Token:       authCfg.Token,
+		}
+	}
+
+	if *gossipBind != "" {
+		g := gossip.DefaultConfig()
+		g.Name = *name
+		g.Role = gossip.RoleStore
+		g.BindAddress = *gossipBind
+		g.AdvertiseAddress = *gossipAdvertise
+		if g.AdvertiseAddress == "" {
+			g.AdvertiseAddress = *gossipBind
+		}
+		g.StoreAddress = *advertise
+		g.Tokens = gossip.NewTokens(*name, *vnodes)
+		if *gossipJoin != "" {
+			g.Seeds = strings.Split(*gossipJoin, ",")
+		}
+		g.Key = *gossipKey
+		if g.Key == "" {
+			g.Key = os.Getenv("NEBULA_GOSSIP_KEY")
+		}
+		cfg.Gossip = &g
+	}
+
+	store.InitStoreServer(":"+port, uint32(capacity), cfg) // blocking call
 }
@@ -27,6 +27,21 @@ type CoordinatorAPIClient interface {
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
 	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error)
 	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	RegisterStore(ctx context.Context, in *RegisterStoreRequest, opts ...grpc.CallOption) (*RegisterStoreResponse, error)
+	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
+	DeregisterStore(ctx context.Context, in *DeregisterStoreRequest, opts ...grpc.CallOption) (*DeregisterStoreResponse, error)
+	JoinCluster(ctx context.Context, in *JoinClusterRequest, opts ...grpc.CallOption) (*JoinClusterResponse, error)
+	CircuitBreakers(ctx context.Context, in *CircuitBreakersRequest, opts ...grpc.CallOption) (*CircuitBreakersResponse, error)
+	GetRing(ctx context.Context, in *GetRingRequest, opts ...grpc.CallOption) (*GetRingResponse, error)
+	WatchRing(ctx context.Context, in *WatchRingRequest, opts ...grpc.CallOption) (*GetRingResponse, error)
+	ListStores(ctx context.Context, in *ListStoresRequest, opts ...grpc.CallOption) (*ListStoresResponse, error)
+	DescribeRing(ctx context.Context, in *DescribeRingRequest, opts ...grpc.CallOption) (*DescribeRingResponse, error)
+	LocateKey(ctx context.Context, in *LocateKeyRequest, opts ...grpc.CallOption) (*LocateKeyResponse, error)
+	ClusterStats(ctx context.Context, in *ClusterStatsRequest, opts ...grpc.CallOption) (*ClusterStatsResponse, error)
+	SlowLog(ctx context.Context, in *SlowLogRequest, opts ...grpc.CallOption) (*SlowLogResponse, error)
+	HotKeys(ctx context.Context, in *HotKeysRequest, opts ...grpc.CallOption) (*HotKeysResponse, error)
+	AuditLog(ctx context.Context, in *AuditLogRequest, opts ...grpc.CallOption) (*AuditLogResponse, error)
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error)
 }
 
 type coordinatorAPIClient struct {
@@ -82,6 +97,141 @@ func (c *coordinatorAPIClient) Delete(ctx context.Context, in *DeleteRequest, op
 	return out, nil
 }
 
+func (c *coordinatorAPIClient) RegisterStore(ctx context.Context, in *RegisterStoreRequest, opts ...grpc.CallOption) (*RegisterStoreResponse, error) {
+	out := new(RegisterStoreResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/RegisterStore", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error) {
+	out := new(HeartbeatResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/Heartbeat", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) DeregisterStore(ctx context.Context, in *DeregisterStoreRequest, opts ...grpc.CallOption) (*DeregisterStoreResponse, error) {
+	out := new(DeregisterStoreResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/DeregisterStore", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) JoinCluster(ctx context.Context, in *JoinClusterRequest, opts ...grpc.CallOption) (*JoinClusterResponse, error) {
+	out := new(JoinClusterResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/JoinCluster", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) CircuitBreakers(ctx context.Context, in *CircuitBreakersRequest, opts ...grpc.CallOption) (*CircuitBreakersResponse, error) {
+	out := new(CircuitBreakersResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/CircuitBreakers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) GetRing(ctx context.Context, in *GetRingRequest, opts ...grpc.CallOption) (*GetRingResponse, error) {
+	out := new(GetRingResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/GetRing", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) WatchRing(ctx context.Context, in *WatchRingRequest, opts ...grpc.CallOption) (*GetRingResponse, error) {
+	out := new(GetRingResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/WatchRing", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) ListStores(ctx context.Context, in *ListStoresRequest, opts ...grpc.CallOption) (*ListStoresResponse, error) {
+	out := new(ListStoresResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/ListStores", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) DescribeRing(ctx context.Context, in *DescribeRingRequest, opts ...grpc.CallOption) (*DescribeRingResponse, error) {
+	out := new(DescribeRingResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/DescribeRing", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) LocateKey(ctx context.Context, in *LocateKeyRequest, opts ...grpc.CallOption) (*LocateKeyResponse, error) {
+	out := new(LocateKeyResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/LocateKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) ClusterStats(ctx context.Context, in *ClusterStatsRequest, opts ...grpc.CallOption) (*ClusterStatsResponse, error) {
+	out := new(ClusterStatsResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/ClusterStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) SlowLog(ctx context.Context, in *SlowLogRequest, opts ...grpc.CallOption) (*SlowLogResponse, error) {
+	out := new(SlowLogResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/SlowLog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) HotKeys(ctx context.Context, in *HotKeysRequest, opts ...grpc.CallOption) (*HotKeysResponse, error) {
+	out := new(HotKeysResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/HotKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) AuditLog(ctx context.Context, in *AuditLogRequest, opts ...grpc.CallOption) (*AuditLogResponse, error) {
+	out := new(AuditLogResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/AuditLog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorAPIClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error) {
+	out := new(ScanResponse)
+	err := c.cc.Invoke(ctx, "/coordinator.CoordinatorAPI/Scan", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CoordinatorAPIServer is the server API for CoordinatorAPI service.
 // All implementations must embed UnimplementedCoordinatorAPIServer
 // for forward compatibility
@@ -91,6 +241,21 @@ type CoordinatorAPIServer interface {
 	Get(context.Context, *GetRequest) (*GetResponse, error)
 	Put(context.Context, *PutRequest) (*PutResponse, error)
 	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	RegisterStore(context.Context, *RegisterStoreRequest) (*RegisterStoreResponse, error)
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+	DeregisterStore(context.Context, *DeregisterStoreRequest) (*DeregisterStoreResponse, error)
+	JoinCluster(context.Context, *JoinClusterRequest) (*JoinClusterResponse, error)
+	CircuitBreakers(context.Context, *CircuitBreakersRequest) (*CircuitBreakersResponse, error)
+	GetRing(context.Context, *GetRingRequest) (*GetRingResponse, error)
+	WatchRing(context.Context, *WatchRingRequest) (*GetRingResponse, error)
+	ListStores(context.Context, *ListStoresRequest) (*ListStoresResponse, error)
+	DescribeRing(context.Context, *DescribeRingRequest) (*DescribeRingResponse, error)
+	LocateKey(context.Context, *LocateKeyRequest) (*LocateKeyResponse, error)
+	ClusterStats(context.Context, *ClusterStatsRequest) (*ClusterStatsResponse, error)
+	SlowLog(context.Context, *SlowLogRequest) (*SlowLogResponse, error)
+	HotKeys(context.Context, *HotKeysRequest) (*HotKeysResponse, error)
+	AuditLog(context.Context, *AuditLogRequest) (*AuditLogResponse, error)
+	Scan(context.Context, *ScanRequest) (*ScanResponse, error)
 	mustEmbedUnimplementedCoordinatorAPIServer()
 }
 
@@ -113,6 +278,51 @@ func (UnimplementedCoordinatorAPIServer) Put(context.Context, *PutRequest) (*Put
 func (UnimplementedCoordinatorAPIServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
 }
+func (UnimplementedCoordinatorAPIServer) RegisterStore(context.Context, *RegisterStoreRequest) (*RegisterStoreResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterStore not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) DeregisterStore(context.Context, *DeregisterStoreRequest) (*DeregisterStoreResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeregisterStore not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) JoinCluster(context.Context, *JoinClusterRequest) (*JoinClusterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method JoinCluster not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) CircuitBreakers(context.Context, *CircuitBreakersRequest) (*CircuitBreakersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CircuitBreakers not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) GetRing(context.Context, *GetRingRequest) (*GetRingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRing not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) WatchRing(context.Context, *WatchRingRequest) (*GetRingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WatchRing not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) ListStores(context.Context, *ListStoresRequest) (*ListStoresResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStores not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) DescribeRing(context.Context, *DescribeRingRequest) (*DescribeRingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DescribeRing not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) LocateKey(context.Context, *LocateKeyRequest) (*LocateKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LocateKey not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) ClusterStats(context.Context, *ClusterStatsRequest) (*ClusterStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClusterStats not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) SlowLog(context.Context, *SlowLogRequest) (*SlowLogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SlowLog not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) HotKeys(context.Context, *HotKeysRequest) (*HotKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HotKeys not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) AuditLog(context.Context, *AuditLogRequest) (*AuditLogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AuditLog not implemented")
+}
+func (UnimplementedCoordinatorAPIServer) Scan(context.Context, *ScanRequest) (*ScanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
 func (UnimplementedCoordinatorAPIServer) mustEmbedUnimplementedCoordinatorAPIServer() {}
 
 // UnsafeCoordinatorAPIServer may be embedded to opt out of forward compatibility for this service.
@@ -216,6 +426,276 @@ func _CoordinatorAPI_Delete_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CoordinatorAPI_RegisterStore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterStoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).RegisterStore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/RegisterStore",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).RegisterStore(ctx, req.(*RegisterStoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/Heartbeat",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_DeregisterStore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeregisterStoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).DeregisterStore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/DeregisterStore",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).DeregisterStore(ctx, req.(*DeregisterStoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_JoinCluster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JoinClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).JoinCluster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/JoinCluster",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).JoinCluster(ctx, req.(*JoinClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_CircuitBreakers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CircuitBreakersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).CircuitBreakers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/CircuitBreakers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).CircuitBreakers(ctx, req.(*CircuitBreakersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_GetRing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).GetRing(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/GetRing",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).GetRing(ctx, req.(*GetRingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_WatchRing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WatchRingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).WatchRing(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/WatchRing",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).WatchRing(ctx, req.(*WatchRingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_ListStores_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStoresRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).ListStores(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/ListStores",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).ListStores(ctx, req.(*ListStoresRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_DescribeRing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DescribeRingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).DescribeRing(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/DescribeRing",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).DescribeRing(ctx, req.(*DescribeRingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_LocateKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LocateKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).LocateKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/LocateKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).LocateKey(ctx, req.(*LocateKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_ClusterStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).ClusterStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/ClusterStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).ClusterStats(ctx, req.(*ClusterStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_SlowLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SlowLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).SlowLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/SlowLog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).SlowLog(ctx, req.(*SlowLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_HotKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HotKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).HotKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/HotKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).HotKeys(ctx, req.(*HotKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_AuditLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).AuditLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/AuditLog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).AuditLog(ctx, req.(*AuditLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorAPI_Scan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorAPIServer).Scan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/coordinator.CoordinatorAPI/Scan",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorAPIServer).Scan(ctx, req.(*ScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CoordinatorAPI_ServiceDesc is the grpc.ServiceDesc for CoordinatorAPI service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -243,6 +723,66 @@ var CoordinatorAPI_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Delete",
 			Handler:    _CoordinatorAPI_Delete_Handler,
 		},
+		{
+			MethodName: "RegisterStore",
+			Handler:    _CoordinatorAPI_RegisterStore_Handler,
+		},
+		{
+			MethodName: "Heartbeat",
+			Handler:    _CoordinatorAPI_Heartbeat_Handler,
+		},
+		{
+			MethodName: "DeregisterStore",
+			Handler:    _CoordinatorAPI_DeregisterStore_Handler,
+		},
+		{
+			MethodName: "JoinCluster",
+			Handler:    _CoordinatorAPI_JoinCluster_Handler,
+		},
+		{
+			MethodName: "CircuitBreakers",
+			Handler:    _CoordinatorAPI_CircuitBreakers_Handler,
+		},
+		{
+			MethodName: "GetRing",
+			Handler:    _CoordinatorAPI_GetRing_Handler,
+		},
+		{
+			MethodName: "WatchRing",
+			Handler:    _CoordinatorAPI_WatchRing_Handler,
+		},
+		{
+			MethodName: "ListStores",
+			Handler:    _CoordinatorAPI_ListStores_Handler,
+		},
+		{
+			MethodName: "DescribeRing",
+			Handler:    _CoordinatorAPI_DescribeRing_Handler,
+		},
+		{
+			MethodName: "LocateKey",
+			Handler:    _CoordinatorAPI_LocateKey_Handler,
+		},
+		{
+			MethodName: "ClusterStats",
+			Handler:    _CoordinatorAPI_ClusterStats_Handler,
+		},
+		{
+			MethodName: "SlowLog",
+			Handler:    _CoordinatorAPI_SlowLog_Handler,
+		},
+		{
+			MethodName: "HotKeys",
+			Handler:    _CoordinatorAPI_HotKeys_Handler,
+		},
+		{
+			MethodName: "AuditLog",
+			Handler:    _CoordinatorAPI_AuditLog_Handler,
+		},
+		{
+			MethodName: "Scan",
+			Handler:    _CoordinatorAPI_Scan_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "coordinator.proto",
@@ -25,6 +25,14 @@ type KeyValueStoreClient interface {
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
 	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error)
 	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	MerkleDigests(ctx context.Context, in *MerkleDigestsRequest, opts ...grpc.CallOption) (*MerkleDigestsResponse, error)
+	MerkleEntries(ctx context.Context, in *MerkleEntriesRequest, opts ...grpc.CallOption) (*MerkleEntriesResponse, error)
+	SyncRanges(ctx context.Context, in *SyncRangesRequest, opts ...grpc.CallOption) (*SyncRangesResponse, error)
+	AssignRanges(ctx context.Context, in *AssignRangesRequest, opts ...grpc.CallOption) (*AssignRangesResponse, error)
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+	SlowLog(ctx context.Context, in *SlowLogRequest, opts ...grpc.CallOption) (*SlowLogResponse, error)
+	HotKeys(ctx context.Context, in *HotKeysRequest, opts ...grpc.CallOption) (*HotKeysResponse, error)
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error)
 }
 
 type keyValueStoreClient struct {
@@ -62,6 +70,78 @@ func (c *keyValueStoreClient) Delete(ctx context.Context, in *DeleteRequest, opt
 	return out, nil
 }
 
+func (c *keyValueStoreClient) MerkleDigests(ctx context.Context, in *MerkleDigestsRequest, opts ...grpc.CallOption) (*MerkleDigestsResponse, error) {
+	out := new(MerkleDigestsResponse)
+	err := c.cc.Invoke(ctx, "/store.KeyValueStore/MerkleDigests", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueStoreClient) MerkleEntries(ctx context.Context, in *MerkleEntriesRequest, opts ...grpc.CallOption) (*MerkleEntriesResponse, error) {
+	out := new(MerkleEntriesResponse)
+	err := c.cc.Invoke(ctx, "/store.KeyValueStore/MerkleEntries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueStoreClient) SyncRanges(ctx context.Context, in *SyncRangesRequest, opts ...grpc.CallOption) (*SyncRangesResponse, error) {
+	out := new(SyncRangesResponse)
+	err := c.cc.Invoke(ctx, "/store.KeyValueStore/SyncRanges", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueStoreClient) AssignRanges(ctx context.Context, in *AssignRangesRequest, opts ...grpc.CallOption) (*AssignRangesResponse, error) {
+	out := new(AssignRangesResponse)
+	err := c.cc.Invoke(ctx, "/store.KeyValueStore/AssignRanges", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueStoreClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, "/store.KeyValueStore/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueStoreClient) SlowLog(ctx context.Context, in *SlowLogRequest, opts ...grpc.CallOption) (*SlowLogResponse, error) {
+	out := new(SlowLogResponse)
+	err := c.cc.Invoke(ctx, "/store.KeyValueStore/SlowLog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueStoreClient) HotKeys(ctx context.Context, in *HotKeysRequest, opts ...grpc.CallOption) (*HotKeysResponse, error) {
+	out := new(HotKeysResponse)
+	err := c.cc.Invoke(ctx, "/store.KeyValueStore/HotKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueStoreClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error) {
+	out := new(ScanResponse)
+	err := c.cc.Invoke(ctx, "/store.KeyValueStore/Scan", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // KeyValueStoreServer is the server API for KeyValueStore service.
 // All implementations must embed UnimplementedKeyValueStoreServer
 // for forward compatibility
@@ -69,6 +149,14 @@ type KeyValueStoreServer interface {
 	Get(context.Context, *GetRequest) (*GetResponse, error)
 	Put(context.Context, *PutRequest) (*PutResponse, error)
 	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	MerkleDigests(context.Context, *MerkleDigestsRequest) (*MerkleDigestsResponse, error)
+	MerkleEntries(context.Context, *MerkleEntriesRequest) (*MerkleEntriesResponse, error)
+	SyncRanges(context.Context, *SyncRangesRequest) (*SyncRangesResponse, error)
+	AssignRanges(context.Context, *AssignRangesRequest) (*AssignRangesResponse, error)
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	SlowLog(context.Context, *SlowLogRequest) (*SlowLogResponse, error)
+	HotKeys(context.Context, *HotKeysRequest) (*HotKeysResponse, error)
+	Scan(context.Context, *ScanRequest) (*ScanResponse, error)
 	mustEmbedUnimplementedKeyValueStoreServer()
 }
 
@@ -85,6 +173,30 @@ func (UnimplementedKeyValueStoreServer) Put(context.Context, *PutRequest) (*PutR
 func (UnimplementedKeyValueStoreServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
 }
+func (UnimplementedKeyValueStoreServer) MerkleDigests(context.Context, *MerkleDigestsRequest) (*MerkleDigestsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MerkleDigests not implemented")
+}
+func (UnimplementedKeyValueStoreServer) MerkleEntries(context.Context, *MerkleEntriesRequest) (*MerkleEntriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MerkleEntries not implemented")
+}
+func (UnimplementedKeyValueStoreServer) SyncRanges(context.Context, *SyncRangesRequest) (*SyncRangesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncRanges not implemented")
+}
+func (UnimplementedKeyValueStoreServer) AssignRanges(context.Context, *AssignRangesRequest) (*AssignRangesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AssignRanges not implemented")
+}
+func (UnimplementedKeyValueStoreServer) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedKeyValueStoreServer) SlowLog(context.Context, *SlowLogRequest) (*SlowLogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SlowLog not implemented")
+}
+func (UnimplementedKeyValueStoreServer) HotKeys(context.Context, *HotKeysRequest) (*HotKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HotKeys not implemented")
+}
+func (UnimplementedKeyValueStoreServer) Scan(context.Context, *ScanRequest) (*ScanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
 func (UnimplementedKeyValueStoreServer) mustEmbedUnimplementedKeyValueStoreServer() {}
 
 // UnsafeKeyValueStoreServer may be embedded to opt out of forward compatibility for this service.
@@ -152,6 +264,150 @@ func _KeyValueStore_Delete_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _KeyValueStore_MerkleDigests_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MerkleDigestsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueStoreServer).MerkleDigests(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/store.KeyValueStore/MerkleDigests",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueStoreServer).MerkleDigests(ctx, req.(*MerkleDigestsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValueStore_MerkleEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MerkleEntriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueStoreServer).MerkleEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/store.KeyValueStore/MerkleEntries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueStoreServer).MerkleEntries(ctx, req.(*MerkleEntriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValueStore_SyncRanges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncRangesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueStoreServer).SyncRanges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/store.KeyValueStore/SyncRanges",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueStoreServer).SyncRanges(ctx, req.(*SyncRangesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValueStore_AssignRanges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AssignRangesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueStoreServer).AssignRanges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/store.KeyValueStore/AssignRanges",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueStoreServer).AssignRanges(ctx, req.(*AssignRangesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValueStore_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueStoreServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/store.KeyValueStore/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueStoreServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValueStore_SlowLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SlowLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueStoreServer).SlowLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/store.KeyValueStore/SlowLog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueStoreServer).SlowLog(ctx, req.(*SlowLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValueStore_HotKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HotKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueStoreServer).HotKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/store.KeyValueStore/HotKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueStoreServer).HotKeys(ctx, req.(*HotKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValueStore_Scan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueStoreServer).Scan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/store.KeyValueStore/Scan",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueStoreServer).Scan(ctx, req.(*ScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // KeyValueStore_ServiceDesc is the grpc.ServiceDesc for KeyValueStore service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -171,6 +427,38 @@ var KeyValueStore_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Delete",
 			Handler:    _KeyValueStore_Delete_Handler,
 		},
+		{
+			MethodName: "MerkleDigests",
+			Handler:    _KeyValueStore_MerkleDigests_Handler,
+		},
+		{
+			MethodName: "MerkleEntries",
+			Handler:    _KeyValueStore_MerkleEntries_Handler,
+		},
+		{
+			MethodName: "SyncRanges",
+			Handler:    _KeyValueStore_SyncRanges_Handler,
+		},
+		{
+			MethodName: "AssignRanges",
+			Handler:    _KeyValueStore_AssignRanges_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _KeyValueStore_Stats_Handler,
+		},
+		{
+			MethodName: "SlowLog",
+			Handler:    _KeyValueStore_SlowLog_Handler,
+		},
+		{
+			MethodName: "HotKeys",
+			Handler:    _KeyValueStore_HotKeys_Handler,
+		},
+		{
+			MethodName: "Scan",
+			Handler:    _KeyValueStore_Scan_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "kvstore.proto",
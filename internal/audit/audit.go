@@ -0,0 +1,278 @@
+// Package audit keeps an append-only log of the requests that change a
+// nebula cluster or its data. Every record holds the hash of the record
+// before it, so that records that are changed or removed from the middle
+// of the log are detected.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/priyansh32/nebula/internal/auth"
+	"github.com/priyansh32/nebula/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// hash of the record before the first one
+var genesis = hex.EncodeToString(make([]byte, sha256.Size))
+
+// Record is an audited request
+type Record struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+
+	// Principal is the user the request came from, empty if requests are
+	// not authenticated, and Peer the address it came from
+	Principal string `json:"principal"`
+	Peer      string `json:"peer"`
+
+	// Method is the RPC and Target the store or key it was for
+	Method    string `json:"method"`
+	Target    string `json:"target,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// Outcome is the gRPC code of the answer, Error its message if it failed
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// returns the hash of the record, chained to the record before it
+func (r Record) digest() string {
+	r.Hash = ""
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Log is an audit log file, records are only ever appended to it
+type Log struct {
+	path string
+
+	mu   sync.Mutex
+	f    *os.File
+	seq  uint64
+	head string
+}
+
+// Open opens the audit log at path, creating it if needed. It fails if
+// the records already in it do not form an unbroken chain. A last record
+// that was torn by a crash while it was appended is cut off the file.
+func Open(path string) (*Log, error) {
+	l := &Log{path: path, head: genesis}
+
+	records, size, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > size {
+		logging.Logger("audit").Warn("cutting off a torn record at the end of the audit log", "path", path, "bytes", info.Size()-size)
+		if err := os.Truncate(path, size); err != nil {
+			return nil, err
+		}
+	}
+	if err := verify(records); err != nil {
+		return nil, fmt.Errorf("audit log %s was tampered with: %w", path, err)
+	}
+	if len(records) > 0 {
+		last := records[len(records)-1]
+		l.seq, l.head = last.Seq, last.Hash
+	}
+
+	l.f, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// reads every record of the log, along with the size of the file up to
+// the end of the last one. Only the last line can miss its newline, after
+// a crash, and it is left out.
+func (l *Log) read() ([]Record, int64, error) {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	records := make([]Record, 0)
+	var size int64
+	reader := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return records, size, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var r Record
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, 0, fmt.Errorf("corrupt record on line %d of audit log %s: %w", line, l.path, err)
+		}
+		records = append(records, r)
+		size += int64(len(data))
+	}
+}
+
+// checks that every record follows the one before it
+func verify(records []Record) error {
+	prev, seq := genesis, uint64(0)
+	for _, r := range records {
+		if r.Seq != seq+1 {
+			return fmt.Errorf("record %d follows record %d", r.Seq, seq)
+		}
+		if r.PrevHash != prev {
+			return fmt.Errorf("record %d does not follow the hash of record %d", r.Seq, seq)
+		}
+		if r.digest() != r.Hash {
+			return fmt.Errorf("record %d does not match its hash", r.Seq)
+		}
+		prev, seq = r.Hash, r.Seq
+	}
+	return nil
+}
+
+// Append chains r to the log and writes it to disk before returning
+func (l *Log) Append(r Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Seq = l.seq + 1
+	r.Time = r.Time.UTC()
+	r.PrevHash = l.head
+	r.Hash = r.digest()
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := l.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+
+	l.seq, l.head = r.Seq, r.Hash
+	return nil
+}
+
+// Filter selects records, empty fields match every record
+type Filter struct {
+	// After only matches records with a higher sequence number
+	After     uint64
+	Principal string
+	Method    string
+	Target    string
+
+	// Limit is the most records returned, the latest ones, all of them if zero
+	Limit int
+}
+
+func (f Filter) matches(r Record) bool {
+	return r.Seq > f.After &&
+		(f.Principal == "" || r.Principal == f.Principal) &&
+		(f.Method == "" || r.Method == f.Method) &&
+		(f.Target == "" || r.Target == f.Target)
+}
+
+// Query returns the records matching f, oldest first, along with the
+// hash and sequence number of the latest record. It reads the whole log
+// and reports records that break the chain as an error.
+func (l *Log) Query(f Filter) (records []Record, head string, seq uint64, err error) {
+	// appends wait so that the log is not read halfway through a record
+	l.mu.Lock()
+	head, seq = l.head, l.seq
+	all, _, err := l.read()
+	l.mu.Unlock()
+
+	if err != nil {
+		return nil, head, seq, err
+	}
+	if err := verify(all); err != nil {
+		return nil, head, seq, fmt.Errorf("audit log %s was tampered with: %w", l.path, err)
+	}
+
+	records = make([]Record, 0)
+	for _, r := range all {
+		if f.matches(r) {
+			records = append(records, r)
+		}
+	}
+	if f.Limit > 0 && len(records) > f.Limit {
+		records = records[len(records)-f.Limit:]
+	}
+	return records, head, seq, nil
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// ServerOption records the requests served that audited selects, which
+// returns the target of a request and whether it is audited. Requests are
+// recorded once answered along with their outcome, it must come before the
+// auth.ServerOption of the server to record their user.
+func (l *Log) ServerOption(audited func(method string, req interface{}) (string, bool)) grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(l.UnaryInterceptor(audited))
+}
+
+// UnaryInterceptor is the interceptor of ServerOption
+func (l *Log) UnaryInterceptor(audited func(method string, req interface{}) (string, bool)) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		target, ok := audited(info.FullMethod, req)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		// requests are audited before they are authenticated so that the
+		// ones denied are recorded too
+		ctx, user := auth.Identity(ctx)
+
+		start := time.Now()
+		res, err := handler(ctx, req)
+
+		r := Record{
+			Time:      start,
+			Principal: user(),
+			Method:    path.Base(info.FullMethod),
+			Target:    target,
+			RequestID: logging.RequestID(ctx),
+			Outcome:   status.Code(err).String(),
+		}
+		if err != nil {
+			r.Error = status.Convert(err).Message()
+		}
+		if p, ok := peer.FromContext(ctx); ok {
+			r.Peer = p.Addr.String()
+		}
+
+		if err := l.Append(r); err != nil {
+			logging.Logger("audit").ErrorContext(ctx, "failed to append to the audit log", "method", r.Method, "target", target, "error", err)
+		}
+		return res, err
+	}
+}
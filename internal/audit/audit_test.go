@@ -0,0 +1,169 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writes a log of three records at path and returns its lines
+func writeLog(t *testing.T, path string) [][]byte {
+	t.Helper()
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{"a", "b", "c"} {
+		if err := l.Append(Record{Time: time.Now(), Method: "AddStore", Target: target, Outcome: "OK"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.SplitAfter(data, []byte("\n"))[:3]
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines [][]byte) [][]byte
+		ok     bool
+		want   int
+	}{
+		{
+			name:   "intact",
+			tamper: func(lines [][]byte) [][]byte { return lines },
+			ok:     true,
+			want:   3,
+		},
+		{
+			name: "changed record",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte(`"target":"b"`), []byte(`"target":"x"`), 1)
+				return lines
+			},
+		},
+		{
+			name: "record removed from the middle",
+			tamper: func(lines [][]byte) [][]byte {
+				return [][]byte{lines[0], lines[2]}
+			},
+		},
+		{
+			name: "reordered records",
+			tamper: func(lines [][]byte) [][]byte {
+				return [][]byte{lines[0], lines[2], lines[1]}
+			},
+		},
+		{
+			name: "corrupt record in the middle",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = []byte("corrupt\n")
+				return lines
+			},
+		},
+		{
+			name: "torn last record",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[2] = lines[2][:len(lines[2])/2]
+				return lines
+			},
+			ok:   true,
+			want: 2,
+		},
+		{
+			// the chain cannot tell, the head hash has to be kept elsewhere
+			name: "records cut off the end",
+			tamper: func(lines [][]byte) [][]byte {
+				return lines[:2]
+			},
+			ok:   true,
+			want: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			lines := writeLog(t, path)
+			if err := os.WriteFile(path, bytes.Join(tt.tamper(lines), nil), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			l, err := Open(path)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Open() = %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			defer l.Close()
+
+			// new records follow the ones kept
+			if err := l.Append(Record{Time: time.Now(), Method: "RemoveStore", Target: "a", Outcome: "OK"}); err != nil {
+				t.Fatal(err)
+			}
+			records, head, seq, err := l.Query(Filter{})
+			if err != nil {
+				t.Fatalf("Query() = %v", err)
+			}
+			if len(records) != tt.want+1 || seq != uint64(tt.want+1) {
+				t.Fatalf("Query() = %d records up to %d, want %d", len(records), seq, tt.want+1)
+			}
+			if last := records[len(records)-1]; last.Method != "RemoveStore" || last.Hash != head {
+				t.Errorf("the last record is %s with hash %s, want RemoveStore with the head hash %s", last.Method, last.Hash, head)
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeLog(t, path)
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "every record", filter: Filter{}, want: []string{"a", "b", "c"}},
+		{name: "after", filter: Filter{After: 1}, want: []string{"b", "c"}},
+		{name: "target", filter: Filter{Target: "b"}, want: []string{"b"}},
+		{name: "method", filter: Filter{Method: "RemoveStore"}, want: []string{}},
+		{name: "latest", filter: Filter{Limit: 2}, want: []string{"b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, _, _, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(records))
+			for _, r := range records {
+				got = append(got, r.Target)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Query() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Query() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+// Package auth authenticates the callers of a gRPC server by a bearer
+// token or the certificate they present with mutual TLS, and authorizes
+// their requests with the roles of a rules file that is reloaded when it
+// changes.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/priyansh32/nebula/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// the rules file is checked for changes at most this often, on requests
+const reloadCheckInterval = time.Second
+
+// metadata key tokens are sent in, as "Bearer <token>"
+const authorizationKey = "authorization"
+
+// Access is what a request needs to be allowed
+type Access int
+
+const (
+	// Authenticated requests only need a known caller
+	Authenticated Access = iota
+
+	// Read and Write requests need a role that may read or write the key
+	// of the request
+	Read
+	Write
+
+	// Admin requests change the cluster, such as adding stores
+	Admin
+
+	// Cluster requests come from the members of the cluster, such as the
+	// heartbeats of stores, admins may send them too
+	Cluster
+)
+
+func (a Access) String() string {
+	switch a {
+	case Authenticated:
+		return "authenticated"
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case Admin:
+		return "admin"
+	case Cluster:
+		return "cluster"
+	}
+	return "unknown"
+}
+
+// Role is a set of permissions
+type Role struct {
+	// Admin and Cluster allow the requests of that access
+	Admin   bool `json:"admin"`
+	Cluster bool `json:"cluster"`
+
+	// Read and Write are the prefixes of the keys the role may read and
+	// write, an empty prefix matches every key
+	Read  []string `json:"read"`
+	Write []string `json:"write"`
+}
+
+// User is a caller known by a token or a certificate
+type User struct {
+	Name string `json:"name"`
+
+	// TokenSHA256 is the hex SHA-256 hash of the bearer token of the user
+	TokenSHA256 string `json:"token_sha256"`
+
+	// Certificate is the common name of the client certificate of the user
+	Certificate string `json:"certificate"`
+
+	Roles []string `json:"roles"`
+}
+
+// Rules is the content of a rules file
+type Rules struct {
+	Roles map[string]Role `json:"roles"`
+	Users []User          `json:"users"`
+}
+
+func (r *Rules) validate() error {
+	for _, u := range r.Users {
+		if u.Name == "" {
+			return errors.New("a user has no name")
+		}
+		if u.TokenSHA256 == "" && u.Certificate == "" {
+			return fmt.Errorf("user %s has neither a token nor a certificate", u.Name)
+		}
+		if u.TokenSHA256 != "" {
+			if hash, err := hex.DecodeString(u.TokenSHA256); err != nil || len(hash) != sha256.Size {
+				return fmt.Errorf("the token of user %s is not a hex SHA-256 hash", u.Name)
+			}
+		}
+		for _, role := range u.Roles {
+			if _, ok := r.Roles[role]; !ok {
+				return fmt.Errorf("user %s has unknown role %s", u.Name, role)
+			}
+		}
+	}
+	return nil
+}
+
+// returns the user a request comes from, by its token or else by the
+// certificate of its connection
+func (r *Rules) identify(ctx context.Context) (*User, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(authorizationKey)) > 0 {
+		token, found := strings.CutPrefix(md.Get(authorizationKey)[0], "Bearer ")
+		if !found {
+			return nil, status.Error(codes.Unauthenticated, "the authorization is not a bearer token")
+		}
+
+		hash := sha256.Sum256([]byte(token))
+		for i, u := range r.Users {
+			want, _ := hex.DecodeString(u.TokenSHA256)
+			if u.TokenSHA256 != "" && subtle.ConstantTimeCompare(hash[:], want) == 1 {
+				return &r.Users[i], nil
+			}
+		}
+		return nil, status.Error(codes.Unauthenticated, "unknown token")
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			name := info.State.VerifiedChains[0][0].Subject.CommonName
+			for i, u := range r.Users {
+				if u.Certificate != "" && u.Certificate == name {
+					return &r.Users[i], nil
+				}
+			}
+			return nil, status.Errorf(codes.Unauthenticated, "unknown certificate %s", name)
+		}
+	}
+
+	return nil, status.Error(codes.Unauthenticated, "requests need a token or a client certificate")
+}
+
+// reports whether user may make a request of the given access for key
+func (r *Rules) allows(u *User, access Access, key string) bool {
+	if access == Authenticated {
+		return true
+	}
+
+	for _, name := range u.Roles {
+		role := r.Roles[name]
+
+		switch access {
+		case Admin:
+			if role.Admin {
+				return true
+			}
+		case Cluster:
+			if role.Cluster || role.Admin {
+				return true
+			}
+		case Read:
+			if hasPrefix(key, role.Read) {
+				return true
+			}
+		case Write:
+			if hasPrefix(key, role.Write) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasPrefix(key string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorizer checks requests against the rules of a file
+type Authorizer struct {
+	path string
+
+	mu        sync.Mutex
+	rules     *Rules
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// Load reads the rules file at path
+func Load(path string) (*Authorizer, error) {
+	a := &Authorizer{path: path}
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+	a.lastCheck = time.Now()
+	return a, nil
+}
+
+// reads the rules file, a.mu must be held or a not shared yet
+func (a *Authorizer) load() error {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return err
+	}
+
+	rules := &Rules{}
+	if err := json.Unmarshal(data, rules); err != nil {
+		return fmt.Errorf("corrupt rules file %s: %w", a.path, err)
+	}
+	if err := rules.validate(); err != nil {
+		return fmt.Errorf("invalid rules file %s: %w", a.path, err)
+	}
+
+	a.rules, a.modTime = rules, info.ModTime()
+	return nil
+}
+
+// returns the current rules, reloading them if the file changed. The
+// previous rules are kept if the new file is invalid.
+func (a *Authorizer) current() *Rules {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(a.lastCheck) >= reloadCheckInterval {
+		a.lastCheck = now
+
+		info, err := os.Stat(a.path)
+		if err == nil && !info.ModTime().Equal(a.modTime) {
+			if err := a.load(); err != nil {
+				logging.Logger("auth").Error("failed to reload the auth rules, keeping the previous ones", "path", a.path, "error", err)
+			} else {
+				logging.Logger("auth").Info("reloaded the auth rules", "path", a.path)
+			}
+		}
+	}
+
+	return a.rules
+}
+
+type userContextKey struct{}
+
+// UserName returns the name of the user the request of ctx comes from,
+// empty if requests are not authenticated
+func UserName(ctx context.Context) string {
+	name, _ := ctx.Value(userContextKey{}).(string)
+	return name
+}
+
+type identityContextKey struct{}
+
+// Identity returns a context for a request that has not been authenticated
+// yet and a function that returns the name of the user it comes from once
+// it has been, even if it is then denied. It lets interceptors that run
+// before authentication learn who sent the request.
+func Identity(ctx context.Context) (context.Context, func() string) {
+	name := new(string)
+	return context.WithValue(ctx, identityContextKey{}, name), func() string { return *name }
+}
+
+// ServerOption authenticates every request served and checks the access
+// its method needs, as given by methods keyed by full method name. Methods
+// that are not listed need Admin. Unknown callers get Unauthenticated and
+// denied requests PermissionDenied.
+func (a *Authorizer) ServerOption(methods map[string]Access) grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(a.UnaryInterceptor(methods))
+}
+
+// UnaryInterceptor is the interceptor of ServerOption
+func (a *Authorizer) UnaryInterceptor(methods map[string]Access) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		rules := a.current()
+
+		user, err := rules.identify(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if name, ok := ctx.Value(identityContextKey{}).(*string); ok {
+			*name = user.Name
+		}
+
+		access, ok := methods[info.FullMethod]
+		if !ok {
+			access = Admin
+		}
+
+		key := requestKey(req)
+		if !rules.allows(user, access, key) {
+			if access == Read || access == Write {
+				return nil, status.Errorf(codes.PermissionDenied, "%s may not %s key %s", user.Name, access, key)
+			}
+			return nil, status.Errorf(codes.PermissionDenied, "%s needs %s access for %s", user.Name, access, info.FullMethod)
+		}
+
+		return handler(context.WithValue(ctx, userContextKey{}, user.Name), req)
+	}
+}
+
+// Authenticate returns the name of the user the request of ctx comes from
+func (a *Authorizer) Authenticate(ctx context.Context) (string, error) {
+	user, err := a.current().identify(ctx)
+	if err != nil {
+		return "", err
+	}
+	return user.Name, nil
+}
+
+// Allows reports whether the request of ctx comes from a known user that
+// may make requests of the given access for key
+func (a *Authorizer) Allows(ctx context.Context, access Access, key string) bool {
+	rules := a.current()
+	user, err := rules.identify(ctx)
+	return err == nil && rules.allows(user, access, key)
+}
+
+// WithToken returns ctx as if its request came with the given bearer
+// token, for requests that do not come through gRPC
+func WithToken(ctx context.Context, token string) context.Context {
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(authorizationKey, "Bearer "+token))
+}
+
+// returns the key of a request, empty if it has none
+func requestKey(req interface{}) string {
+	msg, ok := req.(proto.Message)
+	if !ok || msg == nil {
+		return ""
+	}
+
+	r := msg.ProtoReflect()
+	field := r.Descriptor().Fields().ByName("key")
+	if field == nil || field.Kind() != protoreflect.StringKind || field.IsList() {
+		return ""
+	}
+	return r.Get(field).String()
+}
+
+// Forward passes the token of the request of ctx on to the requests sent
+// on its behalf, such as requests forwarded to the raft leader, so that
+// the server they are sent to authenticates the caller itself. It reports
+// false for a request authenticated by its client certificate, which
+// cannot be passed on.
+func Forward(ctx context.Context) (context.Context, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get(authorizationKey)) == 0 {
+		return ctx, UserName(ctx) == ""
+	}
+	return metadata.AppendToOutgoingContext(ctx, authorizationKey, md.Get(authorizationKey)[0]), true
+}
+
+// token sends a bearer token with every request
+type token string
+
+func (t token) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{authorizationKey: "Bearer " + string(t)}, nil
+}
+
+// tokens are also sent over insecure connections, which are meant for
+// trusted networks
+func (t token) RequireTransportSecurity() bool {
+	return false
+}
+
+// TokenDialOption sends the given bearer token with every request, it does
+// nothing if the token is empty
+func TokenDialOption(t string) grpc.DialOption {
+	if t == "" {
+		return grpc.EmptyDialOption{}
+	}
+	return grpc.WithPerRPCCredentials(token(t))
+}
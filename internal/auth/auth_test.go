@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// returns the hex SHA-256 hash of token, as rules files hold it
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// writes rules to path and returns it
+func writeRules(t *testing.T, path string, rules string) string {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(rules), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// rules of an admin, a store and an app that reads everything and writes
+// the keys under app/, each user with its name as token
+var testRules = fmt.Sprintf(`{
+	"roles": {
+		"admin": {"admin": true},
+		"store": {"cluster": true},
+		"app": {"read": [""], "write": ["app/"]}
+	},
+	"users": [
+		{"name": "root", "token_sha256": %q, "roles": ["admin"]},
+		{"name": "alpha", "token_sha256": %q, "roles": ["store"]},
+		{"name": "app", "token_sha256": %q, "roles": ["app"]}
+	]
+}`, tokenHash("root"), tokenHash("alpha"), tokenHash("app"))
+
+func TestForward(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		ok        bool
+		wantToken string
+	}{
+		{
+			name: "not authenticated",
+			ctx:  context.Background(),
+			ok:   true,
+		},
+		{
+			name:      "token",
+			ctx:       context.WithValue(WithToken(context.Background(), "secret"), userContextKey{}, "app"),
+			ok:        true,
+			wantToken: "Bearer secret",
+		},
+		{
+			name: "client certificate",
+			ctx:  context.WithValue(context.Background(), userContextKey{}, "app"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, ok := Forward(tt.ctx)
+			if ok != tt.ok {
+				t.Fatalf("Forward() = %v, want %v", ok, tt.ok)
+			}
+
+			md, _ := metadata.FromOutgoingContext(ctx)
+			got := ""
+			if values := md.Get(authorizationKey); len(values) > 0 {
+				got = values[0]
+			}
+			if got != tt.wantToken {
+				t.Errorf("forwarded authorization %q, want %q", got, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		ok    bool
+	}{
+		{name: "valid", rules: testRules, ok: true},
+		{name: "empty", rules: `{}`, ok: true},
+		{name: "not json", rules: `{"roles": `},
+		{name: "user without a name", rules: fmt.Sprintf(`{"users": [{"token_sha256": %q}]}`, tokenHash("a"))},
+		{name: "user without a token or a certificate", rules: `{"users": [{"name": "a"}]}`},
+		{name: "token that is not a hash", rules: `{"users": [{"name": "a", "token_sha256": "secret"}]}`},
+		{name: "unknown role", rules: fmt.Sprintf(`{"users": [{"name": "a", "token_sha256": %q, "roles": ["missing"]}]}`, tokenHash("a"))},
+		{name: "certificate user", rules: `{"roles": {"r": {}}, "users": [{"name": "a", "certificate": "a.example.com", "roles": ["r"]}]}`, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeRules(t, filepath.Join(t.TempDir(), "rules.json"), tt.rules)
+			if _, err := Load(path); (err == nil) != tt.ok {
+				t.Errorf("Load() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAllows(t *testing.T) {
+	a, err := Load(writeRules(t, filepath.Join(t.TempDir(), "rules.json"), testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		access Access
+		key    string
+		want   bool
+	}{
+		{name: "admin", token: "root", access: Admin, want: true},
+		{name: "admins send cluster requests", token: "root", access: Cluster, want: true},
+		{name: "admins have no keys", token: "root", access: Read, key: "app/a"},
+		{name: "cluster", token: "alpha", access: Cluster, want: true},
+		{name: "cluster is not admin", token: "alpha", access: Admin},
+		{name: "empty prefix matches every key", token: "app", access: Read, key: "other", want: true},
+		{name: "write under the prefix", token: "app", access: Write, key: "app/a", want: true},
+		{name: "write outside the prefix", token: "app", access: Write, key: "other"},
+		{name: "prefix is not a directory", token: "app", access: Write, key: "app"},
+		{name: "authenticated", token: "app", access: Authenticated, want: true},
+		{name: "unknown token", token: "nobody", access: Authenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.Allows(WithToken(context.Background(), tt.token), tt.access, tt.key); got != tt.want {
+				t.Errorf("Allows(%s, %s, %q) = %v, want %v", tt.token, tt.access, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := writeRules(t, filepath.Join(t.TempDir(), "rules.json"), testRules)
+	a, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added := fmt.Sprintf(`{"roles": {"store": {"cluster": true}}, "users": [{"name": "beta", "token_sha256": %q, "roles": ["store"]}]}`, tokenHash("beta"))
+
+	tests := []struct {
+		name  string
+		rules string
+		beta  bool
+		alpha bool
+	}{
+		{name: "changed rules", rules: added, beta: true},
+		{name: "invalid rules keep the previous ones", rules: `{"users": [{"name": "broken"}]}`, beta: true},
+		{name: "rules put back", rules: testRules, alpha: true},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeRules(t, path, tt.rules)
+			// the file may change within the resolution of its time
+			modTime := time.Now().Add(time.Duration(i+1) * time.Second)
+			if err := os.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+			a.mu.Lock()
+			a.lastCheck = time.Time{}
+			a.mu.Unlock()
+
+			if got := a.Allows(WithToken(context.Background(), "beta"), Cluster, ""); got != tt.beta {
+				t.Errorf("beta allowed: %v, want %v", got, tt.beta)
+			}
+			if got := a.Allows(WithToken(context.Background(), "alpha"), Cluster, ""); got != tt.alpha {
+				t.Errorf("alpha allowed: %v, want %v", got, tt.alpha)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package coordinator
+
+import (
+	"context"
+	"time"
+
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+	"github.com/priyansh32/nebula/internal/logging"
+)
+
+// AntiEntropyConfig controls how often replicas compare their keys in the
+// background, which repairs keys that are never read
+type AntiEntropyConfig struct {
+	// Interval between rounds, anti-entropy is disabled if it is not positive
+	Interval time.Duration
+
+	// Timeout bounds the sync between two stores in a round
+	Timeout time.Duration
+}
+
+func DefaultAntiEntropyConfig() AntiEntropyConfig {
+	return AntiEntropyConfig{
+		Interval: 30 * time.Second,
+		Timeout:  10 * time.Second,
+	}
+}
+
+// StartAntiEntropy has the replicas of every range of the ring sync with
+// each other every cfg.Interval until ctx is done. The first live replica
+// of a range syncs it with each of the others, stores compare merkle trees
+// of the range and only exchange the keys that differ.
+func (c *Coordinator) StartAntiEntropy(ctx context.Context, cfg AntiEntropyConfig) {
+	if cfg.Interval <= 0 || c.replication.Replicas < 2 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// with raft only the leader schedules rounds, so that
+				// replicas are not synced once per coordinator
+				if c.isLeader() {
+					c.syncReplicas(ctx, cfg.Timeout)
+				}
+			}
+		}
+	}()
+}
+
+// a pair of replicas and the ranges they share
+type syncPair struct {
+	from   *StoreClient
+	to     *StoreClient
+	ranges []*pb_store.KeyRange
+}
+
+// runs a round of anti-entropy, syncing every pair of replicas once
+func (c *Coordinator) syncReplicas(ctx context.Context, timeout time.Duration) {
+	c.mu.RLock()
+	if len(c.storeClients) == 0 {
+		c.mu.RUnlock()
+		return
+	}
+	ranges := c.hashRing.Ranges(c.replication.Replicas)
+	c.mu.RUnlock()
+
+	pairs := make(map[[2]*StoreClient]*syncPair)
+	order := make([]*syncPair, 0)
+
+	for _, r := range ranges {
+		live := make([]*StoreClient, 0, len(r.Stores))
+		for _, s := range r.Stores {
+			if s.State() == StoreUp {
+				live = append(live, s)
+			}
+		}
+
+		if len(live) < 2 {
+			continue
+		}
+
+		for _, s := range live[1:] {
+			key := [2]*StoreClient{live[0], s}
+			p, ok := pairs[key]
+			if !ok {
+				p = &syncPair{from: live[0], to: s}
+				pairs[key] = p
+				order = append(order, p)
+			}
+			p.ranges = append(p.ranges, &pb_store.KeyRange{Start: r.Start, End: r.End})
+		}
+	}
+
+	for _, p := range order {
+		syncCtx, cancel := context.WithTimeout(ctx, timeout)
+		res, err := p.from.client.SyncRanges(syncCtx, &pb_store.SyncRangesRequest{
+			Peer:   p.to.address,
+			Ranges: p.ranges,
+		})
+		cancel()
+
+		if err != nil {
+			logging.Logger("antientropy").Warn("failed to sync stores", "store", p.from.name, "peer", p.to.name, "error", err)
+			continue
+		}
+		if res.Pulled > 0 || res.Pushed > 0 {
+			logging.Logger("antientropy").Info("synced stores", "store", p.from.name, "peer", p.to.name, "pulled", res.Pulled, "pushed", res.Pushed)
+		}
+	}
+}
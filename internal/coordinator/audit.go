@@ -0,0 +1,127 @@
+package coordinator
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	"github.com/priyansh32/nebula/internal/audit"
+	"github.com/priyansh32/nebula/internal/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AuditConfig controls the audit log of the coordinator, which records who
+// changed the membership of the cluster and, optionally, which keys
+type AuditConfig struct {
+	// Path, if set, is the file the audit log is appended to. Nothing is
+	// audited if it is empty.
+	Path string
+
+	// Prefixes are the prefixes of the keys whose writes and deletes are
+	// audited, an empty prefix audits every key
+	Prefixes []string
+}
+
+// returns the store, coordinator or key the request of method changes and
+// whether it is audited. Membership changes are always audited, writes of
+// keys only if they match the configured prefixes.
+func (cfg AuditConfig) audited(method string, req interface{}) (string, bool) {
+	switch r := req.(type) {
+	case *pb_coordinator.AddStoreRequest:
+		return r.Name, true
+	case *pb_coordinator.RemoveStoreRequest:
+		return r.Name, true
+	case *pb_coordinator.RegisterStoreRequest:
+		return r.Name, true
+	case *pb_coordinator.DeregisterStoreRequest:
+		return r.Name, true
+	case *pb_coordinator.JoinClusterRequest:
+		return r.NodeId, true
+	case *pb_coordinator.PutRequest:
+		return r.Key, cfg.auditsKey(r.Key)
+	case *pb_coordinator.DeleteRequest:
+		return r.Key, cfg.auditsKey(r.Key)
+	}
+	return "", false
+}
+
+func (cfg AuditConfig) auditsKey(key string) bool {
+	for _, p := range cfg.Prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// principal of the membership changes the coordinator makes by itself
+const systemPrincipal = "system"
+
+// returns the audit record of a membership change the coordinator made by
+// itself rather than on request, such as removing a store whose lease expired
+func systemRecord(method string, store string, err error) audit.Record {
+	r := audit.Record{
+		Time:      time.Now(),
+		Principal: systemPrincipal,
+		Method:    method,
+		Target:    store,
+		Outcome:   status.Code(err).String(),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// appends records of changes made by the coordinator itself to the audit
+// log, if it is enabled. c.mu must not be held, appends wait for the disk.
+func (c *Coordinator) auditSystem(records ...audit.Record) {
+	if c.auditLog == nil {
+		return
+	}
+	for _, r := range records {
+		if err := c.auditLog.Append(r); err != nil {
+			logging.Logger("audit").Error("failed to append to the audit log", "error", err)
+		}
+	}
+}
+
+// AuditLog returns the records of the audit log that match the request,
+// oldest first, along with the hash of the latest record so that it can be
+// kept elsewhere to later check that the log was not rewritten
+func (c *Coordinator) AuditLog(ctx context.Context, in *pb_coordinator.AuditLogRequest) (*pb_coordinator.AuditLogResponse, error) {
+	if c.auditLog == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the audit log is not enabled")
+	}
+
+	records, head, seq, err := c.auditLog.Query(audit.Filter{
+		After:     in.AfterSeq,
+		Principal: in.Principal,
+		Method:    in.Method,
+		Target:    in.Target,
+		Limit:     int(in.Limit),
+	})
+	if err != nil {
+		return nil, status.Error(codes.DataLoss, err.Error())
+	}
+
+	res := &pb_coordinator.AuditLogResponse{HeadHash: head, HeadSeq: seq}
+	for _, r := range records {
+		res.Records = append(res.Records, &pb_coordinator.AuditRecord{
+			Seq:         r.Seq,
+			TimestampMs: r.Time.UnixMilli(),
+			Principal:   r.Principal,
+			Peer:        r.Peer,
+			Method:      r.Method,
+			Target:      r.Target,
+			RequestId:   r.RequestID,
+			Outcome:     r.Outcome,
+			Error:       r.Error,
+			PrevHash:    r.PrevHash,
+			Hash:        r.Hash,
+		})
+	}
+	return res, nil
+}
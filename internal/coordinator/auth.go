@@ -0,0 +1,58 @@
+package coordinator
+
+import (
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	"github.com/priyansh32/nebula/internal/auth"
+)
+
+// AuthConfig controls who may send which requests to the coordinator
+type AuthConfig struct {
+	// RulesFile, if set, is the file of the roles and users allowed to
+	// send requests, see auth.Rules. Requests are not authenticated if it
+	// is empty.
+	RulesFile string
+
+	// Token is the bearer token the coordinator sends to stores and when
+	// it joins a raft cluster, if they authenticate requests. It needs a
+	// cluster role.
+	Token string
+}
+
+// the access every request to the coordinator needs, requests that are
+// not listed need admin access
+var methodAccess = map[string]auth.Access{
+	apiMethod("AddStore"):    auth.Admin,
+	apiMethod("RemoveStore"): auth.Admin,
+	apiMethod("AuditLog"):    auth.Admin,
+
+	// the slow log and the hot keys reveal the keys of other users
+	apiMethod("SlowLog"): auth.Admin,
+	apiMethod("HotKeys"): auth.Admin,
+
+	apiMethod("Get"):       auth.Read,
+	apiMethod("LocateKey"): auth.Read,
+	apiMethod("Put"):       auth.Write,
+	apiMethod("Delete"):    auth.Write,
+
+	// scans have no key, so they need to read every key
+	apiMethod("Scan"): auth.Read,
+
+	// stores may only renew and remove themselves, as the user they
+	// registered as
+	apiMethod("RegisterStore"):   auth.Cluster,
+	apiMethod("Heartbeat"):       auth.Cluster,
+	apiMethod("DeregisterStore"): auth.Cluster,
+	apiMethod("JoinCluster"):     auth.Cluster,
+
+	apiMethod("GetRing"):         auth.Authenticated,
+	apiMethod("WatchRing"):       auth.Authenticated,
+	apiMethod("ListStores"):      auth.Authenticated,
+	apiMethod("DescribeRing"):    auth.Authenticated,
+	apiMethod("ClusterStats"):    auth.Authenticated,
+	apiMethod("CircuitBreakers"): auth.Authenticated,
+}
+
+// returns the full name of a method of the coordinator API
+func apiMethod(name string) string {
+	return "/" + pb_coordinator.CoordinatorAPI_ServiceDesc.ServiceName + "/" + name
+}
@@ -5,33 +5,160 @@ import (
 	"errors"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
 	pb_store "github.com/priyansh32/nebula/internal/api/store"
+	"github.com/priyansh32/nebula/internal/audit"
+	"github.com/priyansh32/nebula/internal/auth"
+	"github.com/priyansh32/nebula/internal/encryption"
+	"github.com/priyansh32/nebula/internal/gossip"
+	"github.com/priyansh32/nebula/internal/hlc"
+	"github.com/priyansh32/nebula/internal/logging"
+	"github.com/priyansh32/nebula/internal/metrics"
+	"github.com/priyansh32/nebula/internal/slowlog"
+	"github.com/priyansh32/nebula/internal/tlsconfig"
+	"github.com/priyansh32/nebula/internal/tracing"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// requests still running this long after the coordinator is interrupted are cancelled
+const shutdownTimeout = 5 * time.Second
+
 type StoreClient struct {
 	name     string
+	address  string
 	conn     *grpc.ClientConn
 	client   pb_store.KeyValueStoreClient
 	nodeKeys []uint64
+	health   health
+	lease    lease
+	breaker  breaker
 }
 
-// Returns a new store client with the given connection and name
-func NewStoreClient(conn *grpc.ClientConn, name string) *StoreClient {
+// Returns a new store client with the given connection, name and address
+func NewStoreClient(conn *grpc.ClientConn, name string, address string) *StoreClient {
 	return &StoreClient{
-		name:   name,
-		conn:   conn,
-		client: pb_store.NewKeyValueStoreClient(conn),
+		name:    name,
+		address: address,
+		conn:    conn,
+		client:  pb_store.NewKeyValueStoreClient(conn),
+	}
+}
+
+// Config holds the optional tunables of a coordinator process
+type Config struct {
+	Health      HealthConfig
+	Lease       LeaseConfig
+	Handoff     HandoffConfig
+	Replication ReplicationConfig
+	AntiEntropy AntiEntropyConfig
+	Resilience  ResilienceConfig
+	Hedge       HedgeConfig
+	NearCache   NearCacheConfig
+
+	// StoreTimeout bounds every request to a store, on top of the deadline
+	// of the client request it serves. It is not bounded if zero.
+	StoreTimeout time.Duration
+
+	// Gossip, if set, makes the coordinator join the gossip cluster and
+	// keep the ring in sync with the stores disseminated there. It needs
+	// a key when TLS or Auth is set.
+	Gossip *gossip.Config
+
+	// Raft, if set, replicates the ring among several coordinators
+	Raft *RaftConfig
+
+	// StateFile, if set, persists the ring so that it survives restarts
+	// it is a lighter alternative to Raft and cannot be used with it
+	StateFile string
+
+	// MetricsAddress, if set, is the HTTP address prometheus metrics are
+	// served on at /metrics
+	MetricsAddress string
+
+	// Tracing selects where the spans of the coordinator are exported to
+	Tracing tracing.Config
+
+	// Logging controls the logs of the coordinator
+	Logging logging.Config
+
+	// SlowLog selects the requests kept in the slow log
+	SlowLog slowlog.Config
+
+	// TLS selects the certificates the coordinator serves and dials
+	// stores and other coordinators with, connections are insecure if unset
+	TLS tlsconfig.Config
+
+	// Auth controls who may send which requests
+	Auth AuthConfig
+
+	// Audit selects the requests recorded in the audit log
+	Audit AuditConfig
+
+	// Encryption selects the keys the hint file is encrypted with, as it
+	// holds the writes of stores that are down
+	Encryption encryption.Config
+
+	// Redis selects where the coordinator serves the redis protocol
+	Redis RedisConfig
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Health:       DefaultHealthConfig(),
+		Lease:        DefaultLeaseConfig(),
+		Handoff:      DefaultHandoffConfig(),
+		Replication:  DefaultReplicationConfig(),
+		AntiEntropy:  DefaultAntiEntropyConfig(),
+		Resilience:   DefaultResilienceConfig(),
+		Hedge:        DefaultHedgeConfig(),
+		NearCache:    DefaultNearCacheConfig(),
+		StoreTimeout: 2 * time.Second,
+		Tracing:      tracing.DefaultConfig(),
+		Logging:      logging.DefaultConfig(),
+		SlowLog:      slowlog.DefaultConfig(),
 	}
 }
 
 type Coordinator struct {
 	ctx          context.Context
+	mu           sync.RWMutex
 	hashRing     *HashRing
 	storeClients map[string]*StoreClient
+	gossipStores map[string]bool
+	gossiping    bool
+	replicator   *replicator
+	stateFile    string
+	hints        *hintQueue
+	replication  ReplicationConfig
+	repairs      repairCounters
+	storeTimeout time.Duration
+	resilience   ResilienceConfig
+	hedge        HedgeConfig
+	hedging      hedgeCounters
+	latencies    latencies
+	nearCache    *nearCache
+	clock        hlc.Clock
+	ringVersion  uint64
+	ringWatch    chan struct{}
+	storeMetrics *metrics.ClientMetrics
+	slowlog      *slowlog.Log
+	creds        *tlsconfig.Credentials
+	authToken    string
+	auditLog     *audit.Log
+	authorizer   *auth.Authorizer
+	interceptors []grpc.UnaryServerInterceptor
+	keys         *encryption.Keyring
+	leaseTTL     time.Duration
+	subsMu       sync.Mutex
+	subscribers  []chan MembershipEvent
 	pb_coordinator.UnimplementedCoordinatorAPIServer
 }
 
@@ -46,44 +173,74 @@ func NewCoordinator(replicationFactor int) (*Coordinator, error) {
 		return nil, errors.New("replication factor must be less than 1024")
 	}
 
+	slow, _ := slowlog.New(slowlog.DefaultConfig())
+
 	return &Coordinator{
 		ctx:          context.Background(),
 		hashRing:     NewHashRing(replicationFactor),
 		storeClients: make(map[string]*StoreClient),
+		gossipStores: make(map[string]bool),
+		hints:        newHintQueue(DefaultHandoffConfig().MaxHints),
+		replication:  DefaultReplicationConfig(),
+		resilience:   DefaultResilienceConfig(),
+		hedge:        DefaultHedgeConfig(),
+		ringWatch:    make(chan struct{}),
+		slowlog:      slow,
 	}, nil
 }
 
 func (c *Coordinator) Get(ctx context.Context, in *pb_coordinator.GetRequest) (*pb_coordinator.GetResponse, error) {
-	key := in.Key
+	return c.get(ctx, in.Key, true)
+}
 
-	store, err := c.hashRing.GetStore(key)
-	if err != nil {
-		return nil, err
+// reads a key from its replicas, or from the near cache if cached is set
+// and the key is in it
+func (c *Coordinator) get(ctx context.Context, key string, cached bool) (*pb_coordinator.GetResponse, error) {
+	if cached {
+		if value, expires, ok := c.nearCache.get(key, time.Now()); ok {
+			return &pb_coordinator.GetResponse{Status: pb_coordinator.StatusType_OK, Value: value, ExpiresAtMs: expires}, nil
+		}
 	}
 
-	res, err := store.client.Get(c.ctx, &pb_store.GetRequest{Key: key})
+	res, err := c.readReplicas(ctx, key)
 	if err != nil {
 		return nil, err
 	}
+	c.clock.Observe(res.Version)
+
+	if res.Status == pb_store.StatusType_OK {
+		c.nearCache.fill(key, res.Value, res.Version, res.ExpiresAtMs, time.Now())
+	}
 
 	return &pb_coordinator.GetResponse{
-		Status: pb_coordinator.StatusType(res.Status),
-		Value:  res.Value,
+		Status:      pb_coordinator.StatusType(res.Status),
+		Value:       res.Value,
+		ExpiresAtMs: res.ExpiresAtMs,
 	}, nil
 }
 
-// Put puts the value for the given key in the appropriate store
+// Put puts the value for the given key in the appropriate store, it
+// expires after in.TtlMs milliseconds if set
 func (c *Coordinator) Put(ctx context.Context, in *pb_coordinator.PutRequest) (*pb_coordinator.PutResponse, error) {
 
 	key := in.Key
 	value := in.Value
 
-	store, err := c.hashRing.GetStore(key)
-	if err != nil {
-		return nil, err
+	if in.TtlMs < 0 {
+		return nil, status.Error(codes.InvalidArgument, "ttl must not be negative")
+	}
+	var expires int64
+	if in.TtlMs > 0 {
+		expires = time.Now().UnixMilli() + in.TtlMs
 	}
 
-	_, err = store.client.Put(c.ctx, &pb_store.PutRequest{Key: key, Value: value})
+	// the latest write wins when replicas disagree
+	err := c.writeVersioned(ctx, key, func(version int64) (hint, func(context.Context, *StoreClient) (int64, error)) {
+		return hint{Key: key, Value: value, Version: version, ExpiresAt: expires}, func(ctx context.Context, store *StoreClient) (int64, error) {
+			res, err := store.client.Put(ctx, &pb_store.PutRequest{Key: key, Value: value, Version: version, ExpiresAtMs: expires})
+			return res.GetVersion(), err
+		}
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -98,89 +255,357 @@ func (c *Coordinator) Delete(ctx context.Context, in *pb_coordinator.DeleteReque
 
 	key := in.Key
 
-	store, err := c.hashRing.GetStore(key)
+	err := c.writeVersioned(ctx, key, func(version int64) (hint, func(context.Context, *StoreClient) (int64, error)) {
+		return hint{Key: key, Delete: true, Version: version}, func(ctx context.Context, store *StoreClient) (int64, error) {
+			res, err := store.client.Delete(ctx, &pb_store.DeleteRequest{Key: key, Version: version})
+			return res.GetVersion(), err
+		}
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = store.client.Delete(c.ctx, &pb_store.DeleteRequest{Key: key})
+	return &pb_coordinator.DeleteResponse{
+		Status: pb_coordinator.StatusType_OK,
+	}, nil
+}
+
+// writes a key with a version from the clock. Stores answer with the
+// version they kept when they hold a newer one, written by a coordinator
+// whose clock runs ahead, and the write is then made once more with a
+// version past it.
+func (c *Coordinator) writeVersioned(ctx context.Context, key string, prepare func(version int64) (hint, func(context.Context, *StoreClient) (int64, error))) error {
+	for attempt := 0; ; attempt++ {
+		version := c.clock.Now()
+		c.nearCache.invalidate(key, version, time.Now())
+
+		var mu sync.Mutex
+		var newer int64
+		h, write := prepare(version)
+		err := c.writeReplicas(ctx, key, h, func(ctx context.Context, store *StoreClient) error {
+			kept, err := write(ctx, store)
+			if err == nil && kept > version {
+				mu.Lock()
+				newer = max(newer, kept)
+				mu.Unlock()
+				c.clock.Observe(kept)
+			}
+			return err
+		})
+
+		mu.Lock()
+		stale := newer > version
+		mu.Unlock()
+		if err != nil || !stale || attempt == 1 {
+			return err
+		}
+	}
+}
+
+// cause of the contexts of store requests that ran out of the store
+// timeout, rather than of the deadline of the request they are made for
+var errStoreTimeout = errors.New("store timeout")
+
+// returns the context for a request to a store made on behalf of ctx
+func (c *Coordinator) storeCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.storeTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, c.storeTimeout, errStoreTimeout)
+}
+
+// adds nodes of a store to the hash ring
+func (c *Coordinator) AddStore(ctx context.Context, in *pb_coordinator.AddStoreRequest) (*pb_coordinator.AddStoreResponse, error) {
+	leader, fwd, release, err := c.forwardTo(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer release()
+	if leader != nil {
+		return leader.AddStore(fwd, in)
+	}
 
-	return &pb_coordinator.DeleteResponse{
+	err = c.changeMembership(membershipChange{
+		Op:        opAddStore,
+		Name:      in.Name,
+		Address:   in.Address,
+		Positions: c.positionsFor(in.Name, in.Weight),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb_coordinator.AddStoreResponse{
 		Status: pb_coordinator.StatusType_OK,
 	}, nil
 }
 
-// adds nodes of a store to the hash ring
-func (c *Coordinator) AddStore(ctx context.Context, in *pb_coordinator.AddStoreRequest) (*pb_coordinator.AddStoreResponse, error) {
+// removes nodes of a store from the hash ring
+func (c *Coordinator) RemoveStore(ctx context.Context, in *pb_coordinator.RemoveStoreRequest) (*pb_coordinator.RemoveStoreResponse, error) {
+	leader, fwd, release, err := c.forwardTo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if leader != nil {
+		return leader.RemoveStore(fwd, in)
+	}
+
+	if err := c.changeMembership(membershipChange{Op: opRemoveStore, Name: in.Name}); err != nil {
+		return nil, err
+	}
+
+	return &pb_coordinator.RemoveStoreResponse{
+		Status: pb_coordinator.StatusType_OK,
+	}, nil
+}
 
-	address := in.Address
-	name := in.Name
+// connects to a store and adds it to the ring, c.mu must be held
+// the store is placed at the given positions or at random ones if there are none
+func (c *Coordinator) addStore(name string, address string, positions []uint64) (*StoreClient, error) {
 
 	// check if the store already exists with the given name
 	if _, ok := c.storeClients[name]; ok {
 		return nil, errors.New("duplicate store name")
 	}
 
+	opts := []grpc.DialOption{c.creds.DialOption(), auth.TokenDialOption(c.authToken), tracing.DialOption(), logging.DialOption()}
+	if c.storeMetrics != nil {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(c.storeMetrics.UnaryInterceptor(name)))
+	}
+
 	// address is of the form <host>:<port>
-	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial(address, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	storeClient := NewStoreClient(conn, name)
+	storeClient := NewStoreClient(conn, name, address)
+	storeClient.client = &resilientClient{
+		KeyValueStoreClient: storeClient.client,
+		name:                name,
+		breaker:             &storeClient.breaker,
+		cfg:                 c.resilience,
+	}
 	c.storeClients[name] = storeClient
-	c.hashRing.AddStoreNodes(storeClient)
-
-	return &pb_coordinator.AddStoreResponse{
-		Status: pb_coordinator.StatusType_OK,
-	}, nil
+	if len(positions) > 0 {
+		c.hashRing.AddStoreNodesAt(storeClient, positions)
+	} else {
+		c.hashRing.AddStoreNodes(storeClient)
+	}
+	c.ringChanged()
+
+	c.emit(MembershipEvent{
+		Type:     StoreJoined,
+		Store:    name,
+		Address:  address,
+		Previous: StoreDown,
+		Current:  StoreUp,
+		Time:     time.Now(),
+	})
+
+	return storeClient, nil
 }
 
-// removes nodes of a store from the hash ring
-func (c *Coordinator) RemoveStore(ctx context.Context, in *pb_coordinator.RemoveStoreRequest) (*pb_coordinator.RemoveStoreResponse, error) {
-	s := c.storeClients[in.Name]
+// disconnects from a store and removes it from the ring, c.mu must be held
+func (c *Coordinator) removeStore(name string) error {
+	s := c.storeClients[name]
 
 	if s == nil {
-		return nil, errors.New("store " + in.Name + " does not exist")
+		return errors.New("store " + name + " does not exist")
 	}
 
 	// remove the store from the store clients
-	delete(c.storeClients, in.Name)
+	delete(c.storeClients, name)
+	delete(c.gossipStores, name)
 
 	// close the connection
 	s.conn.Close()
-
-	// remove the nodes from the hash ring
-	for _, key := range s.nodeKeys {
-		delete(c.hashRing.nodes, key)
-		c.hashRing.sortedKeys = removeSorted(c.hashRing.sortedKeys, key)
+	if c.storeMetrics != nil {
+		c.storeMetrics.Forget(name)
 	}
 
-	return &pb_coordinator.RemoveStoreResponse{
-		Status: pb_coordinator.StatusType_OK,
-	}, nil
+	// remove the nodes from the hash ring
+	c.hashRing.RemoveStoreNodes(s)
+	c.ringChanged()
+
+	c.emit(MembershipEvent{
+		Type:     StoreLeft,
+		Store:    s.name,
+		Address:  s.address,
+		Previous: s.State(),
+		Current:  StoreDown,
+		Time:     time.Now(),
+	})
+
+	return nil
 }
 
-func InitCoordinator(port string, rf int) {
+func InitCoordinator(port string, rf int, cfg Config) {
+	if cfg.Logging.Component == "" {
+		cfg.Logging.Component = "coordinator"
+	}
+	if err := logging.Setup(cfg.Logging); err != nil {
+		log.Fatalf("Failed to set up logging: %s", err)
+	}
+
 	cdr, err := NewCoordinator(rf)
 	if err != nil {
 		log.Fatalf("Failed to create coordinator: %s", err)
 	}
 
+	// background work stops once the process is interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cdr.ctx = ctx
+
+	if cfg.Raft != nil && cfg.StateFile != "" {
+		log.Fatalf("A state file cannot be used together with raft")
+	}
+
+	// anyone reaching the gossip address could otherwise change the ring
+	if cfg.Gossip != nil && cfg.Gossip.Key == "" && (cfg.TLS.Enabled() || cfg.Auth.RulesFile != "") {
+		log.Fatalf("A gossip key is required to gossip when TLS or auth rules are set")
+	}
+
+	if err := cfg.Replication.validate(); err != nil {
+		log.Fatalf("Invalid replication config: %s", err)
+	}
+	cdr.replication = cfg.Replication
+	cdr.storeTimeout = cfg.StoreTimeout
+
+	if err := cfg.Resilience.validate(); err != nil {
+		log.Fatalf("Invalid resilience config: %s", err)
+	}
+	cdr.resilience = cfg.Resilience
+
+	if err := cfg.Hedge.validate(); err != nil {
+		log.Fatalf("Invalid hedging config: %s", err)
+	}
+	cdr.hedge = cfg.Hedge
+
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = "nebula-coordinator"
+	}
+	stopTracing, err := tracing.Start(cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to start tracing: %s", err)
+	}
+
+	cdr.slowlog, err = slowlog.New(cfg.SlowLog)
+	if err != nil {
+		log.Fatalf("Invalid slow log config: %s", err)
+	}
+
+	cdr.creds, err = tlsconfig.Load(cfg.TLS)
+	if err != nil {
+		log.Fatalf("Failed to load TLS certificates: %s", err)
+	}
+	creds, err := cdr.creds.ServerOption()
+	if err != nil {
+		log.Fatalf("Failed to set up TLS: %s", err)
+	}
+
+	opts := []grpc.ServerOption{creds, tracing.ServerOption(), logging.ServerOption("requests")}
+	if cfg.MetricsAddress != "" {
+		// first so that requests that are denied or fail are counted too
+		reg := metrics.NewRegistry()
+		cdr.registerMetrics(reg)
+		cdr.interceptors = append(cdr.interceptors, metrics.NewServerMetrics(reg, "coordinator").UnaryInterceptor())
+		if err := metrics.Serve(cfg.MetricsAddress, reg); err != nil {
+			log.Fatalf("Failed to serve metrics: %s", err)
+		}
+	}
+	if cfg.Audit.Path != "" {
+		// before auth so that denied requests are audited too
+		cdr.auditLog, err = audit.Open(cfg.Audit.Path)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %s", err)
+		}
+		cdr.interceptors = append(cdr.interceptors, cdr.auditLog.UnaryInterceptor(cfg.Audit.audited))
+	}
+	if cfg.Auth.RulesFile != "" {
+		cdr.authorizer, err = auth.Load(cfg.Auth.RulesFile)
+		if err != nil {
+			log.Fatalf("Failed to load auth rules: %s", err)
+		}
+		cdr.interceptors = append(cdr.interceptors, cdr.authorizer.UnaryInterceptor(methodAccess))
+	}
+	cdr.authToken = cfg.Auth.Token
+	cdr.interceptors = append(cdr.interceptors, cdr.slowlog.UnaryInterceptor())
+	opts = append(opts, grpc.ChainUnaryInterceptor(cdr.interceptors...))
+
+	cdr.keys, err = encryption.Load(cfg.Encryption)
+	if err != nil {
+		log.Fatalf("Failed to load encryption keys: %s", err)
+	}
+
+	if cfg.StateFile != "" {
+		if err := cdr.LoadState(cfg.StateFile); err != nil {
+			log.Fatalf("Failed to load state: %s", err)
+		}
+	}
+
+	if cfg.Raft != nil {
+		if err := cdr.StartRaft(*cfg.Raft); err != nil {
+			log.Fatalf("Failed to start raft: %s", err)
+		}
+	}
+
+	if err := cdr.StartHandoff(cdr.ctx, cfg.Handoff); err != nil {
+		log.Fatalf("Failed to start hinted handoff: %s", err)
+	}
+
+	cdr.StartHealthChecks(cdr.ctx, cfg.Health)
+	cdr.StartLeaseExpiry(cdr.ctx, cfg.Lease)
+	cdr.StartAntiEntropy(cdr.ctx, cfg.AntiEntropy)
+	cdr.StartRangeAssignment(cdr.ctx)
+
+	if err := cdr.StartNearCache(cdr.ctx, cfg.NearCache); err != nil {
+		log.Fatalf("Invalid near cache config: %s", err)
+	}
+
+	if cfg.Gossip != nil {
+		node, err := gossip.Start(*cfg.Gossip)
+		if err != nil {
+			log.Fatalf("Failed to join gossip cluster: %s", err)
+		}
+		cdr.FollowGossip(cdr.ctx, node)
+	}
+
+	// once it is known whether other coordinators write keys too
+	if err := cdr.StartRedis(cdr.ctx, cfg.Redis); err != nil {
+		log.Fatalf("Failed to start the redis frontend: %s", err)
+	}
+
 	lis, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		log.Fatalf("Failed to start TCP listener: %s", err)
 	}
 
-	log.Printf("coordinator listening on port: %d\n", lis.Addr().(*net.TCPAddr).Port)
+	logging.Logger("coordinator").Info("coordinator listening", "port", lis.Addr().(*net.TCPAddr).Port)
 
-	gRPCServer := grpc.NewServer()
+	gRPCServer := grpc.NewServer(opts...)
 	pb_coordinator.RegisterCoordinatorAPIServer(gRPCServer, cdr)
 
+	go func() {
+		<-ctx.Done()
+		logging.Logger("coordinator").Info("shutting down")
+
+		// ring watches may wait for a change for ringWatchTimeout
+		timer := time.AfterFunc(shutdownTimeout, gRPCServer.Stop)
+		defer timer.Stop()
+		gRPCServer.GracefulStop()
+	}()
+
 	if err := gRPCServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to start gRPC server: %s", err)
 	}
+
+	cdr.hints.sync()
+
+	// flush the spans of the last requests
+	if err := stopTracing(context.Background()); err != nil {
+		logging.Logger("coordinator").Error("failed to flush spans", "error", err)
+	}
 }
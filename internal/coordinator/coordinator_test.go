@@ -0,0 +1,257 @@
+package coordinator
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// slowStore answers every request after delay, or gives up when the
+// request is cancelled and reports it on cancelled
+type slowStore struct {
+	delay     time.Duration
+	cancelled chan struct{}
+	pb_store.UnimplementedKeyValueStoreServer
+}
+
+func (s *slowStore) wait(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+		return nil
+	case <-ctx.Done():
+		s.cancelled <- struct{}{}
+		return ctx.Err()
+	}
+}
+
+func (s *slowStore) Get(ctx context.Context, in *pb_store.GetRequest) (*pb_store.GetResponse, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return &pb_store.GetResponse{Status: pb_store.StatusType_CACHE_MISS}, nil
+}
+
+func (s *slowStore) Put(ctx context.Context, in *pb_store.PutRequest) (*pb_store.PutResponse, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return &pb_store.PutResponse{Status: pb_store.StatusType_OK}, nil
+}
+
+func (s *slowStore) Delete(ctx context.Context, in *pb_store.DeleteRequest) (*pb_store.DeleteResponse, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return &pb_store.DeleteResponse{Status: pb_store.StatusType_OK}, nil
+}
+
+// returns a coordinator whose only store is s
+func withStore(t *testing.T, s pb_store.KeyValueStoreServer, storeTimeout time.Duration) *Coordinator {
+	t.Helper()
+
+	c := withStores(t, map[string]pb_store.KeyValueStoreServer{"slow": s})
+	c.storeTimeout = storeTimeout
+	return c
+}
+
+// returns a coordinator whose stores are removed when the test ends
+func newTestCoordinator(t *testing.T) *Coordinator {
+	t.Helper()
+
+	c, err := NewCoordinator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for name := range c.storeClients {
+			c.removeStore(name)
+		}
+	})
+	return c
+}
+
+// returns a coordinator whose stores are the servers of stores, by name
+func withStores(t *testing.T, stores map[string]pb_store.KeyValueStoreServer) *Coordinator {
+	t.Helper()
+
+	c := newTestCoordinator(t)
+	for name, s := range stores {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := grpc.NewServer()
+		pb_store.RegisterKeyValueStoreServer(server, s)
+		go server.Serve(lis)
+		t.Cleanup(server.Stop)
+
+		c.mu.Lock()
+		_, err = c.addStore(name, lis.Addr().String(), nil)
+		c.mu.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return c
+}
+
+func TestStoreDeadlines(t *testing.T) {
+	requests := map[string]func(ctx context.Context, c *Coordinator) error{
+		"get": func(ctx context.Context, c *Coordinator) error {
+			_, err := c.Get(ctx, &pb_coordinator.GetRequest{Key: "key"})
+			return err
+		},
+		"put": func(ctx context.Context, c *Coordinator) error {
+			_, err := c.Put(ctx, &pb_coordinator.PutRequest{Key: "key", Value: "value"})
+			return err
+		},
+		"delete": func(ctx context.Context, c *Coordinator) error {
+			_, err := c.Delete(ctx, &pb_coordinator.DeleteRequest{Key: "key"})
+			return err
+		},
+	}
+
+	tests := []struct {
+		name         string
+		delay        time.Duration
+		storeTimeout time.Duration
+
+		// client is the deadline of the request, cancel cancels it after
+		// that long instead
+		client time.Duration
+		cancel bool
+
+		want codes.Code
+	}{
+		{
+			name:         "fast store",
+			delay:        0,
+			storeTimeout: time.Second,
+			want:         codes.OK,
+		},
+		{
+			name:         "client deadline before the store timeout",
+			delay:        10 * time.Second,
+			storeTimeout: 10 * time.Second,
+			client:       100 * time.Millisecond,
+			want:         codes.DeadlineExceeded,
+		},
+		{
+			name:         "store timeout before the client deadline",
+			delay:        10 * time.Second,
+			storeTimeout: 100 * time.Millisecond,
+			client:       10 * time.Second,
+			want:         codes.DeadlineExceeded,
+		},
+		{
+			name:         "store timeout without a client deadline",
+			delay:        10 * time.Second,
+			storeTimeout: 100 * time.Millisecond,
+			want:         codes.DeadlineExceeded,
+		},
+		{
+			name:         "client cancels",
+			delay:        10 * time.Second,
+			storeTimeout: 10 * time.Second,
+			client:       100 * time.Millisecond,
+			cancel:       true,
+			want:         codes.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		for method, request := range requests {
+			t.Run(tt.name+"/"+method, func(t *testing.T) {
+				store := &slowStore{delay: tt.delay, cancelled: make(chan struct{}, 1)}
+				c := withStore(t, store, tt.storeTimeout)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				if tt.client > 0 {
+					if tt.cancel {
+						time.AfterFunc(tt.client, cancel)
+					} else {
+						ctx, cancel = context.WithTimeout(ctx, tt.client)
+						defer cancel()
+					}
+				}
+
+				start := time.Now()
+				err := request(ctx, c)
+				elapsed := time.Since(start)
+
+				if got := status.Code(err); got != tt.want {
+					t.Fatalf("%s = %v, want %s", method, err, tt.want)
+				}
+				if tt.want == codes.OK {
+					return
+				}
+
+				if elapsed > 2*time.Second {
+					t.Errorf("%s took %s, it should give up on the store", method, elapsed)
+				}
+				select {
+				case <-store.cancelled:
+				case <-time.After(2 * time.Second):
+					t.Errorf("the store request of %s was not cancelled", method)
+				}
+			})
+		}
+	}
+}
+
+func TestBreakerDeadlines(t *testing.T) {
+	tests := []struct {
+		name         string
+		storeTimeout time.Duration
+		client       time.Duration
+		want         BreakerState
+	}{
+		{
+			name:         "store timeouts open the breaker",
+			storeTimeout: time.Millisecond,
+			want:         BreakerOpen,
+		},
+		{
+			name:         "client deadlines leave it closed",
+			storeTimeout: time.Minute,
+			client:       time.Millisecond,
+			want:         BreakerClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultResilienceConfig()
+			c := &Coordinator{storeTimeout: tt.storeTimeout}
+			b := &breaker{}
+
+			for i := 0; i < cfg.BreakerThreshold; i++ {
+				ctx, cancel := context.WithCancel(context.Background())
+				if tt.client > 0 {
+					ctx, cancel = context.WithTimeout(ctx, tt.client)
+				}
+				callCtx, callCancel := c.storeCtx(ctx)
+				<-callCtx.Done()
+
+				b.record(callCtx, status.FromContextError(callCtx.Err()).Err(), cfg)
+				callCancel()
+				cancel()
+			}
+
+			if state, failures, _ := b.snapshot(); state != tt.want {
+				t.Errorf("breaker is %s after %d failures, want %s", state, failures, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package coordinator
+
+import (
+	"context"
+
+	"github.com/priyansh32/nebula/internal/audit"
+	"github.com/priyansh32/nebula/internal/gossip"
+	"github.com/priyansh32/nebula/internal/logging"
+)
+
+// FollowGossip keeps the ring in sync with the stores disseminated by the
+// gossip node until ctx is done. Stores learnt through gossip are placed at
+// the positions they chose themselves, so every coordinator following the
+// same cluster builds the same ring. Stores added by other means are left alone.
+func (c *Coordinator) FollowGossip(ctx context.Context, node *gossip.Node) {
+	c.mu.Lock()
+	c.gossiping = true
+	c.mu.Unlock()
+
+	c.reconcileGossip(node.Members())
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				node.Shutdown()
+				return
+			case event := <-node.Events():
+				if event.Member.Role == gossip.RoleStore {
+					c.reconcileGossip(node.Members())
+				}
+			}
+		}
+	}()
+}
+
+// adds live gossip stores missing from the ring and removes the ones that
+// failed or left, recording the changes in the audit log
+func (c *Coordinator) reconcileGossip(members []gossip.Member) {
+	c.mu.Lock()
+	changes := c.reconcileGossipLocked(members)
+	c.mu.Unlock()
+
+	c.auditSystem(changes...)
+}
+
+// reconciles the ring with the gossip stores and returns the audit records
+// of the changes made, c.mu must be held
+func (c *Coordinator) reconcileGossipLocked(members []gossip.Member) []audit.Record {
+	changes := make([]audit.Record, 0)
+
+	live := make(map[string]bool)
+
+	for _, m := range members {
+		if m.Role != gossip.RoleStore || m.State > gossip.StateSuspect {
+			continue
+		}
+		live[m.Name] = true
+
+		if s, ok := c.storeClients[m.Name]; ok {
+			if !c.gossipStores[m.Name] || (s.address == m.StoreAddress && samePositions(s.nodeKeys, m.Tokens)) {
+				continue
+			}
+			// the store restarted with a new address or new positions
+			changes = append(changes, systemRecord("GossipRemoveStore", m.Name, c.removeStore(m.Name)))
+		}
+
+		_, err := c.addStore(m.Name, m.StoreAddress, m.Tokens)
+		changes = append(changes, systemRecord("GossipAddStore", m.Name, err))
+		if err != nil {
+			logging.Logger("gossip").Error("failed to add a gossip store", "store", m.Name, "error", err)
+			continue
+		}
+		c.gossipStores[m.Name] = true
+	}
+
+	for name := range c.gossipStores {
+		if !live[name] {
+			changes = append(changes, systemRecord("GossipRemoveStore", name, c.removeStore(name)))
+		}
+	}
+
+	return changes
+}
+
+func samePositions(a []uint64, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,598 @@
+package coordinator
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+	"github.com/priyansh32/nebula/internal/encryption"
+	"github.com/priyansh32/nebula/internal/logging"
+)
+
+// number of finished hints after which the hint file is compacted
+const hintCompactionThreshold = 1024
+
+// purpose of encrypted hint files
+const hintFilePurpose = "hints"
+
+// HandoffConfig controls hinted handoff: writes for a store that is down
+// go to the next store on the ring and a hint is kept to replay them on
+// the owner once it is back
+type HandoffConfig struct {
+	// Path of the file hints are kept in, hints only live in memory if empty
+	Path string
+
+	// MaxHints bounds the queue, the oldest hints are dropped beyond it
+	MaxHints int
+
+	// ReplayInterval is how often hints are retried besides when a store recovers
+	ReplayInterval time.Duration
+
+	// SyncInterval is how often the hints appended to the file are synced
+	// to disk, the hints taken in the last interval are lost if the machine
+	// crashes. Every hint is synced before the write is acknowledged if 0.
+	SyncInterval time.Duration
+}
+
+func DefaultHandoffConfig() HandoffConfig {
+	return HandoffConfig{
+		MaxHints:       10000,
+		ReplayInterval: 10 * time.Second,
+		SyncInterval:   time.Second,
+	}
+}
+
+// hint is a write that landed on holder while owner was unavailable
+type hint struct {
+	ID      uint64    `json:"id"`
+	Owner   string    `json:"owner"`
+	Holder  string    `json:"holder"`
+	Key     string    `json:"key"`
+	Value   string    `json:"value,omitempty"`
+	Delete  bool      `json:"delete,omitempty"`
+	Version int64     `json:"version,omitempty"`
+	Time    time.Time `json:"time"`
+
+	// ExpiresAt is when the value expires in unix milliseconds, 0 if never
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+// hintRecord is a line of the hint file, either a new hint or the id of a finished one
+type hintRecord struct {
+	Hint *hint  `json:"hint,omitempty"`
+	Done uint64 `json:"done,omitempty"`
+}
+
+// hintQueue is a bounded queue of hints in the order they were taken
+// when backed by a file every change is appended to it, so hints survive
+// a coordinator restart
+type hintQueue struct {
+	mu     sync.Mutex
+	max    int
+	nextID uint64
+	hints  []hint
+
+	path string
+	file *os.File
+	done int
+
+	// appended records are synced by append if syncEach is set, by sync otherwise
+	syncEach bool
+	dirty    bool
+
+	// keys, if set, encrypt the hint file, which is sealed record by
+	// record through sealer and rewritten with the primary key on
+	// compaction. A trailer next to the file is rewritten whenever records
+	// are synced, so records cut off the file are noticed.
+	keys   *encryption.Keyring
+	sealer *encryption.File
+}
+
+func newHintQueue(max int) *hintQueue {
+	return &hintQueue{max: max, nextID: 1}
+}
+
+// opens the hint file at path, loading the hints left in it. With keys
+// the file must be encrypted with one of them.
+func openHintQueue(path string, max int, keys *encryption.Keyring) (*hintQueue, error) {
+	q := newHintQueue(max)
+	q.path = path
+	q.keys = keys
+
+	if f, err := os.Open(path); err == nil {
+		err := q.load(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load hint file %s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// start from a compact file holding only the pending hints
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	if keys != nil {
+		logging.Logger("handoff").Info("the hint file is encrypted", "path", path, "key", keys.PrimaryID())
+	}
+
+	return q, nil
+}
+
+// reads the hints left in a hint file. It fails on any record that is
+// corrupt or, in an encrypted file, does not authenticate, and on records
+// missing from the end of an encrypted file. Only the last record may be
+// torn by a crash, if it was appended after the last sync.
+func (q *hintQueue) load(f *os.File) error {
+	pending := make(map[uint64]hint)
+	order := make([]uint64, 0)
+
+	// count is the number of records of an encrypted file that were on
+	// disk when its trailer was written
+	var file *encryption.File
+	var count uint64
+	reader := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(data) == 0 {
+			break
+		}
+		// only the last line can miss its newline, after a crash
+		torn := data[len(data)-1] != '\n'
+
+		if line == 1 && q.keys != nil && !encryption.IsHeader(data) {
+			// a file written before the keys were given, it is
+			// encrypted when the queue is compacted after loading
+			logging.Logger("handoff").Warn("the hint file is not encrypted, encrypting it", "path", f.Name(), "key", q.keys.PrimaryID())
+		} else if line == 1 && q.keys != nil {
+			if file, err = q.keys.Open(hintFilePurpose, data); err != nil {
+				return err
+			}
+			if count, err = q.readTrailer(file); err != nil {
+				return err
+			}
+			continue
+		}
+		if line == 1 && encryption.IsHeader(data) {
+			return errors.New("the file is encrypted but no encryption keys are given")
+		}
+
+		if file != nil {
+			plaintext, err := file.Unseal(data)
+			if err != nil && torn && file.Records() >= count {
+				logging.Logger("handoff").Warn("dropping a hint record torn by a crash", "error", err)
+				break
+			}
+			if err != nil {
+				return err
+			}
+			data = plaintext
+		}
+
+		var record hintRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			if torn && file == nil {
+				logging.Logger("handoff").Warn("dropping a hint record torn by a crash", "error", err)
+				break
+			}
+			return fmt.Errorf("hint record %d is corrupt: %w", line, err)
+		}
+		if record.Hint != nil {
+			pending[record.Hint.ID] = *record.Hint
+			order = append(order, record.Hint.ID)
+			if record.Hint.ID >= q.nextID {
+				q.nextID = record.Hint.ID + 1
+			}
+		} else {
+			delete(pending, record.Done)
+		}
+	}
+
+	if file != nil && file.Records() < count {
+		return fmt.Errorf("the file ends after record %d but its trailer counts %d, records were cut off", file.Records(), count)
+	}
+
+	for _, id := range order {
+		if h, ok := pending[id]; ok {
+			q.hints = append(q.hints, h)
+		}
+	}
+	return nil
+}
+
+// returns the path of the trailer of the encrypted hint file with the given id
+func (q *hintQueue) trailerPath(fileID string) string {
+	return q.path + ".trailer." + fileID
+}
+
+// returns the number of records the trailer of an encrypted hint file counts
+func (q *hintQueue) readTrailer(file *encryption.File) (uint64, error) {
+	data, err := os.ReadFile(q.trailerPath(file.ID()))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, errors.New("the trailer of the file is missing, records may have been cut off")
+	}
+	if err != nil {
+		return 0, err
+	}
+	return file.OpenTrailer(data)
+}
+
+// rewrites the trailer of an encrypted hint file once the records appended
+// to it are on disk, q.mu must be held
+func (q *hintQueue) writeTrailer() error {
+	if q.sealer == nil {
+		return nil
+	}
+	trailer, err := q.sealer.SealTrailer()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(q.trailerPath(q.sealer.ID()), append(trailer, '\n'))
+}
+
+// removes the trailers of hint files other than the current one, left
+// behind by compactions
+func (q *hintQueue) removeTrailers() {
+	paths, _ := filepath.Glob(q.path + ".trailer.*")
+	for _, path := range paths {
+		if q.sealer == nil || path != q.trailerPath(q.sealer.ID()) {
+			os.Remove(path)
+		}
+	}
+}
+
+// returns the line of a record of the hint file, encrypted with file if set
+func encodeHintRecord(file *encryption.File, record hintRecord) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil || file == nil {
+		return data, err
+	}
+	return file.Seal(data)
+}
+
+// queues a hint, dropping the oldest one if the queue is full
+func (q *hintQueue) add(h hint) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	h.ID = q.nextID
+	q.nextID++
+	q.hints = append(q.hints, h)
+	q.append(hintRecord{Hint: &h})
+
+	if len(q.hints) > q.max {
+		dropped := q.hints[0]
+		q.hints = q.hints[1:]
+		q.finish(dropped.ID)
+		logging.Logger("handoff").Warn("hint queue full, dropped a hint", logging.Key("key", dropped.Key), "store", dropped.Owner)
+	}
+}
+
+// returns the hints for the given owner in the order they were taken
+func (q *hintQueue) forOwner(owner string) []hint {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	hints := make([]hint, 0)
+	for _, h := range q.hints {
+		if h.Owner == owner {
+			hints = append(hints, h)
+		}
+	}
+	return hints
+}
+
+// returns the owners that have hints waiting for them
+func (q *hintQueue) owners() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	seen := make(map[string]bool)
+	owners := make([]string, 0)
+	for _, h := range q.hints {
+		if !seen[h.Owner] {
+			seen[h.Owner] = true
+			owners = append(owners, h.Owner)
+		}
+	}
+	return owners
+}
+
+// removes the hint with the given id
+func (q *hintQueue) remove(id uint64) {
+	q.removeWhere(func(h hint) bool { return h.ID == id })
+}
+
+// removes the hints of an owner for a key, they are stale once the owner took a newer write
+func (q *hintQueue) supersede(owner string, key string) {
+	q.removeWhere(func(h hint) bool { return h.Owner == owner && h.Key == key })
+}
+
+// removes all hints of an owner
+func (q *hintQueue) drop(owner string) {
+	q.removeWhere(func(h hint) bool { return h.Owner == owner })
+}
+
+func (q *hintQueue) removeWhere(match func(hint) bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kept := q.hints[:0]
+	for _, h := range q.hints {
+		if match(h) {
+			q.finish(h.ID)
+		} else {
+			kept = append(kept, h)
+		}
+	}
+	q.hints = kept
+
+	if q.done >= hintCompactionThreshold && q.done > len(q.hints) {
+		if err := q.compact(); err != nil {
+			logging.Logger("handoff").Error("failed to compact the hint file", "error", err)
+		}
+	}
+}
+
+// records a finished hint in the file, q.mu must be held
+func (q *hintQueue) finish(id uint64) {
+	q.done++
+	q.append(hintRecord{Done: id})
+}
+
+// appends a record to the hint file, q.mu must be held
+func (q *hintQueue) append(record hintRecord) {
+	if q.file == nil {
+		return
+	}
+
+	data, err := encodeHintRecord(q.sealer, record)
+	if err != nil {
+		logging.Logger("handoff").Error("failed to encode a hint", "error", err)
+		return
+	}
+
+	if _, err := q.file.Write(append(data, '\n')); err != nil {
+		logging.Logger("handoff").Error("failed to write the hint file", "error", err)
+		return
+	}
+
+	if !q.syncEach {
+		q.dirty = true
+		return
+	}
+	if err := q.file.Sync(); err != nil {
+		logging.Logger("handoff").Error("failed to sync the hint file", "error", err)
+		return
+	}
+	if err := q.writeTrailer(); err != nil {
+		logging.Logger("handoff").Error("failed to write the trailer of the hint file", "error", err)
+	}
+}
+
+// syncs the records appended to the hint file since the last sync
+func (q *hintQueue) sync() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.file == nil || !q.dirty {
+		return
+	}
+	if err := q.file.Sync(); err != nil {
+		logging.Logger("handoff").Error("failed to sync the hint file", "error", err)
+		return
+	}
+	if err := q.writeTrailer(); err != nil {
+		logging.Logger("handoff").Error("failed to write the trailer of the hint file", "error", err)
+		return
+	}
+	q.dirty = false
+}
+
+// rewrites the hint file with only the pending hints, encrypting it with
+// the primary key if it is encrypted, q.mu must be held
+func (q *hintQueue) compact() error {
+	if q.path == "" {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), filepath.Base(q.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+
+	var file *encryption.File
+	if q.keys != nil {
+		var header []byte
+		file, header, err = q.keys.Create(hintFilePurpose)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.Write(append(header, '\n'))
+	}
+
+	for i := range q.hints {
+		data, err := encodeHintRecord(file, hintRecord{Hint: &q.hints[i]})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.Write(append(data, '\n'))
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// the trailer of the new file is written before the file replaces the
+	// old one, so whichever file is on disk after a crash has its trailer
+	if file != nil {
+		trailer, err := file.SealTrailer()
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(q.trailerPath(file.ID()), append(trailer, '\n')); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp.Name(), q.path); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(q.path)); err != nil {
+		return err
+	}
+
+	if q.file != nil {
+		q.file.Close()
+	}
+	q.file, err = os.OpenFile(q.path, os.O_APPEND|os.O_WRONLY, 0600)
+	q.sealer = file
+	q.done = 0
+	q.dirty = false
+	q.removeTrailers()
+
+	return err
+}
+
+// StartHandoff persists hints to cfg.Path, encrypted with the keys of the
+// coordinator if it has any, and replays them whenever a
+// store recovers and every cfg.ReplayInterval until ctx is done
+func (c *Coordinator) StartHandoff(ctx context.Context, cfg HandoffConfig) error {
+	if cfg.SyncInterval < 0 {
+		return errors.New("hint sync interval must not be negative")
+	}
+
+	if cfg.Path != "" {
+		q, err := openHintQueue(cfg.Path, cfg.MaxHints, c.keys)
+		if err != nil {
+			return err
+		}
+		q.syncEach = cfg.SyncInterval == 0
+		c.hints = q
+	} else {
+		c.hints.max = cfg.MaxHints
+	}
+
+	events := c.Subscribe(64)
+
+	go func() {
+		ticker := time.NewTicker(cfg.ReplayInterval)
+		defer ticker.Stop()
+
+		// a nil channel never fires, hints are synced as they are appended then
+		var syncs <-chan time.Time
+		if cfg.Path != "" && cfg.SyncInterval > 0 {
+			syncTicker := time.NewTicker(cfg.SyncInterval)
+			defer syncTicker.Stop()
+			syncs = syncTicker.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				c.hints.sync()
+				return
+			case <-syncs:
+				c.hints.sync()
+			case event := <-events:
+				if event.Current == StoreUp {
+					c.replayHints(ctx, event.Store)
+				}
+			case <-ticker.C:
+				for _, owner := range c.hints.owners() {
+					c.replayHints(ctx, owner)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// sends the hinted writes to their owner and removes the copies held by other stores
+func (c *Coordinator) replayHints(ctx context.Context, owner string) {
+	c.mu.RLock()
+	s := c.storeClients[owner]
+	c.mu.RUnlock()
+
+	if s == nil {
+		// the store left the ring, its keys belong to other stores now
+		c.hints.drop(owner)
+		return
+	}
+
+	if s.State() != StoreUp {
+		return
+	}
+
+	for _, h := range c.hints.forOwner(owner) {
+		callCtx, cancel := c.storeCtx(ctx)
+
+		var err error
+		if h.Delete {
+			_, err = s.client.Delete(callCtx, &pb_store.DeleteRequest{Key: h.Key, Version: h.Version})
+		} else {
+			_, err = s.client.Put(callCtx, &pb_store.PutRequest{Key: h.Key, Value: h.Value, Version: h.Version, ExpiresAtMs: h.ExpiresAt})
+		}
+		cancel()
+		if err != nil {
+			logging.Logger("handoff").Warn("failed to replay hints", "store", owner, "error", err)
+			return
+		}
+
+		c.hints.remove(h.ID)
+		c.releaseHintedCopy(ctx, h)
+	}
+}
+
+// deletes the copy of a hinted key from the store that held it for the owner,
+// unless the ring changed and the holder is a replica of the key now
+func (c *Coordinator) releaseHintedCopy(ctx context.Context, h hint) {
+	c.mu.RLock()
+	holder := c.storeClients[h.Holder]
+	c.mu.RUnlock()
+
+	if holder == nil || c.isReplica(h.Key, holder) {
+		return
+	}
+
+	callCtx, cancel := c.storeCtx(ctx)
+	defer cancel()
+
+	// the holder keeps any newer write it took for the key meanwhile
+	if _, err := holder.client.Delete(callCtx, &pb_store.DeleteRequest{Key: h.Key, Version: h.Version}); err != nil {
+		logging.Logger("handoff").Warn("failed to release a hinted key", logging.Key("key", h.Key), "store", h.Holder, "error", err)
+	}
+}
+
+// returns the number of hints waiting to be replayed
+func (q *hintQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.hints)
+}
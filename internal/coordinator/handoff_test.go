@@ -0,0 +1,461 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+	"github.com/priyansh32/nebula/internal/encryption"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// returns a keyring of the given ids, each key derived from its id
+func hintKeys(t *testing.T, ids ...string) *encryption.Keyring {
+	t.Helper()
+
+	entries := make([]string, len(ids))
+	for i, id := range ids {
+		entries[i] = id + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte(id), 16)[:16])
+	}
+	keys, err := encryption.Load(encryption.Config{Keys: strings.Join(entries, ",")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// writes a hint file at path holding the hints of keys a and c, b was
+// replayed, and closes it
+func writeHints(t *testing.T, path string, keys *encryption.Keyring, unsynced bool) {
+	t.Helper()
+
+	q, err := openHintQueue(path, 100, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		q.add(hint{Owner: "s1", Holder: "s2", Key: key, Value: "v"})
+	}
+	q.supersede("s1", "b")
+	q.sync()
+
+	if unsynced {
+		q.add(hint{Owner: "s1", Holder: "s2", Key: "d", Value: "v"})
+	}
+	q.file.Close()
+}
+
+// returns the lines of the file at path
+func lines(t *testing.T, path string) [][]byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.SplitAfter(data, []byte("\n"))
+}
+
+func rewrite(t *testing.T, path string, lines [][]byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, bytes.Join(lines, nil), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHintFileLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		encrypted bool
+		unsynced  bool
+		tamper    func(t *testing.T, path string)
+		want      string
+		ok        bool
+	}{
+		{
+			name:      "intact",
+			encrypted: true,
+			tamper:    func(t *testing.T, path string) {},
+			want:      "a,c",
+			ok:        true,
+		},
+		{
+			name:      "record appended after the last sync",
+			encrypted: true,
+			unsynced:  true,
+			tamper:    func(t *testing.T, path string) {},
+			want:      "a,c,d",
+			ok:        true,
+		},
+		{
+			name:      "record torn after the last sync",
+			encrypted: true,
+			unsynced:  true,
+			tamper: func(t *testing.T, path string) {
+				l := lines(t, path)
+				last := l[len(l)-2]
+				l[len(l)-2] = last[:len(last)/2]
+				rewrite(t, path, l)
+			},
+			want: "a,c",
+			ok:   true,
+		},
+		{
+			name:      "flipped byte",
+			encrypted: true,
+			tamper: func(t *testing.T, path string) {
+				l := lines(t, path)
+				l[2][5] ^= 1
+				rewrite(t, path, l)
+			},
+		},
+		{
+			name:      "reordered records",
+			encrypted: true,
+			tamper: func(t *testing.T, path string) {
+				l := lines(t, path)
+				l[1], l[2] = l[2], l[1]
+				rewrite(t, path, l)
+			},
+		},
+		{
+			name:      "records cut off the end",
+			encrypted: true,
+			tamper: func(t *testing.T, path string) {
+				l := lines(t, path)
+				rewrite(t, path, l[:len(l)-2])
+			},
+		},
+		{
+			name:      "torn record before the last sync",
+			encrypted: true,
+			tamper: func(t *testing.T, path string) {
+				l := lines(t, path)
+				last := l[len(l)-2]
+				l[len(l)-2] = last[:len(last)/2]
+				rewrite(t, path, l)
+			},
+		},
+		{
+			name:      "trailer removed",
+			encrypted: true,
+			tamper: func(t *testing.T, path string) {
+				trailers, _ := filepath.Glob(path + ".trailer.*")
+				for _, trailer := range trailers {
+					os.Remove(trailer)
+				}
+			},
+		},
+		{
+			name:   "plaintext",
+			tamper: func(t *testing.T, path string) {},
+			want:   "a,c",
+			ok:     true,
+		},
+		{
+			name: "plaintext torn at the end",
+			tamper: func(t *testing.T, path string) {
+				l := lines(t, path)
+				rewrite(t, path, append(l[:len(l)-1], []byte(`{"hint":{"id":9`)))
+			},
+			want: "a,c",
+			ok:   true,
+		},
+		{
+			name: "plaintext corrupt in the middle",
+			tamper: func(t *testing.T, path string) {
+				l := lines(t, path)
+				l[1] = []byte("corrupt\n")
+				rewrite(t, path, l)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hints")
+			var keys *encryption.Keyring
+			if tt.encrypted {
+				keys = hintKeys(t, "k1")
+			}
+
+			writeHints(t, path, keys, tt.unsynced)
+			tt.tamper(t, path)
+
+			q, err := openHintQueue(path, 100, keys)
+			if (err == nil) != tt.ok {
+				t.Fatalf("openHintQueue() = %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			defer q.file.Close()
+
+			got := make([]string, 0)
+			for _, h := range q.forOwner("s1") {
+				got = append(got, h.Key)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("loaded hints for %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHintFileKeyRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hints")
+	writeHints(t, path, hintKeys(t, "k1"), false)
+
+	tests := []struct {
+		name string
+		keys *encryption.Keyring
+		ok   bool
+	}{
+		{name: "without the old key", keys: hintKeys(t, "k2")},
+		{name: "new key first", keys: hintKeys(t, "k2", "k1"), ok: true},
+		{name: "old key dropped after compaction", keys: hintKeys(t, "k2"), ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := openHintQueue(path, 100, tt.keys)
+			if (err == nil) != tt.ok {
+				t.Fatalf("openHintQueue() = %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			defer q.file.Close()
+
+			if q.len() != 2 {
+				t.Errorf("loaded %d hints, want 2", q.len())
+			}
+			if id := q.sealer.KeyID(); id != "k2" {
+				t.Errorf("the hint file is encrypted with %s, want k2", id)
+			}
+			if trailers, _ := filepath.Glob(path + ".trailer.*"); len(trailers) != 1 {
+				t.Errorf("found trailers %v, want only the one of the current file", trailers)
+			}
+		})
+	}
+}
+
+func TestHintFileCompaction(t *testing.T) {
+	const pending = 10
+
+	tests := []struct {
+		name      string
+		encrypted bool
+	}{
+		{name: "plaintext"},
+		{name: "encrypted", encrypted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hints")
+			var keys *encryption.Keyring
+			header := 0
+			if tt.encrypted {
+				keys = hintKeys(t, "k1")
+				header = 1
+			}
+
+			q, err := openHintQueue(path, 2*hintCompactionThreshold, keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < hintCompactionThreshold+pending; i++ {
+				q.add(hint{Owner: "s1", Holder: "s2", Key: fmt.Sprintf("key-%d", i), Value: "v"})
+			}
+			for i := 0; i < hintCompactionThreshold; i++ {
+				q.supersede("s1", fmt.Sprintf("key-%d", i))
+			}
+			q.sync()
+			q.file.Close()
+
+			// only the pending hints are left in the file
+			if got := len(lines(t, path)) - 1; got != pending+header {
+				t.Errorf("the compacted file has %d lines, want %d", got, pending+header)
+			}
+
+			q, err = openHintQueue(path, 2*hintCompactionThreshold, keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer q.file.Close()
+
+			hints := q.forOwner("s1")
+			if len(hints) != pending {
+				t.Fatalf("loaded %d hints, want %d", len(hints), pending)
+			}
+			for i, h := range hints {
+				if want := fmt.Sprintf("key-%d", hintCompactionThreshold+i); h.Key != want {
+					t.Errorf("hint %d is for %s, want %s", i, h.Key, want)
+				}
+			}
+
+			// hints taken after a restart do not reuse the ids of loaded ones
+			q.add(hint{Owner: "s1", Holder: "s2", Key: "new", Value: "v"})
+			hints = q.forOwner("s1")
+			if last := hints[len(hints)-1]; last.ID <= hints[len(hints)-2].ID {
+				t.Errorf("new hint got id %d after %d", last.ID, hints[len(hints)-2].ID)
+			}
+		})
+	}
+}
+
+func TestHintQueueBound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hints")
+	q, err := openHintQueue(path, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		q.add(hint{Owner: "s1", Holder: "s2", Key: key, Value: "v"})
+	}
+	q.sync()
+	q.file.Close()
+
+	// the oldest hints are dropped, on disk too
+	q, err = openHintQueue(path, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.file.Close()
+
+	got := make([]string, 0)
+	for _, h := range q.forOwner("s1") {
+		got = append(got, h.Key)
+	}
+	if strings.Join(got, ",") != "c,d,e" {
+		t.Errorf("kept hints for %v, want [c d e]", got)
+	}
+}
+
+// recordingStore records the keys of the writes it takes, or fails them
+type recordingStore struct {
+	pb_store.UnimplementedKeyValueStoreServer
+	fail bool
+
+	mu      sync.Mutex
+	puts    []string
+	deletes []string
+}
+
+func (s *recordingStore) Put(ctx context.Context, in *pb_store.PutRequest) (*pb_store.PutResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail {
+		return nil, status.Error(codes.FailedPrecondition, "failing")
+	}
+	s.puts = append(s.puts, in.Key)
+	return &pb_store.PutResponse{Status: pb_store.StatusType_OK}, nil
+}
+
+func (s *recordingStore) Delete(ctx context.Context, in *pb_store.DeleteRequest) (*pb_store.DeleteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail {
+		return nil, status.Error(codes.FailedPrecondition, "failing")
+	}
+	s.deletes = append(s.deletes, in.Key)
+	return &pb_store.DeleteResponse{Status: pb_store.StatusType_OK}, nil
+}
+
+func (s *recordingStore) writes() (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.puts, ","), strings.Join(s.deletes, ",")
+}
+
+func TestReplayHints(t *testing.T) {
+	tests := []struct {
+		name        string
+		owner       func(c *Coordinator)
+		fail        bool
+		wantPuts    string
+		wantDeletes string
+		wantLeft    int
+	}{
+		{
+			name:        "owner is up",
+			owner:       func(c *Coordinator) {},
+			wantPuts:    "a,b",
+			wantDeletes: "c",
+		},
+		{
+			name: "owner is down",
+			owner: func(c *Coordinator) {
+				c.storeClients["owner"].recordProbe(false, HealthConfig{SuspectThreshold: 1, DownThreshold: 1})
+			},
+			wantLeft: 3,
+		},
+		{
+			name:     "owner fails the writes",
+			owner:    func(c *Coordinator) {},
+			fail:     true,
+			wantLeft: 3,
+		},
+		{
+			name: "owner left the ring",
+			owner: func(c *Coordinator) {
+				c.mu.Lock()
+				defer c.mu.Unlock()
+				c.removeStore("owner")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, holder := &recordingStore{fail: tt.fail}, &recordingStore{}
+			c := withStores(t, map[string]pb_store.KeyValueStoreServer{"owner": owner, "holder": holder})
+
+			hints := []hint{
+				{Owner: "owner", Holder: "holder", Key: "a", Value: "v", Version: 1},
+				{Owner: "owner", Holder: "holder", Key: "b", Value: "v", Version: 1},
+				{Owner: "owner", Holder: "holder", Key: "c", Delete: true, Version: 1},
+			}
+			for _, h := range hints {
+				c.hints.add(h)
+			}
+			tt.owner(c)
+
+			c.replayHints(context.Background(), "owner")
+
+			puts, deletes := owner.writes()
+			if puts != tt.wantPuts || deletes != tt.wantDeletes {
+				t.Errorf("the owner took puts [%s] and deletes [%s], want [%s] and [%s]", puts, deletes, tt.wantPuts, tt.wantDeletes)
+			}
+			if left := c.hints.len(); left != tt.wantLeft {
+				t.Errorf("%d hints left, want %d", left, tt.wantLeft)
+			}
+
+			// the holder lets go of the copies it kept for the owner, unless
+			// it is a replica of their keys
+			released := make([]string, 0)
+			if tt.wantPuts != "" {
+				for _, h := range hints {
+					if !c.isReplica(h.Key, c.storeClients["holder"]) {
+						released = append(released, h.Key)
+					}
+				}
+			}
+			if _, deletes := holder.writes(); deletes != strings.Join(released, ",") {
+				t.Errorf("the holder released [%s], want [%s]", deletes, strings.Join(released, ","))
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package coordinator
 
 import (
 	"errors"
+	"math"
 	"sort"
 
 	"github.com/google/uuid"
@@ -27,11 +28,26 @@ func NewHashRing(rf int) *HashRing {
 
 // adds a store to the ring with count nodes
 func (hr *HashRing) AddStoreNodes(s *StoreClient) {
+	hr.AddStoreNodesAt(s, hr.NewPositions(s.name, hr.replicationFactor))
+}
 
-	for i := 0; i < hr.replicationFactor; i++ {
+// picks count random positions on the ring for the nodes of a store
+func (hr *HashRing) NewPositions(name string, count int) []uint64 {
+	hashes := make([]uint64, 0, count)
+	for i := 0; i < count; i++ {
 
 		// identify the position of the node on the ring
-		hash := hashKey(s.name + "-" + uuid.New().String())
+		hashes = append(hashes, hashKey(name+"-"+uuid.New().String()))
+	}
+
+	return hashes
+}
+
+// adds a store to the ring with nodes at the given positions
+// used for stores that chose their own positions
+func (hr *HashRing) AddStoreNodesAt(s *StoreClient, hashes []uint64) {
+
+	for _, hash := range hashes {
 
 		hr.nodes[hash] = &node{
 			storeClient: s,
@@ -45,27 +61,94 @@ func (hr *HashRing) AddStoreNodes(s *StoreClient) {
 	}
 }
 
+// removes all nodes of a store from the ring
+func (hr *HashRing) RemoveStoreNodes(s *StoreClient) {
+	for _, key := range s.nodeKeys {
+		delete(hr.nodes, key)
+		hr.sortedKeys = removeSorted(hr.sortedKeys, key)
+	}
+	s.nodeKeys = nil
+}
+
 // finds the store for the given key
 // this operation is O(log n), n = number of nodes in the ring
 func (hr *HashRing) GetStore(key string) (*StoreClient, error) {
+	stores, err := hr.GetStores(key, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return stores[0], nil
+}
+
+// finds up to n distinct stores for the given key, in ring order
+// the first store is the owner of the key, the rest are the stores
+// a request falls back to when the ones before them are unavailable
+func (hr *HashRing) GetStores(key string, n int) ([]*StoreClient, error) {
 	hash := hashKey(key)
 
 	if len(hr.nodes) == 0 {
 		return nil, errors.New("no stores in the ring")
 	}
 
-	var s *StoreClient
-
-	// find upper bound of hash in sortedKeys, if not found return the first element
+	// find upper bound of hash in sortedKeys, wrapping around to the first element
 	index := sort.Search(len(hr.sortedKeys), func(i int) bool {
 		return hr.sortedKeys[i] >= hash
 	})
 
-	if index < len(hr.sortedKeys) {
-		s = hr.nodes[hr.sortedKeys[index]].storeClient
-	} else {
-		s = hr.nodes[hr.sortedKeys[0]].storeClient
+	return hr.storesFrom(index, n), nil
+}
+
+// returns up to n distinct stores starting from the node at index, in ring order
+func (hr *HashRing) storesFrom(index int, n int) []*StoreClient {
+	stores := make([]*StoreClient, 0, n)
+	seen := make(map[*StoreClient]bool)
+
+	for i := 0; i < len(hr.sortedKeys) && len(stores) < n; i++ {
+		s := hr.nodes[hr.sortedKeys[(index+i)%len(hr.sortedKeys)]].storeClient
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		stores = append(stores, s)
+	}
+
+	return stores
+}
+
+// RingRange is the part of the ring (Start, End] that ends at a node, along
+// with up to n stores holding its keys in order of preference. The range
+// wraps around when Start >= End.
+type RingRange struct {
+	Start  uint64
+	End    uint64
+	Stores []*StoreClient
+}
+
+// returns the ranges between the nodes of the ring, with up to n distinct stores each
+func (hr *HashRing) Ranges(n int) []RingRange {
+	ranges := make([]RingRange, 0, len(hr.sortedKeys))
+	for i, end := range hr.sortedKeys {
+		start := hr.sortedKeys[(i+len(hr.sortedKeys)-1)%len(hr.sortedKeys)]
+		ranges = append(ranges, RingRange{Start: start, End: end, Stores: hr.storesFrom(i, n)})
 	}
+	return ranges
+}
 
-	return s, nil
+// returns the share of the ring each store is the first owner of, between 0 and 1
+// a node owns the range from the node before it up to itself
+func (hr *HashRing) Ownership() map[*StoreClient]float64 {
+	ownership := make(map[*StoreClient]float64)
+	if len(hr.sortedKeys) == 1 {
+		ownership[hr.nodes[hr.sortedKeys[0]].storeClient] = 1
+		return ownership
+	}
+
+	for i, end := range hr.sortedKeys {
+		start := hr.sortedKeys[(i+len(hr.sortedKeys)-1)%len(hr.sortedKeys)]
+
+		// unsigned subtraction wraps around for the range crossing zero
+		ownership[hr.nodes[end].storeClient] += float64(end-start) / math.MaxUint64
+	}
+	return ownership
 }
@@ -0,0 +1,211 @@
+package coordinator
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/priyansh32/nebula/internal/logging"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// StoreState is the health of a store as seen by the coordinator
+type StoreState int32
+
+const (
+	StoreUp StoreState = iota
+	StoreSuspect
+	StoreDown
+)
+
+func (s StoreState) String() string {
+	switch s {
+	case StoreUp:
+		return "UP"
+	case StoreSuspect:
+		return "SUSPECT"
+	case StoreDown:
+		return "DOWN"
+	}
+	return "UNKNOWN"
+}
+
+// HealthConfig controls how often stores are probed and how many
+// consecutive failed probes move a store to suspect and down
+type HealthConfig struct {
+	Interval         time.Duration
+	Timeout          time.Duration
+	SuspectThreshold int
+	DownThreshold    int
+}
+
+func DefaultHealthConfig() HealthConfig {
+	return HealthConfig{
+		Interval:         2 * time.Second,
+		Timeout:          time.Second,
+		SuspectThreshold: 1,
+		DownThreshold:    3,
+	}
+}
+
+// EventType tells what happened to a store in a MembershipEvent
+type EventType int32
+
+const (
+	StoreJoined EventType = iota
+	StoreLeft
+	StoreStateChanged
+)
+
+func (e EventType) String() string {
+	switch e {
+	case StoreJoined:
+		return "JOINED"
+	case StoreLeft:
+		return "LEFT"
+	case StoreStateChanged:
+		return "STATE_CHANGED"
+	}
+	return "UNKNOWN"
+}
+
+// MembershipEvent is emitted whenever a store joins, leaves or changes state
+type MembershipEvent struct {
+	Type     EventType
+	Store    string
+	Address  string
+	Previous StoreState
+	Current  StoreState
+	Time     time.Time
+}
+
+// health tracks the probe results of a single store
+type health struct {
+	mu       sync.Mutex
+	state    StoreState
+	failures int
+}
+
+// State returns the current health state of the store
+func (s *StoreClient) State() StoreState {
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+	return s.health.state
+}
+
+// records the outcome of a probe and returns the state before and after it
+func (s *StoreClient) recordProbe(ok bool, cfg HealthConfig) (StoreState, StoreState) {
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+
+	prev := s.health.state
+
+	if ok {
+		s.health.failures = 0
+		s.health.state = StoreUp
+		return prev, s.health.state
+	}
+
+	s.health.failures++
+	switch {
+	case s.health.failures >= cfg.DownThreshold:
+		s.health.state = StoreDown
+	case s.health.failures >= cfg.SuspectThreshold:
+		s.health.state = StoreSuspect
+	}
+
+	return prev, s.health.state
+}
+
+// probe asks the store's gRPC health service whether it is serving
+func (s *StoreClient) probe(ctx context.Context, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	res, err := healthpb.NewHealthClient(s.conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return false
+	}
+
+	return res.Status == healthpb.HealthCheckResponse_SERVING
+}
+
+// Subscribe returns a channel that receives membership events
+// events are dropped for subscribers that do not keep up
+func (c *Coordinator) Subscribe(buffer int) <-chan MembershipEvent {
+	ch := make(chan MembershipEvent, buffer)
+
+	c.subsMu.Lock()
+	c.subscribers = append(c.subscribers, ch)
+	c.subsMu.Unlock()
+
+	return ch
+}
+
+func (c *Coordinator) emit(event MembershipEvent) {
+	logging.Logger("health").Info("membership changed", "event", event.Type.String(), "store", event.Store, "address", event.Address, "previous", event.Previous.String(), "current", event.Current.String())
+
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	for _, ch := range c.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// StartHealthChecks probes every store on the given interval until ctx is done
+func (c *Coordinator) StartHealthChecks(ctx context.Context, cfg HealthConfig) {
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.probeStores(ctx, cfg)
+			}
+		}
+	}()
+}
+
+// reports whether the store client is still part of the ring
+func (c *Coordinator) isMember(s *StoreClient) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.storeClients[s.name] == s
+}
+
+func (c *Coordinator) probeStores(ctx context.Context, cfg HealthConfig) {
+	c.mu.RLock()
+	stores := make([]*StoreClient, 0, len(c.storeClients))
+	for _, s := range c.storeClients {
+		stores = append(stores, s)
+	}
+	c.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, s := range stores {
+		wg.Add(1)
+		go func(s *StoreClient) {
+			defer wg.Done()
+
+			prev, cur := s.recordProbe(s.probe(ctx, cfg.Timeout), cfg)
+			if prev != cur && c.isMember(s) {
+				c.emit(MembershipEvent{
+					Type:     StoreStateChanged,
+					Store:    s.name,
+					Address:  s.address,
+					Previous: prev,
+					Current:  cur,
+					Time:     time.Now(),
+				})
+			}
+		}(s)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,183 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+	"github.com/priyansh32/nebula/internal/slowlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// number of recent read latencies the hedging delay is computed from
+	latencyWindow = 1024
+
+	// the delay is recomputed after this many new latencies
+	latencyRecompute = 64
+)
+
+// HedgeConfig controls hedged reads: with a read quorum of one, a read
+// goes to the first replica only and another replica is asked when the
+// first has not answered within the given percentile of read latencies.
+// Hedged reads are not repaired, anti-entropy catches up the replicas.
+type HedgeConfig struct {
+	Enabled bool
+
+	// Percentile of recent read latencies to wait before hedging
+	Percentile float64
+
+	// MinDelay is the lowest delay and the delay used until enough reads were timed
+	MinDelay time.Duration
+}
+
+func DefaultHedgeConfig() HedgeConfig {
+	return HedgeConfig{
+		Percentile: 95,
+		MinDelay:   5 * time.Millisecond,
+	}
+}
+
+func (cfg HedgeConfig) validate() error {
+	if cfg.Enabled && (cfg.Percentile <= 0 || cfg.Percentile > 100) {
+		return errors.New("hedging percentile must be between 0 and 100")
+	}
+	return nil
+}
+
+// HedgeStats counts hedged reads
+type HedgeStats struct {
+	// Hedges is the number of duplicate reads sent after the hedging delay,
+	// Wins the number of reads answered by a replica other than the first
+	Hedges uint64
+	Wins   uint64
+}
+
+type hedgeCounters struct {
+	hedges atomic.Uint64
+	wins   atomic.Uint64
+}
+
+// HedgeStats returns the hedged read counters
+func (c *Coordinator) HedgeStats() HedgeStats {
+	return HedgeStats{
+		Hedges: c.hedging.hedges.Load(),
+		Wins:   c.hedging.wins.Load(),
+	}
+}
+
+// latencies keeps recent read latencies to derive the hedging delay from
+type latencies struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	fresh   int
+	delay   time.Duration
+}
+
+func (l *latencies) observe(d time.Duration, cfg HedgeConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.samples) < latencyWindow {
+		l.samples = append(l.samples, d)
+	} else {
+		l.samples[l.next] = d
+		l.next = (l.next + 1) % latencyWindow
+	}
+
+	l.fresh++
+	if l.fresh >= latencyRecompute {
+		l.fresh = 0
+		sorted := append([]time.Duration(nil), l.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		i := int(math.Ceil(cfg.Percentile/100*float64(len(sorted)))) - 1
+		l.delay = sorted[max(0, min(i, len(sorted)-1))]
+	}
+}
+
+// returns how long to wait for a replica before asking the next one
+func (l *latencies) hedgeDelay(cfg HedgeConfig) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return max(l.delay, cfg.MinDelay)
+}
+
+// reads a key from its first replica, asking the next replica whenever
+// the ones asked so far have not answered within the hedging delay or
+// have failed. The first answer wins and the other reads are cancelled.
+func (c *Coordinator) hedgedRead(ctx context.Context, key string, p *placement) (*pb_store.GetResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type answer struct {
+		index int
+		res   *pb_store.GetResponse
+		err   error
+	}
+
+	answers := make(chan answer, len(p.targets))
+	send := func(i int) {
+		go func() {
+			spanCtx, span := startReplicaSpan(ctx, "read replica", p.targets[i])
+			callCtx, cancel := c.storeCtx(spanCtx)
+			defer cancel()
+
+			start := time.Now()
+			done := slowlog.Track(ctx, "read", p.targets[i].store.name)
+			res, err := p.targets[i].store.client.Get(callCtx, &pb_store.GetRequest{Key: key})
+			done()
+			if err == nil {
+				c.latencies.observe(time.Since(start), c.hedge)
+			}
+			endSpan(span, err)
+			answers <- answer{index: i, res: res, err: err}
+		}()
+	}
+
+	send(0)
+	sent, pending := 1, 1
+
+	timer := time.NewTimer(c.latencies.hedgeDelay(c.hedge))
+	defer timer.Stop()
+
+	var err error
+	for pending > 0 {
+		select {
+		case a := <-answers:
+			pending--
+			if a.err == nil {
+				if a.index > 0 {
+					c.hedging.wins.Add(1)
+				}
+				return a.res, nil
+			}
+			err = a.err
+
+			// a failed replica is replaced right away
+			if sent < len(p.targets) {
+				send(sent)
+				sent++
+				pending++
+			}
+		case <-timer.C:
+			if sent < len(p.targets) {
+				trace.SpanFromContext(ctx).AddEvent("hedge", trace.WithAttributes(attribute.Int("nebula.replica_index", sent)))
+				c.hedging.hedges.Add(1)
+				send(sent)
+				sent++
+				pending++
+				timer.Reset(c.latencies.hedgeDelay(c.hedge))
+			}
+		}
+	}
+
+	return nil, quorumError(ctx, err, 0, "no replica answered the read")
+}
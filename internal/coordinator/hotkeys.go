@@ -0,0 +1,100 @@
+package coordinator
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+)
+
+// HotKeys asks every store for its most accessed keys and returns the most
+// accessed keys of the cluster, counting the accesses of a key on all of
+// its replicas. Stores that are down or fail to answer are reported with an error.
+func (c *Coordinator) HotKeys(ctx context.Context, in *pb_coordinator.HotKeysRequest) (*pb_coordinator.HotKeysResponse, error) {
+	c.mu.RLock()
+	stores := make([]*StoreClient, 0, len(c.storeClients))
+	for _, s := range c.storeClients {
+		stores = append(stores, s)
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(stores, func(i, j int) bool {
+		return stores[i].name < stores[j].name
+	})
+
+	res := &pb_coordinator.HotKeysResponse{
+		Stores: make([]*pb_coordinator.StoreHotKeys, len(stores)),
+	}
+
+	var wg sync.WaitGroup
+	for i, s := range stores {
+		wg.Add(1)
+		go func(i int, s *StoreClient) {
+			defer wg.Done()
+			res.Stores[i] = c.storeHotKeys(ctx, s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	// a store only knows of its own accesses, so the counts of a key are
+	// summed across the stores holding it, from all the keys they track
+	keys := make(map[string]*pb_coordinator.HotKey)
+	for _, s := range res.Stores {
+		for _, k := range s.Keys {
+			key := keys[k.Key]
+			if key == nil {
+				key = &pb_coordinator.HotKey{Key: k.Key}
+				keys[k.Key] = key
+				res.Keys = append(res.Keys, key)
+			}
+			key.Count += k.Count
+			key.Stores = append(key.Stores, s.Name)
+		}
+		if in.Count > 0 && int(in.Count) < len(s.Keys) {
+			s.Keys = s.Keys[:in.Count]
+		}
+	}
+
+	sort.Slice(res.Keys, func(i, j int) bool {
+		if res.Keys[i].Count != res.Keys[j].Count {
+			return res.Keys[i].Count > res.Keys[j].Count
+		}
+		return res.Keys[i].Key < res.Keys[j].Key
+	})
+	if in.Count > 0 && int(in.Count) < len(res.Keys) {
+		res.Keys = res.Keys[:in.Count]
+	}
+
+	return res, nil
+}
+
+// returns all the keys a single store tracks, most accessed first
+func (c *Coordinator) storeHotKeys(ctx context.Context, s *StoreClient) *pb_coordinator.StoreHotKeys {
+	hot := &pb_coordinator.StoreHotKeys{
+		Name:    s.name,
+		Address: s.address,
+		State:   s.State().String(),
+	}
+
+	if s.State() == StoreDown {
+		hot.Error = "store is down"
+		return hot
+	}
+
+	callCtx, cancel := c.storeCtx(ctx)
+	defer cancel()
+
+	res, err := s.client.HotKeys(callCtx, &pb_store.HotKeysRequest{})
+	if err != nil {
+		hot.Error = err.Error()
+		return hot
+	}
+
+	hot.HalfLifeMs = res.HalfLifeMs
+	for _, k := range res.Keys {
+		hot.Keys = append(hot.Keys, &pb_coordinator.HotKey{Key: k.Key, Count: k.Count})
+	}
+	return hot
+}
@@ -0,0 +1,94 @@
+package coordinator
+
+import (
+	"context"
+	"sort"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+)
+
+// ListStores returns the stores of the ring with their health
+func (c *Coordinator) ListStores(ctx context.Context, in *pb_coordinator.ListStoresRequest) (*pb_coordinator.ListStoresResponse, error) {
+	c.mu.RLock()
+	stores := make([]*pb_coordinator.StoreInfo, 0, len(c.storeClients))
+	for name, s := range c.storeClients {
+		stores = append(stores, &pb_coordinator.StoreInfo{
+			Name:    name,
+			Address: s.address,
+			State:   s.State().String(),
+			Vnodes:  uint32(len(s.nodeKeys)),
+		})
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(stores, func(i, j int) bool {
+		return stores[i].Name < stores[j].Name
+	})
+
+	return &pb_coordinator.ListStoresResponse{Stores: stores}, nil
+}
+
+// DescribeRing returns the vnodes of every store and the share of the ring
+// it is the first owner of
+func (c *Coordinator) DescribeRing(ctx context.Context, in *pb_coordinator.DescribeRingRequest) (*pb_coordinator.DescribeRingResponse, error) {
+	c.mu.RLock()
+	ownership := c.hashRing.Ownership()
+	res := &pb_coordinator.DescribeRingResponse{
+		Version: c.ringVersion,
+		Vnodes:  uint32(len(c.hashRing.sortedKeys)),
+		Stores:  make([]*pb_coordinator.StoreOwnership, 0, len(c.storeClients)),
+	}
+	for name, s := range c.storeClients {
+		positions := append([]uint64(nil), s.nodeKeys...)
+		sort.Slice(positions, func(i, j int) bool {
+			return positions[i] < positions[j]
+		})
+
+		res.Stores = append(res.Stores, &pb_coordinator.StoreOwnership{
+			Name:             name,
+			Address:          s.address,
+			Positions:        positions,
+			OwnershipPercent: ownership[s] * 100,
+		})
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(res.Stores, func(i, j int) bool {
+		return res.Stores[i].Name < res.Stores[j].Name
+	})
+
+	return res, nil
+}
+
+// LocateKey returns the position of a key on the ring and its replicas in
+// order of preference, along with the stores serving the replicas that are down
+func (c *Coordinator) LocateKey(ctx context.Context, in *pb_coordinator.LocateKeyRequest) (*pb_coordinator.LocateKeyResponse, error) {
+	c.mu.RLock()
+	stores, err := c.hashRing.GetStores(in.Key, c.replication.Replicas)
+	c.mu.RUnlock()
+
+	res := &pb_coordinator.LocateKeyResponse{Hash: hashKey(in.Key)}
+	if err != nil {
+		// an empty ring has no replicas
+		return res, nil
+	}
+
+	servedBy := make(map[*StoreClient]string)
+	if p, err := c.place(in.Key); err == nil {
+		for _, t := range p.targets {
+			servedBy[t.replica] = t.store.name
+		}
+	}
+
+	res.Owner = stores[0].name
+	for _, s := range stores {
+		res.Replicas = append(res.Replicas, &pb_coordinator.KeyReplica{
+			Name:     s.name,
+			Address:  s.address,
+			State:    s.State().String(),
+			ServedBy: servedBy[s],
+		})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,277 @@
+package coordinator
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	"github.com/priyansh32/nebula/internal/auth"
+	"github.com/priyansh32/nebula/internal/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LeaseConfig controls how long a self-registered store stays in the ring
+// without sending a heartbeat
+type LeaseConfig struct {
+	TTL time.Duration
+}
+
+func DefaultLeaseConfig() LeaseConfig {
+	return LeaseConfig{
+		TTL: 10 * time.Second,
+	}
+}
+
+// lease tracks the expiry of a self-registered store
+// stores added through AddStore hold no lease and never expire
+type lease struct {
+	mu     sync.Mutex
+	held   bool
+	expiry time.Time
+
+	// owner is the user the store registered as, only that user may renew
+	// the lease or deregister the store. It is empty if requests are not
+	// authenticated and for stores added through AddStore.
+	owner string
+}
+
+func (s *StoreClient) renewLease(ttl time.Duration) {
+	s.lease.mu.Lock()
+	defer s.lease.mu.Unlock()
+
+	s.lease.held = true
+	s.lease.expiry = time.Now().Add(ttl)
+}
+
+// grants a lease to a store that registered as owner
+func (s *StoreClient) grantLease(owner string, ttl time.Duration) {
+	s.lease.mu.Lock()
+	s.lease.owner = owner
+	s.lease.mu.Unlock()
+
+	s.renewLease(ttl)
+}
+
+func (s *StoreClient) leaseOwner() string {
+	s.lease.mu.Lock()
+	defer s.lease.mu.Unlock()
+
+	return s.lease.owner
+}
+
+// fails with PermissionDenied unless the store registered as user
+func (s *StoreClient) checkOwner(user string) error {
+	if owner := s.leaseOwner(); owner != user {
+		return status.Errorf(codes.PermissionDenied, "store %s is not registered as %s", s.name, user)
+	}
+	return nil
+}
+
+func (s *StoreClient) leaseHeld() bool {
+	s.lease.mu.Lock()
+	defer s.lease.mu.Unlock()
+
+	return s.lease.held
+}
+
+func (s *StoreClient) leaseExpired(now time.Time) bool {
+	s.lease.mu.Lock()
+	defer s.lease.mu.Unlock()
+
+	return s.lease.held && now.After(s.lease.expiry)
+}
+
+// RegisterStore adds a store to the ring on behalf of the store itself
+// registering again with the same address only renews the lease. The
+// store is bound to the user it registers as, so that the credentials of
+// one store cannot take over the name or lease of another.
+func (c *Coordinator) RegisterStore(ctx context.Context, in *pb_coordinator.RegisterStoreRequest) (*pb_coordinator.RegisterStoreResponse, error) {
+	leader, fwd, release, err := c.forwardTo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if leader != nil {
+		return leader.RegisterStore(fwd, in)
+	}
+
+	c.mu.RLock()
+	s, ok := c.storeClients[in.Name]
+	c.mu.RUnlock()
+
+	user := auth.UserName(ctx)
+	if ok && s.address != in.Address {
+		return nil, status.Errorf(codes.AlreadyExists, "store %s is registered with address %s", in.Name, s.address)
+	}
+	if ok {
+		if err := s.checkOwner(user); err != nil {
+			return nil, err
+		}
+	}
+
+	if !ok {
+		err := c.changeMembership(membershipChange{
+			Op:        opAddStore,
+			Name:      in.Name,
+			Address:   in.Address,
+			Positions: c.positionsFor(in.Name, 0),
+			Leased:    true,
+			Owner:     user,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.RLock()
+	s, ok = c.storeClients[in.Name]
+	ttl := c.leaseTTL
+	c.mu.RUnlock()
+
+	if !ok {
+		return nil, status.Errorf(codes.Aborted, "store %s was removed while registering", in.Name)
+	}
+
+	s.renewLease(ttl)
+
+	return &pb_coordinator.RegisterStoreResponse{
+		Status:     pb_coordinator.StatusType_OK,
+		LeaseTtlMs: ttl.Milliseconds(),
+	}, nil
+}
+
+// Heartbeat renews the lease of a registered store
+// unknown stores get NotFound so that they register again, stores
+// registered as another user get PermissionDenied
+func (c *Coordinator) Heartbeat(ctx context.Context, in *pb_coordinator.HeartbeatRequest) (*pb_coordinator.HeartbeatResponse, error) {
+	leader, fwd, release, err := c.forwardTo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if leader != nil {
+		return leader.Heartbeat(fwd, in)
+	}
+
+	c.mu.RLock()
+	s, ok := c.storeClients[in.Name]
+	ttl := c.leaseTTL
+	c.mu.RUnlock()
+
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "store %s is not registered", in.Name)
+	}
+	if err := s.checkOwner(auth.UserName(ctx)); err != nil {
+		return nil, err
+	}
+
+	s.renewLease(ttl)
+
+	return &pb_coordinator.HeartbeatResponse{
+		Status: pb_coordinator.StatusType_OK,
+	}, nil
+}
+
+// DeregisterStore removes a store on behalf of the store itself, a store
+// may only remove itself while admins remove any store with RemoveStore
+func (c *Coordinator) DeregisterStore(ctx context.Context, in *pb_coordinator.DeregisterStoreRequest) (*pb_coordinator.DeregisterStoreResponse, error) {
+	leader, fwd, release, err := c.forwardTo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if leader != nil {
+		return leader.DeregisterStore(fwd, in)
+	}
+
+	c.mu.RLock()
+	s, ok := c.storeClients[in.Name]
+	c.mu.RUnlock()
+
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "store %s is not registered", in.Name)
+	}
+	if !s.leaseHeld() {
+		return nil, status.Errorf(codes.PermissionDenied, "store %s was not registered by itself", in.Name)
+	}
+	if err := s.checkOwner(auth.UserName(ctx)); err != nil {
+		return nil, err
+	}
+
+	if err := c.changeMembership(membershipChange{Op: opRemoveStore, Name: in.Name}); err != nil {
+		return nil, err
+	}
+
+	return &pb_coordinator.DeregisterStoreResponse{
+		Status: pb_coordinator.StatusType_OK,
+	}, nil
+}
+
+// StartLeaseExpiry removes self-registered stores whose lease lapsed until ctx is done
+func (c *Coordinator) StartLeaseExpiry(ctx context.Context, cfg LeaseConfig) {
+	if cfg.TTL <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	c.leaseTTL = cfg.TTL
+	c.mu.Unlock()
+
+	// stores restored from the state file or raft registered before leases
+	// were tracked by this process
+	c.grantLeases()
+
+	go func() {
+		ticker := time.NewTicker(cfg.TTL / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				c.expireLeases(now)
+			}
+		}
+	}()
+}
+
+// renews the lease of every self-registered store, for a coordinator that
+// has not seen their heartbeats such as one that just became raft leader
+func (c *Coordinator) grantLeases() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, s := range c.storeClients {
+		if s.leaseHeld() {
+			s.renewLease(c.leaseTTL)
+		}
+	}
+}
+
+func (c *Coordinator) expireLeases(now time.Time) {
+	// with raft only the leader receives heartbeats
+	if !c.isLeader() {
+		return
+	}
+
+	c.mu.RLock()
+	expired := make([]string, 0)
+	for name, s := range c.storeClients {
+		if s.leaseExpired(now) {
+			expired = append(expired, name)
+		}
+	}
+	c.mu.RUnlock()
+
+	for _, name := range expired {
+		logging.Logger("lease").Warn("lease of a store expired", "store", name)
+		err := c.changeMembership(membershipChange{Op: opRemoveStore, Name: name})
+		if err != nil {
+			logging.Logger("lease").Error("failed to remove a store", "store", name, "error", err)
+		}
+		c.auditSystem(systemRecord("ExpireLease", name, err))
+	}
+}
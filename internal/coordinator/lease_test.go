@@ -0,0 +1,152 @@
+package coordinator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	"github.com/priyansh32/nebula/internal/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// returns an authorizer whose users are named after their tokens, each
+// user in admins has an admin role and every other one a cluster role
+func clusterUsers(t *testing.T, users []string, admins []string) *auth.Authorizer {
+	t.Helper()
+
+	entries := make([]string, 0, len(users)+len(admins))
+	for _, name := range append(users, admins...) {
+		role := "store"
+		for _, admin := range admins {
+			if name == admin {
+				role = "admin"
+			}
+		}
+		sum := sha256.Sum256([]byte(name))
+		entries = append(entries, fmt.Sprintf(`{"name": %q, "token_sha256": %q, "roles": [%q]}`, name, hex.EncodeToString(sum[:]), role))
+	}
+	rules := fmt.Sprintf(`{"roles": {"store": {"cluster": true}, "admin": {"admin": true}}, "users": [%s]}`, strings.Join(entries, ", "))
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(rules), 0600); err != nil {
+		t.Fatal(err)
+	}
+	authorizer, err := auth.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authorizer
+}
+
+func TestStoreOwnership(t *testing.T) {
+	type call struct {
+		user   string
+		method string
+		store  string
+		want   codes.Code
+	}
+
+	tests := []struct {
+		name  string
+		calls []call
+	}{
+		{
+			name: "a store renews and removes itself",
+			calls: []call{
+				{user: "alpha", method: "RegisterStore", store: "a", want: codes.OK},
+				{user: "alpha", method: "RegisterStore", store: "a", want: codes.OK},
+				{user: "alpha", method: "Heartbeat", store: "a", want: codes.OK},
+				{user: "alpha", method: "DeregisterStore", store: "a", want: codes.OK},
+				{user: "alpha", method: "Heartbeat", store: "a", want: codes.NotFound},
+			},
+		},
+		{
+			name: "another store cannot take over the name or lease",
+			calls: []call{
+				{user: "alpha", method: "RegisterStore", store: "a", want: codes.OK},
+				{user: "beta", method: "RegisterStore", store: "a", want: codes.PermissionDenied},
+				{user: "beta", method: "Heartbeat", store: "a", want: codes.PermissionDenied},
+				{user: "beta", method: "DeregisterStore", store: "a", want: codes.PermissionDenied},
+				{user: "alpha", method: "Heartbeat", store: "a", want: codes.OK},
+			},
+		},
+		{
+			name: "only admins remove any store",
+			calls: []call{
+				{user: "alpha", method: "RegisterStore", store: "a", want: codes.OK},
+				{user: "alpha", method: "RemoveStore", store: "a", want: codes.PermissionDenied},
+				{user: "root", method: "RemoveStore", store: "a", want: codes.OK},
+				{user: "alpha", method: "Heartbeat", store: "a", want: codes.NotFound},
+			},
+		},
+		{
+			name: "stores added by admins are not deregistered by stores",
+			calls: []call{
+				{user: "root", method: "AddStore", store: "a", want: codes.OK},
+				{user: "alpha", method: "DeregisterStore", store: "a", want: codes.PermissionDenied},
+				{user: "alpha", method: "RegisterStore", store: "a", want: codes.PermissionDenied},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCoordinator(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.leaseTTL = DefaultLeaseConfig().TTL
+			t.Cleanup(func() {
+				c.mu.Lock()
+				defer c.mu.Unlock()
+				for name := range c.storeClients {
+					c.removeStore(name)
+				}
+			})
+
+			intercept := clusterUsers(t, []string{"alpha", "beta"}, []string{"root"}).UnaryInterceptor(methodAccess)
+			requests := map[string]func(ctx context.Context, store string) error{
+				"AddStore": func(ctx context.Context, store string) error {
+					_, err := c.AddStore(ctx, &pb_coordinator.AddStoreRequest{Name: store, Address: "127.0.0.1:1"})
+					return err
+				},
+				"RemoveStore": func(ctx context.Context, store string) error {
+					_, err := c.RemoveStore(ctx, &pb_coordinator.RemoveStoreRequest{Name: store})
+					return err
+				},
+				"RegisterStore": func(ctx context.Context, store string) error {
+					_, err := c.RegisterStore(ctx, &pb_coordinator.RegisterStoreRequest{Name: store, Address: "127.0.0.1:1"})
+					return err
+				},
+				"Heartbeat": func(ctx context.Context, store string) error {
+					_, err := c.Heartbeat(ctx, &pb_coordinator.HeartbeatRequest{Name: store})
+					return err
+				},
+				"DeregisterStore": func(ctx context.Context, store string) error {
+					_, err := c.DeregisterStore(ctx, &pb_coordinator.DeregisterStoreRequest{Name: store})
+					return err
+				},
+			}
+
+			for _, call := range tt.calls {
+				info := &grpc.UnaryServerInfo{FullMethod: apiMethod(call.method)}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return nil, requests[call.method](ctx, call.store)
+				}
+
+				_, err := intercept(auth.WithToken(context.Background(), call.user), nil, info, handler)
+				if got := status.Code(err); got != call.want {
+					t.Fatalf("%s %s as %s = %v, want %s", call.method, call.store, call.user, err, call.want)
+				}
+			}
+		})
+	}
+}
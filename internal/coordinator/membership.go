@@ -0,0 +1,156 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	"github.com/priyansh32/nebula/internal/logging"
+)
+
+const (
+	opAddStore       = "add_store"
+	opRemoveStore    = "remove_store"
+	opAddCoordinator = "add_coordinator"
+)
+
+// membershipChange is a change to the ring or to the set of coordinators
+// positions are picked before the change is made so that applying it is
+// deterministic, which lets it be replicated through raft
+type membershipChange struct {
+	Op        string   `json:"op"`
+	Name      string   `json:"name,omitempty"`
+	Address   string   `json:"address,omitempty"`
+	Positions []uint64 `json:"positions,omitempty"`
+
+	// Leased is set for stores that registered themselves and expire
+	// without heartbeats
+	Leased bool `json:"leased,omitempty"`
+
+	// Owner is the user a leased store registered as
+	Owner string `json:"owner,omitempty"`
+
+	// set for coordinators joining a raft cluster
+	RaftAddress string `json:"raft_address,omitempty"`
+
+	// index is the raft log index of a replicated change, which becomes
+	// the ring version so that every coordinator reports the same one
+	index uint64
+}
+
+// makes a membership change, through the raft log when raft is enabled
+func (c *Coordinator) changeMembership(change membershipChange) error {
+	if c.replicator != nil {
+		return c.replicator.propose(change)
+	}
+	return c.applyChange(change)
+}
+
+// returns a client for the raft leader and the context to forward the
+// request of ctx to it with when membership changes must be forwarded, or
+// nil when this coordinator can make them itself, along with a function to
+// call once done with the client
+func (c *Coordinator) forwardTo(ctx context.Context) (pb_coordinator.CoordinatorAPIClient, context.Context, func(), error) {
+	if c.replicator == nil {
+		return nil, ctx, func() {}, nil
+	}
+	return c.replicator.leaderClient(ctx)
+}
+
+// reports whether this coordinator makes membership changes
+func (c *Coordinator) isLeader() bool {
+	return c.replicator == nil || c.replicator.isLeader()
+}
+
+// applies a membership change to the local ring
+func (c *Coordinator) applyChange(change membershipChange) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	switch change.Op {
+	case opAddStore:
+		var s *StoreClient
+		s, err = c.addStore(change.Name, change.Address, change.Positions)
+		if err == nil && change.Leased {
+			s.grantLease(change.Owner, c.leaseTTL)
+		}
+	case opRemoveStore:
+		err = c.removeStore(change.Name)
+	default:
+		err = errors.New("unknown membership change " + change.Op)
+	}
+
+	// stores learnt through gossip bump the version locally, it never
+	// goes back when they are mixed with replicated changes
+	if err == nil && change.index > 0 {
+		c.ringVersion = max(c.ringVersion, change.index)
+	}
+
+	if err == nil && c.stateFile != "" {
+		if err := c.saveState(); err != nil {
+			logging.Logger("coordinator").Error("failed to save the state file", "path", c.stateFile, "error", err)
+		}
+	}
+
+	return err
+}
+
+// storeRecord is a store as it is persisted and replicated
+type storeRecord struct {
+	Name      string   `json:"name"`
+	Address   string   `json:"address"`
+	Positions []uint64 `json:"positions"`
+	Leased    bool     `json:"leased,omitempty"`
+	Owner     string   `json:"owner,omitempty"`
+}
+
+// returns the stores that are persisted and replicated, c.mu must be held
+// stores learnt through gossip are local to each coordinator and left out
+func (c *Coordinator) storeRecords() []storeRecord {
+	records := make([]storeRecord, 0, len(c.storeClients))
+	for name, s := range c.storeClients {
+		if c.gossipStores[name] {
+			continue
+		}
+		records = append(records, storeRecord{
+			Name:      name,
+			Address:   s.address,
+			Positions: append([]uint64(nil), s.nodeKeys...),
+			Leased:    s.leaseHeld(),
+			Owner:     s.leaseOwner(),
+		})
+	}
+	return records
+}
+
+// replaces the persisted and replicated stores with records, c.mu must be held
+func (c *Coordinator) restoreStores(records []storeRecord) {
+	for name := range c.storeClients {
+		if !c.gossipStores[name] {
+			c.removeStore(name)
+		}
+	}
+	for _, r := range records {
+		s, err := c.addStore(r.Name, r.Address, r.Positions)
+		if err != nil {
+			logging.Logger("raft").Error("failed to restore a store", "store", r.Name, "error", err)
+			continue
+		}
+		// heartbeats sent before the restore were not seen, so restored
+		// stores get a whole lease to send the next one
+		if r.Leased {
+			s.grantLease(r.Owner, c.leaseTTL)
+		}
+	}
+}
+
+// picks the ring positions of a new store, weight is the number of
+// positions and defaults to the replication factor
+func (c *Coordinator) positionsFor(name string, weight uint32) []uint64 {
+	count := c.hashRing.replicationFactor
+	if weight > 0 {
+		count = int(weight)
+	}
+	return c.hashRing.NewPositions(name, count)
+}
@@ -0,0 +1,98 @@
+package coordinator
+
+import (
+	"github.com/priyansh32/nebula/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	ringStoresDesc = prometheus.NewDesc("nebula_coordinator_ring_stores",
+		"Stores in the ring.", nil, nil)
+	ringVnodesDesc = prometheus.NewDesc("nebula_coordinator_ring_vnodes",
+		"Positions of stores on the ring.", nil, nil)
+	ringVersionDesc = prometheus.NewDesc("nebula_coordinator_ring_version",
+		"Version of the ring, bumped on every change.", nil, nil)
+	storeStateDesc = prometheus.NewDesc("nebula_coordinator_store_state",
+		"Health of every store, 1 for its current state.", []string{"store", "state"}, nil)
+	storeVnodesDesc = prometheus.NewDesc("nebula_coordinator_store_vnodes",
+		"Positions of every store on the ring.", []string{"store"}, nil)
+	breakerStateDesc = prometheus.NewDesc("nebula_coordinator_breaker_state",
+		"Circuit breaker of every store, 1 for its current state.", []string{"store", "state"}, nil)
+	hintsDesc = prometheus.NewDesc("nebula_coordinator_hints",
+		"Hinted writes waiting to be replayed to their store.", nil, nil)
+)
+
+// clusterCollector reads the ring and the health of the stores when scraped
+type clusterCollector struct {
+	c *Coordinator
+}
+
+func (cc clusterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ringStoresDesc
+	ch <- ringVnodesDesc
+	ch <- ringVersionDesc
+	ch <- storeStateDesc
+	ch <- storeVnodesDesc
+	ch <- breakerStateDesc
+	ch <- hintsDesc
+}
+
+func (cc clusterCollector) Collect(ch chan<- prometheus.Metric) {
+	c := cc.c
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ch <- prometheus.MustNewConstMetric(ringStoresDesc, prometheus.GaugeValue, float64(len(c.storeClients)))
+	ch <- prometheus.MustNewConstMetric(ringVnodesDesc, prometheus.GaugeValue, float64(len(c.hashRing.sortedKeys)))
+	ch <- prometheus.MustNewConstMetric(ringVersionDesc, prometheus.GaugeValue, float64(c.ringVersion))
+	ch <- prometheus.MustNewConstMetric(hintsDesc, prometheus.GaugeValue, float64(c.hints.len()))
+
+	for name, s := range c.storeClients {
+		ch <- prometheus.MustNewConstMetric(storeVnodesDesc, prometheus.GaugeValue, float64(len(s.nodeKeys)), name)
+
+		current := s.State()
+		for _, state := range []StoreState{StoreUp, StoreSuspect, StoreDown} {
+			ch <- prometheus.MustNewConstMetric(storeStateDesc, prometheus.GaugeValue, boolValue(state == current), name, state.String())
+		}
+
+		breaker, _, _ := s.breaker.snapshot()
+		for _, state := range []BreakerState{BreakerClosed, BreakerOpen, BreakerHalfOpen} {
+			ch <- prometheus.MustNewConstMetric(breakerStateDesc, prometheus.GaugeValue, boolValue(state == breaker), name, state.String())
+		}
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// registers the metrics of the coordinator with reg and starts counting the
+// requests sent to every store, it must be called before stores are added
+func (c *Coordinator) registerMetrics(reg prometheus.Registerer) {
+	c.storeMetrics = metrics.NewClientMetrics(reg, "coordinator", "store")
+
+	counter := func(name string, help string, read func() uint64) prometheus.CounterFunc {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "nebula",
+			Subsystem: "coordinator",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return float64(read()) })
+	}
+
+	reg.MustRegister(
+		clusterCollector{c},
+		counter("divergent_reads_total", "Reads whose replicas disagreed.", func() uint64 { return c.RepairStats().DivergentReads }),
+		counter("read_repairs_total", "Stale replicas sent the latest version by read repair.", func() uint64 { return c.RepairStats().Repairs }),
+		counter("read_repair_failures_total", "Stale replicas read repair failed to update.", func() uint64 { return c.RepairStats().Failures }),
+		counter("hedged_reads_total", "Reads also sent to a second replica.", func() uint64 { return c.HedgeStats().Hedges }),
+		counter("hedged_read_wins_total", "Reads answered by a replica other than the first.", func() uint64 { return c.HedgeStats().Wins }),
+		counter("near_cache_hits_total", "Reads of cached keys answered by the near cache.", func() uint64 { return c.NearCacheStats().Hits }),
+		counter("near_cache_misses_total", "Reads of cached keys the near cache had no value for.", func() uint64 { return c.NearCacheStats().Misses }),
+		counter("near_cache_invalidations_total", "Values of the near cache dropped by writes.", func() uint64 { return c.NearCacheStats().Invalidations }),
+	)
+}
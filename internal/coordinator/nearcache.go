@@ -0,0 +1,313 @@
+package coordinator
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	"github.com/priyansh32/nebula/internal/logging"
+)
+
+// admission policies of the near cache
+const (
+	// AdmitHot caches the keys the stores report as the hottest
+	AdmitHot = "hot"
+
+	// AdmitAll caches every key read
+	AdmitAll = "all"
+)
+
+// NearCacheConfig controls the cache the coordinator keeps of the values
+// of hot keys, which answers reads of those keys without asking the stores
+// holding them. Writes through the coordinator invalidate the keys they
+// change, writes through other coordinators or straight to the stores are
+// only seen once TTL expires.
+type NearCacheConfig struct {
+	Enabled bool
+
+	// Size is the number of keys cached, the least recently used are evicted
+	Size int
+
+	// TTL is how long a value is served from the cache
+	TTL time.Duration
+
+	// Admission selects the keys cached, AdmitHot or AdmitAll
+	Admission string
+
+	// HotKeys is the number of the hottest keys of the cluster that are
+	// cached with AdmitHot, they are asked from the stores every HotInterval
+	HotKeys     int
+	HotInterval time.Duration
+}
+
+func DefaultNearCacheConfig() NearCacheConfig {
+	return NearCacheConfig{
+		Size:        1024,
+		TTL:         time.Second,
+		Admission:   AdmitHot,
+		HotKeys:     16,
+		HotInterval: 5 * time.Second,
+	}
+}
+
+func (cfg NearCacheConfig) validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Size < 1 {
+		return errors.New("near cache size must be greater than 0")
+	}
+	if cfg.TTL <= 0 {
+		return errors.New("near cache ttl must be greater than 0")
+	}
+	switch cfg.Admission {
+	case AdmitAll:
+	case AdmitHot:
+		if cfg.HotKeys < 1 {
+			return errors.New("the number of hot keys cached must be greater than 0")
+		}
+		if cfg.HotInterval <= 0 {
+			return errors.New("the interval between hot key updates must be greater than 0")
+		}
+	default:
+		return errors.New("unknown near cache admission policy " + cfg.Admission + ", use hot or all")
+	}
+	return nil
+}
+
+// NearCacheStats counts the reads answered by the near cache
+type NearCacheStats struct {
+	// Hits and Misses count the reads of cached keys, whether their value
+	// was in the cache or not
+	Hits   uint64
+	Misses uint64
+
+	// Invalidations counts the cached values dropped by writes
+	Invalidations uint64
+}
+
+// NearCacheStats returns the near cache counters
+func (c *Coordinator) NearCacheStats() NearCacheStats {
+	if c.nearCache == nil {
+		return NearCacheStats{}
+	}
+	return NearCacheStats{
+		Hits:          c.nearCache.hits.Load(),
+		Misses:        c.nearCache.misses.Load(),
+		Invalidations: c.nearCache.invalidations.Load(),
+	}
+}
+
+// nearCache is an LRU cache of the values of keys with a TTL. A write
+// leaves an invalidated entry holding its version, so that a read that
+// started before the write cannot cache the value it replaced.
+type nearCache struct {
+	cfg NearCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	hot     map[string]bool
+
+	// keys served from the cache since the hot keys were last updated,
+	// their reads no longer reach the stores so they stay hot
+	served map[string]bool
+
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+type nearEntry struct {
+	key         string
+	value       string
+	version     int64
+	invalidated bool
+	expires     time.Time
+
+	// when the value itself expires in unix milliseconds, 0 if never
+	expiresAt int64
+}
+
+func newNearCache(cfg NearCacheConfig) *nearCache {
+	return &nearCache{
+		cfg:     cfg,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		hot:     make(map[string]bool),
+		served:  make(map[string]bool),
+	}
+}
+
+// reports whether key may be cached, n.mu must be held
+func (n *nearCache) admits(key string) bool {
+	return n.cfg.Admission == AdmitAll || n.hot[key]
+}
+
+// returns the cached value of key and when it expires, if it has one
+// that has not expired
+func (n *nearCache) get(key string, now time.Time) (string, int64, bool) {
+	if n == nil {
+		return "", 0, false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.admits(key) {
+		return "", 0, false
+	}
+
+	e, ok := n.entries[key]
+	if !ok {
+		n.misses.Add(1)
+		return "", 0, false
+	}
+
+	entry := e.Value.(*nearEntry)
+	if entry.invalidated || now.After(entry.expires) {
+		n.misses.Add(1)
+		return "", 0, false
+	}
+
+	n.lru.MoveToFront(e)
+	n.served[key] = true
+	n.hits.Add(1)
+	return entry.value, entry.expiresAt, true
+}
+
+// caches the value of key read at the given version, unless a write of
+// the same or a later version went through since. A value that expires at
+// expiresAt, in unix milliseconds, is not cached past it.
+func (n *nearCache) fill(key string, value string, version int64, expiresAt int64, now time.Time) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.admits(key) {
+		return
+	}
+
+	if e, ok := n.entries[key]; ok {
+		entry := e.Value.(*nearEntry)
+		if entry.version >= version && !now.After(entry.expires) {
+			return
+		}
+	}
+
+	expires := now.Add(n.cfg.TTL)
+	if expiresAt != 0 && time.UnixMilli(expiresAt).Before(expires) {
+		expires = time.UnixMilli(expiresAt)
+	}
+	n.set(&nearEntry{key: key, value: value, version: version, expires: expires, expiresAt: expiresAt})
+}
+
+// drops the cached value of key written at the given version
+func (n *nearCache) invalidate(key string, version int64, now time.Time) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, cached := n.entries[key]
+	if !cached && !n.admits(key) {
+		return
+	}
+	if cached {
+		n.invalidations.Add(1)
+	}
+
+	// reads that started before the write must not cache older values
+	// for as long as they could take
+	n.set(&nearEntry{key: key, version: version, invalidated: true, expires: now.Add(n.cfg.TTL)})
+}
+
+// replaces the entry of a key, evicting the least recently used entry if
+// the cache is full, n.mu must be held
+func (n *nearCache) set(entry *nearEntry) {
+	if e, ok := n.entries[entry.key]; ok {
+		e.Value = entry
+		n.lru.MoveToFront(e)
+		return
+	}
+
+	if n.lru.Len() >= n.cfg.Size {
+		oldest := n.lru.Back()
+		n.lru.Remove(oldest)
+		delete(n.entries, oldest.Value.(*nearEntry).key)
+	}
+	n.entries[entry.key] = n.lru.PushFront(entry)
+}
+
+// replaces the keys admitted with AdmitHot by the given keys and the keys
+// served from the cache since, dropping the values of keys no longer hot
+func (n *nearCache) setHot(keys []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	hot := n.served
+	for _, k := range keys {
+		hot[k] = true
+	}
+	n.hot = hot
+	n.served = make(map[string]bool)
+
+	for key, e := range n.entries {
+		if !hot[key] {
+			n.lru.Remove(e)
+			delete(n.entries, key)
+		}
+	}
+}
+
+// StartNearCache caches the values of keys read through the coordinator
+// as configured. With AdmitHot the hottest keys of the cluster are asked
+// from the stores every cfg.HotInterval until ctx is done.
+func (c *Coordinator) StartNearCache(ctx context.Context, cfg NearCacheConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	c.nearCache = newNearCache(cfg)
+	if cfg.Admission != AdmitHot {
+		return nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.HotInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				res, err := c.HotKeys(ctx, &pb_coordinator.HotKeysRequest{Count: uint32(cfg.HotKeys)})
+				if err != nil {
+					logging.Logger("nearcache").Warn("failed to get hot keys", "error", err)
+					continue
+				}
+
+				keys := make([]string, 0, len(res.Keys))
+				for _, k := range res.Keys {
+					keys = append(keys, k.Key)
+				}
+				c.nearCache.setHot(keys)
+			}
+		}
+	}()
+
+	return nil
+}
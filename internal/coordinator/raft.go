@@ -0,0 +1,450 @@
+package coordinator
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	"github.com/priyansh32/nebula/internal/auth"
+	"github.com/priyansh32/nebula/internal/logging"
+	"github.com/priyansh32/nebula/internal/tlsconfig"
+	"github.com/priyansh32/nebula/internal/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	raftApplyTimeout  = 10 * time.Second
+	raftJoinInterval  = time.Second
+	raftSnapshotsKept = 2
+)
+
+// RaftConfig makes a coordinator one of several that replicate the ring
+// through a raft log. Only the leader changes the ring, the other
+// coordinators forward membership changes to it and serve data requests
+// from their replica of the ring.
+type RaftConfig struct {
+	NodeID string
+
+	// BindAddress is the TCP address raft listens on
+	// AdvertiseAddress is the address other coordinators reach it at,
+	// it defaults to BindAddress
+	BindAddress      string
+	AdvertiseAddress string
+
+	// APIAddress is the gRPC address other coordinators forward requests to
+	APIAddress string
+
+	// DataDir holds the raft log and snapshots
+	DataDir string
+
+	// Bootstrap starts a new cluster with this coordinator as its only member
+	// Join is the gRPC address of any coordinator of an existing cluster
+	Bootstrap bool
+	Join      string
+}
+
+// replicator replicates membership changes through raft and applies
+// committed changes to the coordinator's ring
+type replicator struct {
+	c    *Coordinator
+	cfg  RaftConfig
+	raft *raft.Raft
+
+	mu sync.Mutex
+	// api addresses of the coordinators, keyed by raft address
+	coordinators map[string]string
+	leaderAddr   string
+	leaderConn   *leaderConn
+}
+
+// tlsStreamLayer carries raft traffic over TLS with the certificates of
+// the coordinator, verifying the other coordinators like gRPC does
+type tlsStreamLayer struct {
+	net.Listener
+	advertise net.Addr
+	client    *tls.Config
+}
+
+func newTLSStreamLayer(bind string, advertise net.Addr, creds *tlsconfig.Credentials) (*tlsStreamLayer, error) {
+	server, err := creds.ServerConfig()
+	if err != nil {
+		return nil, fmt.Errorf("raft over TLS: %w", err)
+	}
+	lis, err := tls.Listen("tcp", bind, server)
+	if err != nil {
+		return nil, err
+	}
+	return &tlsStreamLayer{Listener: lis, advertise: advertise, client: creds.ClientConfig()}, nil
+}
+
+func (l *tlsStreamLayer) Dial(address raft.ServerAddress, timeout time.Duration) (net.Conn, error) {
+	return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", string(address), l.client)
+}
+
+// Addr is the address other coordinators reach raft at
+func (l *tlsStreamLayer) Addr() net.Addr {
+	return l.advertise
+}
+
+// leaderConn is a connection to the raft leader, it is closed once the
+// leader changed and the requests still using it are done
+type leaderConn struct {
+	conn    *grpc.ClientConn
+	refs    int
+	retired bool
+}
+
+// StartRaft joins or bootstraps a raft cluster of coordinators
+// it must be called before the coordinator serves requests
+func (c *Coordinator) StartRaft(cfg RaftConfig) error {
+	if cfg.AdvertiseAddress == "" {
+		cfg.AdvertiseAddress = cfg.BindAddress
+	}
+
+	if err := os.MkdirAll(cfg.DataDir, 0700); err != nil {
+		return err
+	}
+
+	r := &replicator{
+		c:            c,
+		cfg:          cfg,
+		coordinators: make(map[string]string),
+	}
+
+	config := raft.DefaultConfig()
+	config.LocalID = raft.ServerID(cfg.NodeID)
+
+	logStore, err := raftboltdb.NewBoltStore(filepath.Join(cfg.DataDir, "raft.db"))
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := raft.NewFileSnapshotStore(cfg.DataDir, raftSnapshotsKept, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	advertise, err := net.ResolveTCPAddr("tcp", cfg.AdvertiseAddress)
+	if err != nil {
+		return err
+	}
+
+	// raft traffic is encrypted like gRPC when the coordinator serves TLS
+	var transport raft.Transport
+	if c.creds != nil {
+		stream, err := newTLSStreamLayer(cfg.BindAddress, advertise, c.creds)
+		if err != nil {
+			return err
+		}
+		transport = raft.NewNetworkTransport(stream, 3, 10*time.Second, os.Stderr)
+	} else {
+		transport, err = raft.NewTCPTransport(cfg.BindAddress, advertise, 3, 10*time.Second, os.Stderr)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.raft, err = raft.NewRaft(config, r, logStore, logStore, snapshots, transport)
+	if err != nil {
+		return err
+	}
+
+	c.replicator = r
+
+	if cfg.Bootstrap {
+		hasState, err := raft.HasExistingState(logStore, logStore, snapshots)
+		if err != nil {
+			return err
+		}
+
+		if !hasState {
+			err := r.raft.BootstrapCluster(raft.Configuration{
+				Servers: []raft.Server{{ID: config.LocalID, Address: transport.LocalAddr()}},
+			}).Error()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	go r.announceLeadership()
+
+	if cfg.Join != "" {
+		go r.join(cfg.Join)
+	}
+
+	return nil
+}
+
+// records this coordinator's api address whenever it becomes leader, so
+// that followers know where to forward changes
+func (r *replicator) announceLeadership() {
+	for isLeader := range r.raft.LeaderCh() {
+		if !isLeader {
+			continue
+		}
+
+		// heartbeats went to the previous leader
+		r.c.grantLeases()
+
+		r.mu.Lock()
+		known := r.coordinators[r.cfg.AdvertiseAddress] == r.cfg.APIAddress
+		r.mu.Unlock()
+
+		if known {
+			continue
+		}
+
+		err := r.propose(membershipChange{
+			Op:          opAddCoordinator,
+			Name:        r.cfg.NodeID,
+			Address:     r.cfg.APIAddress,
+			RaftAddress: r.cfg.AdvertiseAddress,
+		})
+		if err != nil {
+			logging.Logger("raft").Error("failed to announce leadership", "error", err)
+		}
+	}
+}
+
+// asks the cluster behind target to add this coordinator, retrying until it does
+func (r *replicator) join(target string) {
+	conn, err := grpc.Dial(target, r.c.creds.DialOption(), auth.TokenDialOption(r.c.authToken), tracing.DialOption(), logging.DialOption())
+	if err != nil {
+		logging.Logger("raft").Error("failed to dial a coordinator to join the raft cluster", "target", target, "error", err)
+		return
+	}
+	defer conn.Close()
+
+	client := pb_coordinator.NewCoordinatorAPIClient(conn)
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), raftApplyTimeout)
+		_, err := client.JoinCluster(ctx, &pb_coordinator.JoinClusterRequest{
+			NodeId:      r.cfg.NodeID,
+			RaftAddress: r.cfg.AdvertiseAddress,
+			ApiAddress:  r.cfg.APIAddress,
+		})
+		cancel()
+
+		if err == nil {
+			logging.Logger("raft").Info("joined the raft cluster", "target", target)
+			return
+		}
+
+		logging.Logger("raft").Warn("failed to join the raft cluster, retrying", "target", target, "error", err)
+		time.Sleep(raftJoinInterval)
+	}
+}
+
+// appends a change to the raft log and waits for it to be applied
+func (r *replicator) propose(change membershipChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	future := r.raft.Apply(data, raftApplyTimeout)
+	if err := future.Error(); err != nil {
+		if errors.Is(err, raft.ErrNotLeader) || errors.Is(err, raft.ErrLeadershipLost) {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		return err
+	}
+
+	if err, ok := future.Response().(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+func (r *replicator) isLeader() bool {
+	return r.raft.State() == raft.Leader
+}
+
+// returns a client for the leader and the context to forward the request
+// of ctx with, or nil if this coordinator is the leader, along with a
+// function to call once done with the client. Requests are forwarded with
+// the token of their caller, which the leader authenticates again. Callers
+// known by their client certificate are told to send the request to the
+// leader themselves, as the leader would see the certificate of this
+// coordinator instead.
+func (r *replicator) leaderClient(ctx context.Context) (pb_coordinator.CoordinatorAPIClient, context.Context, func(), error) {
+	if r.isLeader() {
+		return nil, ctx, func() {}, nil
+	}
+
+	raftAddr, _ := r.raft.LeaderWithID()
+	if raftAddr == "" {
+		return nil, nil, nil, status.Error(codes.Unavailable, "no raft leader")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	apiAddr, ok := r.coordinators[string(raftAddr)]
+	if !ok {
+		return nil, nil, nil, status.Error(codes.Unavailable, "address of the raft leader is not known yet")
+	}
+
+	fwd, ok := auth.Forward(ctx)
+	if !ok {
+		return nil, nil, nil, status.Errorf(codes.FailedPrecondition, "requests authenticated by a client certificate are not forwarded, send the request to the raft leader at %s", apiAddr)
+	}
+
+	if r.leaderAddr != apiAddr {
+		conn, err := grpc.Dial(apiAddr, r.c.creds.DialOption(), tracing.DialOption(), logging.DialOption())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		// requests forwarded to the previous leader may still be running
+		if prev := r.leaderConn; prev != nil {
+			prev.retired = true
+			if prev.refs == 0 {
+				prev.conn.Close()
+			}
+		}
+		r.leaderAddr, r.leaderConn = apiAddr, &leaderConn{conn: conn}
+	}
+
+	lc := r.leaderConn
+	lc.refs++
+
+	release := func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		lc.refs--
+		if lc.retired && lc.refs == 0 {
+			lc.conn.Close()
+		}
+	}
+	return pb_coordinator.NewCoordinatorAPIClient(lc.conn), fwd, release, nil
+}
+
+// Apply applies a committed membership change, it implements raft.FSM
+func (r *replicator) Apply(l *raft.Log) interface{} {
+	var change membershipChange
+	if err := json.Unmarshal(l.Data, &change); err != nil {
+		return err
+	}
+
+	if change.Op == opAddCoordinator {
+		r.mu.Lock()
+		r.coordinators[change.RaftAddress] = change.Address
+		r.mu.Unlock()
+		return nil
+	}
+
+	change.index = l.Index
+	return r.c.applyChange(change)
+}
+
+type replicatedState struct {
+	Stores       []storeRecord     `json:"stores"`
+	Coordinators map[string]string `json:"coordinators"`
+	RingVersion  uint64            `json:"ring_version"`
+}
+
+// Snapshot captures the replicated ring, it implements raft.FSM
+// stores learnt through gossip are local to each coordinator and left out
+func (r *replicator) Snapshot() (raft.FSMSnapshot, error) {
+	state := &replicatedState{Coordinators: make(map[string]string)}
+
+	r.c.mu.RLock()
+	state.Stores = r.c.storeRecords()
+	state.RingVersion = r.c.ringVersion
+	r.c.mu.RUnlock()
+
+	r.mu.Lock()
+	for raftAddr, apiAddr := range r.coordinators {
+		state.Coordinators[raftAddr] = apiAddr
+	}
+	r.mu.Unlock()
+
+	return state, nil
+}
+
+// Restore replaces the replicated ring with a snapshot, it implements raft.FSM
+func (r *replicator) Restore(snapshot io.ReadCloser) error {
+	defer snapshot.Close()
+
+	var state replicatedState
+	if err := json.NewDecoder(snapshot).Decode(&state); err != nil {
+		return err
+	}
+
+	r.c.mu.Lock()
+	r.c.restoreStores(state.Stores)
+	r.c.ringVersion = max(r.c.ringVersion, state.RingVersion)
+	r.c.mu.Unlock()
+
+	r.mu.Lock()
+	r.coordinators = state.Coordinators
+	if r.coordinators == nil {
+		r.coordinators = make(map[string]string)
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (s *replicatedState) Persist(sink raft.SnapshotSink) error {
+	if err := json.NewEncoder(sink).Encode(s); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *replicatedState) Release() {}
+
+// JoinCluster adds a coordinator to the raft cluster
+func (c *Coordinator) JoinCluster(ctx context.Context, in *pb_coordinator.JoinClusterRequest) (*pb_coordinator.JoinClusterResponse, error) {
+	if c.replicator == nil {
+		return nil, status.Error(codes.FailedPrecondition, "raft is not enabled on this coordinator")
+	}
+
+	leader, fwd, release, err := c.forwardTo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if leader != nil {
+		return leader.JoinCluster(fwd, in)
+	}
+
+	err = c.replicator.raft.AddVoter(raft.ServerID(in.NodeId), raft.ServerAddress(in.RaftAddress), 0, raftApplyTimeout).Error()
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.replicator.propose(membershipChange{
+		Op:          opAddCoordinator,
+		Name:        in.NodeId,
+		Address:     in.ApiAddress,
+		RaftAddress: in.RaftAddress,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb_coordinator.JoinClusterResponse{
+		Status: pb_coordinator.StatusType_OK,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package coordinator
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/hashicorp/raft"
+)
+
+// snapshotBuffer is a raft snapshot sink kept in memory
+type snapshotBuffer struct {
+	bytes.Buffer
+}
+
+func (s *snapshotBuffer) ID() string    { return "test" }
+func (s *snapshotBuffer) Cancel() error { return nil }
+func (s *snapshotBuffer) Close() error  { return nil }
+
+func TestReplicatedRingVersion(t *testing.T) {
+	type entry struct {
+		index  uint64
+		change membershipChange
+	}
+
+	tests := []struct {
+		name    string
+		entries []entry
+		want    uint64
+	}{
+		{
+			name: "added stores",
+			entries: []entry{
+				{index: 3, change: membershipChange{Op: opAddStore, Name: "a", Address: "127.0.0.1:1"}},
+				{index: 7, change: membershipChange{Op: opAddStore, Name: "b", Address: "127.0.0.1:2"}},
+			},
+			want: 7,
+		},
+		{
+			name: "coordinators do not change the ring",
+			entries: []entry{
+				{index: 3, change: membershipChange{Op: opAddStore, Name: "a", Address: "127.0.0.1:1"}},
+				{index: 4, change: membershipChange{Op: opAddCoordinator, Name: "c2", Address: "127.0.0.1:3", RaftAddress: "127.0.0.1:4"}},
+			},
+			want: 3,
+		},
+		{
+			name: "failed changes do not change the ring",
+			entries: []entry{
+				{index: 3, change: membershipChange{Op: opAddStore, Name: "a", Address: "127.0.0.1:1"}},
+				{index: 5, change: membershipChange{Op: opRemoveStore, Name: "missing"}},
+			},
+			want: 3,
+		},
+		{
+			name: "removed store",
+			entries: []entry{
+				{index: 3, change: membershipChange{Op: opAddStore, Name: "a", Address: "127.0.0.1:1"}},
+				{index: 9, change: membershipChange{Op: opRemoveStore, Name: "a"}},
+			},
+			want: 9,
+		},
+	}
+
+	// returns a coordinator with a replicator that is not running raft
+	replica := func(t *testing.T) *replicator {
+		c, err := NewCoordinator(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for name := range c.storeClients {
+				c.removeStore(name)
+			}
+		})
+		return &replicator{c: c, coordinators: make(map[string]string)}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := replica(t)
+			for _, e := range tt.entries {
+				data, err := json.Marshal(e.change)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r.Apply(&raft.Log{Index: e.index, Data: data})
+			}
+			if r.c.ringVersion != tt.want {
+				t.Errorf("ring version = %d, want %d", r.c.ringVersion, tt.want)
+			}
+
+			// a coordinator restored from a snapshot reports the same version
+			snapshot, err := r.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink := &snapshotBuffer{}
+			if err := snapshot.Persist(sink); err != nil {
+				t.Fatal(err)
+			}
+
+			restored := replica(t)
+			if err := restored.Restore(io.NopCloser(&sink.Buffer)); err != nil {
+				t.Fatal(err)
+			}
+			if restored.c.ringVersion != tt.want {
+				t.Errorf("restored ring version = %d, want %d", restored.c.ringVersion, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,735 @@
+package coordinator
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"hash/fnv"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	"github.com/priyansh32/nebula/internal/auth"
+	"github.com/priyansh32/nebula/internal/logging"
+	"github.com/priyansh32/nebula/internal/resp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// how long a client has to finish the TLS handshake
+const redisHandshakeTimeout = 10 * time.Second
+
+// the redis version reported to clients, the first one that speaks RESP3
+const redisVersion = "6.0.0"
+
+// commands of clients that did not authenticate yet are limited to this
+// many bytes, which is plenty for AUTH and HELLO
+const redisUnauthenticatedLimit = 16 * 1024
+
+// the most keys of an MGET read at the same time
+const redisMGetParallelism = 16
+
+// RedisConfig controls the frontend that serves the redis protocol, so
+// that clients written for redis can use the coordinator
+type RedisConfig struct {
+	// Address, if set, is the TCP address the frontend listens on. It is
+	// not started if empty.
+	Address string
+}
+
+// redisFrontend serves the redis protocol on behalf of a coordinator
+type redisFrontend struct {
+	c *Coordinator
+
+	// serialize the commands that read a key before writing it, such as
+	// SET NX and INCR, striped by key. They are only served when this is
+	// the only coordinator, as the locks do not hold for other ones.
+	locks [256]sync.Mutex
+
+	clients atomic.Int64
+}
+
+// StartRedis serves the redis protocol on cfg.Address, with TLS if the
+// coordinator serves TLS, until ctx is done. Commands are sent through the
+// interceptors of the gRPC server, so they are authenticated, audited and
+// kept in the slow log like the requests they turn into.
+func (c *Coordinator) StartRedis(ctx context.Context, cfg RedisConfig) error {
+	if cfg.Address == "" {
+		return nil
+	}
+
+	lis, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		return err
+	}
+	if c.creds != nil {
+		tlsCfg, err := c.creds.ServerConfig()
+		if err != nil {
+			lis.Close()
+			return err
+		}
+		lis = tls.NewListener(lis, tlsCfg)
+	}
+
+	logging.Logger("redis").Info("redis frontend listening", "address", lis.Addr().String())
+
+	go func() {
+		<-ctx.Done()
+		lis.Close()
+	}()
+
+	f := &redisFrontend{c: c}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if err != nil {
+				// such as running out of file descriptors
+				logging.Logger("redis").Error("failed to accept a connection", "error", err)
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			go f.serve(ctx, conn)
+		}
+	}()
+	return nil
+}
+
+// redisClient is a connection of a redis client
+type redisClient struct {
+	f    *redisFrontend
+	r    *resp.Reader
+	w    *resp.Writer
+	id   int64
+	name string
+	quit bool
+
+	// base is the context of the connection and ctx the one of its
+	// requests, which also holds the token it authenticated with
+	base context.Context
+	ctx  context.Context
+}
+
+// serves the commands of a client until it disconnects
+func (f *redisFrontend) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	p := &peer.Peer{Addr: conn.RemoteAddr()}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		hsCtx, cancel := context.WithTimeout(ctx, redisHandshakeTimeout)
+		err := tlsConn.HandshakeContext(hsCtx)
+		cancel()
+		if err != nil {
+			logging.Logger("redis").Warn("client failed the TLS handshake", "client", conn.RemoteAddr().String(), "error", err)
+			return
+		}
+		// as gRPC reports it, so that client certificates identify users
+		p.AuthInfo = credentials.TLSInfo{
+			State:          tlsConn.ConnectionState(),
+			CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+		}
+	}
+
+	cl := &redisClient{
+		f:    f,
+		r:    resp.NewReader(conn),
+		w:    resp.NewWriter(conn),
+		id:   f.clients.Add(1),
+		base: peer.NewContext(ctx, p),
+	}
+	cl.ctx = cl.base
+
+	if authorizer := f.c.authorizer; authorizer != nil {
+		if _, err := authorizer.Authenticate(cl.base); err != nil {
+			// until AUTH, clients that did not present a client certificate
+			cl.r.SetLimit(redisUnauthenticatedLimit)
+		}
+	}
+
+	for !cl.quit {
+		args, err := cl.r.ReadCommand()
+		if err != nil {
+			var protoErr resp.ProtocolError
+			if errors.As(err, &protoErr) {
+				cl.w.Error("ERR " + protoErr.Error())
+				cl.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		cl.exec(args)
+
+		// replies to pipelined commands are sent together
+		if !cl.r.Buffered() {
+			if err := cl.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+	cl.w.Flush()
+}
+
+// a command of the frontend, arity is its number of arguments including
+// its name, or minus the least number if it takes more
+type redisCommand struct {
+	run   func(cl *redisClient, args []string)
+	arity int
+}
+
+var redisCommands = map[string]redisCommand{
+	"get":    {(*redisClient).get, 2},
+	"set":    {(*redisClient).set, -3},
+	"del":    {(*redisClient).del, -2},
+	"mget":   {(*redisClient).mget, -2},
+	"mset":   {(*redisClient).mset, -3},
+	"exists": {(*redisClient).exists, -2},
+	"incr":   {(*redisClient).incr, 2},
+	"scan":   {(*redisClient).scan, -2},
+
+	"ping":    {(*redisClient).ping, -1},
+	"auth":    {(*redisClient).auth, -2},
+	"hello":   {(*redisClient).hello, -1},
+	"select":  {(*redisClient).selectDB, 2},
+	"client":  {(*redisClient).client, -2},
+	"command": {(*redisClient).command, -1},
+	"info":    {(*redisClient).info, -1},
+	"quit":    {(*redisClient).quitCmd, 1},
+}
+
+func (cl *redisClient) exec(args []string) {
+	name := strings.ToLower(args[0])
+	cmd, ok := redisCommands[name]
+	if !ok {
+		cl.w.Error("ERR unknown command '" + args[0] + "'")
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || len(args) < -cmd.arity {
+		cl.w.Error("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	// keys and values are protobuf strings
+	for _, arg := range args[1:] {
+		if !utf8.ValidString(arg) {
+			cl.w.Error("ERR keys and values must be valid UTF-8")
+			return
+		}
+	}
+
+	cmd.run(cl, args)
+}
+
+// writes err as a redis error, with the codes clients expect for failed
+// authentication and denied commands
+func (cl *redisClient) writeErr(err error) {
+	s := status.Convert(err)
+	switch s.Code() {
+	case codes.Unauthenticated:
+		cl.w.Error("NOAUTH " + s.Message())
+	case codes.PermissionDenied:
+		cl.w.Error("NOPERM " + s.Message())
+	default:
+		cl.w.Error("ERR " + s.Message())
+	}
+}
+
+// sends a request to the coordinator through the interceptors of its gRPC
+// server, as if it was the given method of the coordinator API
+func (cl *redisClient) invoke(method string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	info := &grpc.UnaryServerInfo{Server: cl.f.c, FullMethod: apiMethod(method)}
+
+	chain := handler
+	for i := len(cl.f.c.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := cl.f.c.interceptors[i], chain
+		chain = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, info, next)
+		}
+	}
+	return chain(logging.WithRequestID(cl.ctx, uuid.NewString()), req)
+}
+
+func (cl *redisClient) getKey(key string) (*pb_coordinator.GetResponse, error) {
+	res, err := cl.invoke("Get", &pb_coordinator.GetRequest{Key: key}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return cl.f.c.Get(ctx, req.(*pb_coordinator.GetRequest))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb_coordinator.GetResponse), nil
+}
+
+// reads a key from its replicas, bypassing the near cache, for the commands
+// that read a key before writing it
+func (cl *redisClient) getLatest(key string) (*pb_coordinator.GetResponse, error) {
+	res, err := cl.invoke("Get", &pb_coordinator.GetRequest{Key: key}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return cl.f.c.get(ctx, req.(*pb_coordinator.GetRequest).Key, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb_coordinator.GetResponse), nil
+}
+
+func (cl *redisClient) putKey(key string, value string, ttlMs int64) error {
+	_, err := cl.invoke("Put", &pb_coordinator.PutRequest{Key: key, Value: value, TtlMs: ttlMs}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return cl.f.c.Put(ctx, req.(*pb_coordinator.PutRequest))
+	})
+	return err
+}
+
+func (cl *redisClient) deleteKey(key string) error {
+	_, err := cl.invoke("Delete", &pb_coordinator.DeleteRequest{Key: key}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return cl.f.c.Delete(ctx, req.(*pb_coordinator.DeleteRequest))
+	})
+	return err
+}
+
+// reports whether other coordinators may write keys too, through raft or
+// gossip, in which case the frontend cannot serialize a read and a write
+func (f *redisFrontend) shared() bool {
+	f.c.mu.RLock()
+	defer f.c.mu.RUnlock()
+	return f.c.replicator != nil || f.c.gossiping
+}
+
+// locks key against the other commands of the frontend that write it,
+// writes through the gRPC API are not locked out
+func (f *redisFrontend) lock(key string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	mu := &f.locks[h.Sum32()%uint32(len(f.locks))]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// GET key
+func (cl *redisClient) get(args []string) {
+	res, err := cl.getKey(args[1])
+	if err != nil {
+		cl.writeErr(err)
+		return
+	}
+	if res.Status != pb_coordinator.StatusType_OK {
+		cl.w.Null()
+		return
+	}
+	cl.w.Bulk(res.Value)
+}
+
+// SET key value [NX|XX] [EX seconds|PX milliseconds]
+func (cl *redisClient) set(args []string) {
+	key, value := args[1], args[2]
+
+	var nx, xx bool
+	var ttl int64
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "NX" && !xx:
+			nx = true
+		case opt == "XX" && !nx:
+			xx = true
+		case (opt == "EX" || opt == "PX") && ttl == 0 && i+1 < len(args):
+			i++
+			n, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil {
+				cl.w.Error("ERR value is not an integer or out of range")
+				return
+			}
+			if n <= 0 || (opt == "EX" && n > math.MaxInt64/1000) {
+				cl.w.Error("ERR invalid expire time in 'set' command")
+				return
+			}
+			ttl = n
+			if opt == "EX" {
+				ttl = n * 1000
+			}
+		default:
+			cl.w.Error("ERR syntax error")
+			return
+		}
+	}
+
+	if (nx || xx) && cl.f.shared() {
+		cl.w.Error("ERR SET NX and XX are not supported with several coordinators")
+		return
+	}
+
+	unlock := cl.f.lock(key)
+	defer unlock()
+
+	if nx || xx {
+		res, err := cl.getLatest(key)
+		if err != nil {
+			cl.writeErr(err)
+			return
+		}
+		if exists := res.Status == pb_coordinator.StatusType_OK; exists == nx {
+			cl.w.Null()
+			return
+		}
+	}
+
+	if err := cl.putKey(key, value, ttl); err != nil {
+		cl.writeErr(err)
+		return
+	}
+	cl.w.SimpleString("OK")
+}
+
+// DEL key [key ...], replies with the number of keys that existed
+func (cl *redisClient) del(args []string) {
+	var deleted int64
+	for _, key := range args[1:] {
+		n, err := cl.delOne(key)
+		if err != nil {
+			cl.writeErr(err)
+			return
+		}
+		deleted += n
+	}
+	cl.w.Integer(deleted)
+}
+
+func (cl *redisClient) delOne(key string) (int64, error) {
+	unlock := cl.f.lock(key)
+	defer unlock()
+
+	res, err := cl.getLatest(key)
+	if err != nil {
+		return 0, err
+	}
+	if res.Status != pb_coordinator.StatusType_OK {
+		return 0, nil
+	}
+	return 1, cl.deleteKey(key)
+}
+
+// MGET key [key ...], up to redisMGetParallelism keys are read in parallel
+func (cl *redisClient) mget(args []string) {
+	keys := args[1:]
+	results := make([]*pb_coordinator.GetResponse, len(keys))
+	errs := make([]error, len(keys))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, redisMGetParallelism)
+	for i, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, key string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i], errs[i] = cl.getKey(key)
+		}(i, key)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			cl.writeErr(err)
+			return
+		}
+	}
+
+	cl.w.Array(len(keys))
+	for _, res := range results {
+		if res.Status != pb_coordinator.StatusType_OK {
+			cl.w.Null()
+			continue
+		}
+		cl.w.Bulk(res.Value)
+	}
+}
+
+// MSET key value [key value ...], the keys are written one after the
+// other, so other clients may see some of them written and not others
+func (cl *redisClient) mset(args []string) {
+	if len(args)%2 != 1 {
+		cl.w.Error("ERR wrong number of arguments for 'mset' command")
+		return
+	}
+
+	for i := 1; i < len(args); i += 2 {
+		unlock := cl.f.lock(args[i])
+		err := cl.putKey(args[i], args[i+1], 0)
+		unlock()
+		if err != nil {
+			cl.writeErr(err)
+			return
+		}
+	}
+	cl.w.SimpleString("OK")
+}
+
+// EXISTS key [key ...], keys given several times are counted every time
+func (cl *redisClient) exists(args []string) {
+	var n int64
+	for _, key := range args[1:] {
+		res, err := cl.getKey(key)
+		if err != nil {
+			cl.writeErr(err)
+			return
+		}
+		if res.Status == pb_coordinator.StatusType_OK {
+			n++
+		}
+	}
+	cl.w.Integer(n)
+}
+
+// INCR key, keeping the expiry of the key
+func (cl *redisClient) incr(args []string) {
+	key := args[1]
+
+	if cl.f.shared() {
+		cl.w.Error("ERR INCR is not supported with several coordinators")
+		return
+	}
+
+	unlock := cl.f.lock(key)
+	defer unlock()
+
+	res, err := cl.getLatest(key)
+	if err != nil {
+		cl.writeErr(err)
+		return
+	}
+
+	var n, ttl int64
+	if res.Status == pb_coordinator.StatusType_OK {
+		n, err = strconv.ParseInt(res.Value, 10, 64)
+		if err != nil {
+			cl.w.Error("ERR value is not an integer or out of range")
+			return
+		}
+		if res.ExpiresAtMs != 0 {
+			ttl = max(res.ExpiresAtMs-time.Now().UnixMilli(), 1)
+		}
+	}
+	if n == math.MaxInt64 {
+		cl.w.Error("ERR increment or decrement would overflow")
+		return
+	}
+	n++
+
+	if err := cl.putKey(key, strconv.FormatInt(n, 10), ttl); err != nil {
+		cl.writeErr(err)
+		return
+	}
+	cl.w.Integer(n)
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count], see Coordinator.Scan
+func (cl *redisClient) scan(args []string) {
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		cl.w.Error("ERR invalid cursor")
+		return
+	}
+
+	req := &pb_coordinator.ScanRequest{Cursor: cursor}
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			cl.w.Error("ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			req.Match = args[i+1]
+		case "COUNT":
+			count, err := strconv.ParseUint(args[i+1], 10, 32)
+			if err != nil || count == 0 {
+				cl.w.Error("ERR syntax error")
+				return
+			}
+			req.Count = uint32(count)
+		default:
+			cl.w.Error("ERR syntax error")
+			return
+		}
+	}
+
+	res, err := cl.invoke("Scan", req, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return cl.f.c.Scan(ctx, req.(*pb_coordinator.ScanRequest))
+	})
+	if err != nil {
+		cl.writeErr(err)
+		return
+	}
+	page := res.(*pb_coordinator.ScanResponse)
+
+	cl.w.Array(2)
+	cl.w.Bulk(strconv.FormatUint(page.Cursor, 10))
+	cl.w.Array(len(page.Keys))
+	for _, key := range page.Keys {
+		cl.w.Bulk(key)
+	}
+}
+
+// PING [message]
+func (cl *redisClient) ping(args []string) {
+	switch len(args) {
+	case 1:
+		cl.w.SimpleString("PONG")
+	case 2:
+		cl.w.Bulk(args[1])
+	default:
+		cl.w.Error("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+// AUTH [username] token
+func (cl *redisClient) auth(args []string) {
+	if len(args) > 3 {
+		cl.w.Error("ERR syntax error")
+		return
+	}
+	username := ""
+	if len(args) == 3 {
+		username = args[1]
+	}
+	if cl.authenticate(username, args[len(args)-1]) {
+		cl.w.SimpleString("OK")
+	}
+}
+
+// authenticates the client with a bearer token of the auth rules, the
+// password of redis. The username may be left out or be default. It
+// writes the reply and reports whether it succeeded.
+func (cl *redisClient) authenticate(username string, token string) bool {
+	authorizer := cl.f.c.authorizer
+	if authorizer == nil {
+		cl.w.Error("ERR AUTH called without any password configured for the default user. Are you sure your configuration is correct?")
+		return false
+	}
+
+	ctx := auth.WithToken(cl.base, token)
+	name, err := authorizer.Authenticate(ctx)
+	if err != nil || (username != "" && username != "default" && username != name) {
+		cl.w.Error("WRONGPASS invalid username-password pair or user is disabled.")
+		return false
+	}
+
+	cl.ctx = ctx
+	cl.r.SetLimit(0)
+	return true
+}
+
+// HELLO [protover [AUTH username token] [SETNAME name]]
+func (cl *redisClient) hello(args []string) {
+	version := cl.w.Version
+	if len(args) > 1 {
+		v, err := strconv.Atoi(args[1])
+		if err != nil {
+			cl.w.Error("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if v != 2 && v != 3 {
+			cl.w.Error("NOPROTO unsupported protocol version")
+			return
+		}
+		version = v
+	}
+
+	name := cl.name
+	var username, token string
+	authenticate := false
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "AUTH" && i+2 < len(args):
+			username, token, authenticate = args[i+1], args[i+2], true
+			i += 2
+		case opt == "SETNAME" && i+1 < len(args):
+			name = args[i+1]
+			i++
+		default:
+			cl.w.Error("ERR Syntax error in HELLO option '" + args[i] + "'")
+			return
+		}
+	}
+
+	if authenticate && !cl.authenticate(username, token) {
+		return
+	}
+	cl.name = name
+	cl.w.Version = version
+
+	cl.w.Map(7)
+	cl.w.Bulk("server")
+	cl.w.Bulk("nebula")
+	cl.w.Bulk("version")
+	cl.w.Bulk(redisVersion)
+	cl.w.Bulk("proto")
+	cl.w.Integer(int64(version))
+	cl.w.Bulk("id")
+	cl.w.Integer(cl.id)
+	cl.w.Bulk("mode")
+	cl.w.Bulk("standalone")
+	cl.w.Bulk("role")
+	cl.w.Bulk("master")
+	cl.w.Bulk("modules")
+	cl.w.Array(0)
+}
+
+// SELECT index, there is only database 0
+func (cl *redisClient) selectDB(args []string) {
+	if args[1] != "0" {
+		cl.w.Error("ERR DB index is out of range")
+		return
+	}
+	cl.w.SimpleString("OK")
+}
+
+// CLIENT SETNAME, GETNAME, ID and SETINFO, which client libraries send
+// when they connect
+func (cl *redisClient) client(args []string) {
+	switch sub := strings.ToUpper(args[1]); {
+	case sub == "SETNAME" && len(args) == 3:
+		cl.name = args[2]
+		cl.w.SimpleString("OK")
+	case sub == "GETNAME" && len(args) == 2:
+		if cl.name == "" {
+			cl.w.Null()
+			return
+		}
+		cl.w.Bulk(cl.name)
+	case sub == "ID" && len(args) == 2:
+		cl.w.Integer(cl.id)
+	case sub == "SETINFO" && len(args) == 4:
+		cl.w.SimpleString("OK")
+	default:
+		cl.w.Error("ERR unknown subcommand or wrong number of arguments for '" + args[1] + "'")
+	}
+}
+
+// COMMAND, which redis-cli asks for hints, describes no commands
+func (cl *redisClient) command(args []string) {
+	cl.w.Array(0)
+}
+
+// INFO, which some clients check before they are ready
+func (cl *redisClient) info(args []string) {
+	cl.w.Bulk("# Server\r\nredis_version:" + redisVersion + "\r\nredis_mode:standalone\r\nloading:0\r\n")
+}
+
+// QUIT, the connection is closed once the reply is sent
+func (cl *redisClient) quitCmd(args []string) {
+	cl.quit = true
+	cl.w.SimpleString("OK")
+}
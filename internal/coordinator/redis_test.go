@@ -0,0 +1,359 @@
+package coordinator
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/priyansh32/nebula/internal/auth"
+	"github.com/priyansh32/nebula/internal/store"
+)
+
+// redisError is an error reply, a step expecting one only checks that the
+// message starts with it
+type redisError string
+
+// closed is expected of a step whose connection the frontend closed
+type closed struct{}
+
+// redisConn is a client of the redis frontend, writing commands as arrays
+// of bulk strings and reading replies of either protocol version
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (rc *redisConn) do(args ...string) interface{} {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(rc.conn, b.String()); err != nil {
+		return closed{}
+	}
+	return rc.read()
+}
+
+func (rc *redisConn) read() interface{} {
+	line, err := rc.r.ReadString('\n')
+	if err != nil {
+		return closed{}
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return redisError(line[1:])
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n
+	case '_':
+		return nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(rc.r, data); err != nil {
+			return closed{}
+		}
+		return string(data[:n])
+	case '*', '%':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		if line[0] == '%' {
+			n *= 2
+		}
+		elems := make([]interface{}, n)
+		for i := range elems {
+			elems[i] = rc.read()
+		}
+		return elems
+	}
+	return redisError("unexpected reply " + line)
+}
+
+// reports whether a reply is the expected one
+func matches(got interface{}, want interface{}) bool {
+	if prefix, ok := want.(redisError); ok {
+		msg, ok := got.(redisError)
+		return ok && strings.HasPrefix(string(msg), string(prefix))
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+// serves the redis protocol for c and returns a function connecting to it
+func serveRedis(t *testing.T, c *Coordinator) func() *redisConn {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	f := &redisFrontend{c: c}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(context.Background(), conn)
+		}
+	}()
+
+	return func() *redisConn {
+		conn, err := net.Dial("tcp", lis.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	}
+}
+
+// makes c authenticate requests, with "secret" as the token of a user that
+// may read and write every key
+func withAuth(t *testing.T, c *Coordinator) {
+	t.Helper()
+
+	sum := sha256.Sum256([]byte("secret"))
+	rules := fmt.Sprintf(`{"roles": {"rw": {"read": [""], "write": [""]}}, "users": [{"name": "app", "token_sha256": "%s", "roles": ["rw"]}]}`, hex.EncodeToString(sum[:]))
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(rules), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	authorizer, err := auth.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.authorizer = authorizer
+	c.interceptors = append(c.interceptors, authorizer.UnaryInterceptor(methodAccess))
+}
+
+// a command and its expected reply, sent after waiting for after
+type step struct {
+	args  []string
+	want  interface{}
+	after time.Duration
+}
+
+func cmd(want interface{}, args ...string) step {
+	return step{args: args, want: want}
+}
+
+func TestRedisCommands(t *testing.T) {
+	var many []string
+	var manyValues []interface{}
+	for i := 0; i < 3*redisMGetParallelism; i++ {
+		many = append(many, fmt.Sprintf("key%d", i))
+		manyValues = append(manyValues, strconv.Itoa(i))
+	}
+	msetMany := []string{"MSET"}
+	for i, key := range many {
+		msetMany = append(msetMany, key, strconv.Itoa(i))
+	}
+	large := strings.Repeat("x", 2*redisUnauthenticatedLimit)
+
+	tests := []struct {
+		name   string
+		shared bool
+		auth   bool
+		steps  []step
+	}{
+		{
+			name: "set and get",
+			steps: []step{
+				cmd("OK", "SET", "k", "v"),
+				cmd("v", "GET", "k"),
+				cmd(nil, "GET", "missing"),
+				cmd(redisError("ERR syntax error"), "SET", "k", "v", "NX", "XX"),
+			},
+		},
+		{
+			name: "set with px expires",
+			steps: []step{
+				cmd("OK", "SET", "k", "v", "PX", "100"),
+				cmd("v", "GET", "k"),
+				{args: []string{"GET", "k"}, want: nil, after: 200 * time.Millisecond},
+			},
+		},
+		{
+			name: "set with ex",
+			steps: []step{
+				cmd("OK", "SET", "k", "v", "EX", "60"),
+				cmd("v", "GET", "k"),
+				cmd(redisError("ERR invalid expire time"), "SET", "k", "v", "EX", "0"),
+				cmd(redisError("ERR value is not an integer"), "SET", "k", "v", "EX", "soon"),
+			},
+		},
+		{
+			name: "set nx",
+			steps: []step{
+				cmd("OK", "SET", "k", "first", "NX"),
+				cmd(nil, "SET", "k", "second", "NX"),
+				cmd("first", "GET", "k"),
+			},
+		},
+		{
+			name: "set xx",
+			steps: []step{
+				cmd(nil, "SET", "k", "first", "XX"),
+				cmd(nil, "GET", "k"),
+				cmd("OK", "SET", "k", "first"),
+				cmd("OK", "SET", "k", "second", "XX"),
+				cmd("second", "GET", "k"),
+			},
+		},
+		{
+			name: "incr",
+			steps: []step{
+				cmd(int64(1), "INCR", "n"),
+				cmd(int64(2), "INCR", "n"),
+				cmd("2", "GET", "n"),
+				cmd("OK", "SET", "s", "abc"),
+				cmd(redisError("ERR value is not an integer"), "INCR", "s"),
+				cmd("OK", "SET", "big", "9223372036854775807"),
+				cmd(redisError("ERR increment or decrement would overflow"), "INCR", "big"),
+			},
+		},
+		{
+			name: "incr keeps the expiry",
+			steps: []step{
+				cmd("OK", "SET", "n", "1", "PX", "150"),
+				cmd(int64(2), "INCR", "n"),
+				{args: []string{"GET", "n"}, want: nil, after: 250 * time.Millisecond},
+			},
+		},
+		{
+			name: "mset and mget",
+			steps: []step{
+				cmd("OK", "MSET", "a", "1", "b", "2"),
+				cmd([]interface{}{"1", "2", nil}, "MGET", "a", "b", "c"),
+				cmd(redisError("ERR wrong number of arguments"), "MSET", "a", "1", "b"),
+			},
+		},
+		{
+			name: "mget of more keys than are read at once",
+			steps: []step{
+				cmd("OK", msetMany...),
+				cmd(manyValues, append([]string{"MGET"}, many...)...),
+			},
+		},
+		{
+			name: "scan with match",
+			steps: []step{
+				cmd("OK", "MSET", "user:1", "a", "order:1", "b"),
+				cmd([]interface{}{"0", []interface{}{"user:1"}}, "SCAN", "0", "MATCH", "user:*", "COUNT", "100"),
+				cmd([]interface{}{"0", []interface{}{}}, "SCAN", "0", "MATCH", "none:*"),
+				cmd(redisError("ERR invalid cursor"), "SCAN", "start"),
+			},
+		},
+		{
+			name:   "several coordinators",
+			shared: true,
+			steps: []step{
+				cmd(redisError("ERR SET NX and XX are not supported"), "SET", "k", "v", "NX"),
+				cmd(redisError("ERR SET NX and XX are not supported"), "SET", "k", "v", "XX"),
+				cmd(redisError("ERR INCR is not supported"), "INCR", "n"),
+				cmd("OK", "SET", "k", "v"),
+				cmd("v", "GET", "k"),
+			},
+		},
+		{
+			name: "authenticated",
+			auth: true,
+			steps: []step{
+				cmd(redisError("NOAUTH"), "GET", "k"),
+				cmd(redisError("WRONGPASS"), "AUTH", "wrong"),
+				cmd("OK", "AUTH", "secret"),
+				cmd("OK", "SET", "k", large),
+				cmd(large, "GET", "k"),
+			},
+		},
+		{
+			name: "large command before auth",
+			auth: true,
+			steps: []step{
+				cmd(redisError("ERR Protocol error: too big command"), "SET", "k", large),
+				cmd(closed{}, "PING"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := withStore(t, store.NewStore(1024), time.Second)
+			c.gossiping = tt.shared
+			if tt.auth {
+				withAuth(t, c)
+			}
+			rc := serveRedis(t, c)()
+
+			for _, s := range tt.steps {
+				time.Sleep(s.after)
+				if got := rc.do(s.args...); !matches(got, s.want) {
+					t.Fatalf("%s = %#v, want %#v", strings.Join(s.args, " "), got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisScanPages(t *testing.T) {
+	c := withStore(t, store.NewStore(1024), time.Second)
+	rc := serveRedis(t, c)()
+
+	want := make(map[string]bool)
+	args := []string{"MSET"}
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("key%d", i)
+		want[key] = true
+		args = append(args, key, "v")
+	}
+	if got := rc.do(args...); got != "OK" {
+		t.Fatalf("MSET = %#v", got)
+	}
+
+	seen := make(map[string]bool)
+	cursor := "0"
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatalf("scan did not end after %d pages", pages)
+		}
+
+		reply, ok := rc.do("SCAN", cursor, "COUNT", "4").([]interface{})
+		if !ok || len(reply) != 2 {
+			t.Fatalf("SCAN %s = %#v", cursor, reply)
+		}
+		for _, key := range reply[1].([]interface{}) {
+			seen[key.(string)] = true
+		}
+		if cursor = reply[0].(string); cursor == "0" {
+			break
+		}
+	}
+
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("scan returned %v, want %v", seen, want)
+	}
+}
@@ -0,0 +1,427 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+	"github.com/priyansh32/nebula/internal/logging"
+	"github.com/priyansh32/nebula/internal/slowlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ReplicationConfig controls how many stores hold each key and how many
+// of them must answer before a request succeeds
+type ReplicationConfig struct {
+	// Replicas is the number of stores holding each key, they are the
+	// first stores clockwise from the key on the ring
+	Replicas int
+
+	// ReadQuorum and WriteQuorum are the number of replicas that must
+	// answer a read and acknowledge a write
+	ReadQuorum  int
+	WriteQuorum int
+
+	// SyncReadRepair makes reads wait for every replica and repair the
+	// stale ones before answering, otherwise they are repaired in the background
+	SyncReadRepair bool
+}
+
+func DefaultReplicationConfig() ReplicationConfig {
+	return ReplicationConfig{
+		Replicas:    1,
+		ReadQuorum:  1,
+		WriteQuorum: 1,
+	}
+}
+
+func (cfg ReplicationConfig) validate() error {
+	if cfg.Replicas < 1 {
+		return errors.New("replicas must be greater than 0")
+	}
+	if cfg.ReadQuorum < 1 || cfg.ReadQuorum > cfg.Replicas {
+		return errors.New("read quorum must be between 1 and the number of replicas")
+	}
+	if cfg.WriteQuorum < 1 || cfg.WriteQuorum > cfg.Replicas {
+		return errors.New("write quorum must be between 1 and the number of replicas")
+	}
+	return nil
+}
+
+// RepairStats counts the work done by read repair
+type RepairStats struct {
+	// DivergentReads is the number of reads whose replicas disagreed
+	DivergentReads uint64
+
+	// Repairs is the number of stale replicas sent the winning version,
+	// Failures the number of those that could not be repaired
+	Repairs  uint64
+	Failures uint64
+}
+
+type repairCounters struct {
+	divergentReads atomic.Uint64
+	repairs        atomic.Uint64
+	failures       atomic.Uint64
+}
+
+// RepairStats returns the read repair counters
+func (c *Coordinator) RepairStats() RepairStats {
+	return RepairStats{
+		DivergentReads: c.repairs.divergentReads.Load(),
+		Repairs:        c.repairs.repairs.Load(),
+		Failures:       c.repairs.failures.Load(),
+	}
+}
+
+// target is a store a request for a key is sent to, in place of replica
+// when the replica is down
+type target struct {
+	store   *StoreClient
+	replica *StoreClient
+}
+
+// placement is where the replicas of a key are served from
+type placement struct {
+	targets  []target
+	replicas int
+
+	// live stores past the replicas, used in place of replicas that fail
+	mu     sync.Mutex
+	spares []*StoreClient
+}
+
+// takes the next spare store, nil when there are none left
+func (p *placement) spare() *StoreClient {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.spares) == 0 {
+		return nil
+	}
+	s := p.spares[0]
+	p.spares = p.spares[1:]
+	return s
+}
+
+// returns the number of answers needed for a quorum, the cluster may
+// have fewer stores than the configured replicas
+func (p *placement) quorum(want int) int {
+	if want > p.replicas {
+		return p.replicas
+	}
+	return want
+}
+
+// finds the stores that serve the given key: its replicas, with the ones
+// marked down replaced by the next live stores on the ring
+func (c *Coordinator) place(key string) (*placement, error) {
+	c.mu.RLock()
+	stores, err := c.hashRing.GetStores(key, len(c.storeClients))
+	replicas := c.replication.Replicas
+	c.mu.RUnlock()
+
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	if replicas > len(stores) {
+		replicas = len(stores)
+	}
+
+	p := &placement{replicas: replicas}
+	for _, s := range stores[replicas:] {
+		if s.State() != StoreDown {
+			p.spares = append(p.spares, s)
+		}
+	}
+
+	for _, s := range stores[:replicas] {
+		if s.State() != StoreDown {
+			p.targets = append(p.targets, target{store: s, replica: s})
+		} else if spare := p.spare(); spare != nil {
+			p.targets = append(p.targets, target{store: spare, replica: s})
+		}
+	}
+
+	if len(p.targets) == 0 {
+		return nil, status.Error(codes.Unavailable, "all stores for key are down")
+	}
+
+	return p, nil
+}
+
+// reports whether s is one of the replicas of key
+func (c *Coordinator) isReplica(key string, s *StoreClient) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stores, err := c.hashRing.GetStores(key, c.replication.Replicas)
+	if err != nil {
+		return false
+	}
+	for _, r := range stores {
+		if r == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sends a write to every replica of the key and waits for the write quorum
+// a replica that is unavailable is replaced by a spare store, which keeps
+// a hint so that the replica gets the write once it is back
+func (c *Coordinator) writeReplicas(ctx context.Context, key string, h hint, write func(context.Context, *StoreClient) error) error {
+	p, err := c.lookup(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	writeCtx, release, cancel := detach(ctx)
+
+	results := make(chan error, len(p.targets))
+	for _, t := range p.targets {
+		go func(t target) {
+			results <- c.writeReplica(writeCtx, p, t, h, write)
+		}(t)
+	}
+
+	quorum := p.quorum(c.replication.WriteQuorum)
+	_, wait := tracer.Start(ctx, "quorum wait", trace.WithAttributes(attribute.Int("nebula.quorum", quorum)))
+	defer wait.End()
+	defer slowlog.Track(ctx, "quorum wait", "")()
+
+	acks := 0
+	for i := range p.targets {
+		if err = <-results; err == nil {
+			acks++
+			if acks == quorum {
+				// the other replicas still get the write once the request is answered
+				release()
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						<-results
+					}
+					cancel()
+				}(len(p.targets) - i - 1)
+				return nil
+			}
+		}
+	}
+	cancel()
+
+	return quorumError(ctx, err, acks, "%d of %d replicas acknowledged the write", acks, quorum)
+}
+
+// writes to the store of a target, falling back to spare stores while
+// it is unavailable. Other errors fail the write.
+func (c *Coordinator) writeReplica(ctx context.Context, p *placement, t target, h hint, write func(context.Context, *StoreClient) error) (err error) {
+	ctx, span := startReplicaSpan(ctx, "write replica", t)
+	defer func() { endSpan(span, err) }()
+
+	s := t.store
+
+	for {
+		callCtx, cancel := c.storeCtx(ctx)
+		done := slowlog.Track(ctx, "write", s.name)
+		err := write(callCtx, s)
+		done()
+		cancel()
+
+		if status.Code(err) == codes.Unavailable {
+			if s = p.spare(); s != nil {
+				span.AddEvent("spare", trace.WithAttributes(attribute.String("nebula.store", s.name)))
+				continue
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if s == t.replica {
+			c.hints.supersede(t.replica.name, h.Key)
+		} else {
+			h.Owner = t.replica.name
+			h.Holder = s.name
+			h.Time = time.Now()
+			c.hints.add(h)
+		}
+		return nil
+	}
+}
+
+// replicaRead is the answer of a store to a read
+type replicaRead struct {
+	store *StoreClient
+	res   *pb_store.GetResponse
+	err   error
+}
+
+// reads a key from its replicas and answers with the latest version once
+// the read quorum has answered. Replicas that returned an older version
+// are repaired, before answering if SyncReadRepair is set.
+func (c *Coordinator) readReplicas(ctx context.Context, key string) (*pb_store.GetResponse, error) {
+	p, err := c.lookup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.hedge.Enabled && c.replication.ReadQuorum == 1 && len(p.targets) > 1 {
+		return c.hedgedRead(ctx, key, p)
+	}
+
+	readCtx, release, cancel := detach(ctx)
+
+	reads := make(chan replicaRead, len(p.targets))
+	for _, t := range p.targets {
+		go func(t target) {
+			spanCtx, span := startReplicaSpan(readCtx, "read replica", t)
+			callCtx, cancel := c.storeCtx(spanCtx)
+			defer cancel()
+
+			done := slowlog.Track(readCtx, "read", t.store.name)
+			res, err := t.store.client.Get(callCtx, &pb_store.GetRequest{Key: key})
+			done()
+			endSpan(span, err)
+			reads <- replicaRead{store: t.store, res: res, err: err}
+		}(t)
+	}
+
+	quorum := p.quorum(c.replication.ReadQuorum)
+	waitAll := c.replication.SyncReadRepair
+
+	_, wait := tracer.Start(ctx, "quorum wait", trace.WithAttributes(attribute.Int("nebula.quorum", quorum)))
+	waited := slowlog.Track(ctx, "quorum wait", "")
+
+	answered := make([]replicaRead, 0, len(p.targets))
+	ok := 0
+	for len(answered) < len(p.targets) && (waitAll || ok < quorum) {
+		r := <-reads
+		answered = append(answered, r)
+		if r.err == nil {
+			ok++
+		} else {
+			err = r.err
+		}
+	}
+	wait.End()
+	waited()
+
+	if ok < quorum {
+		cancel()
+		return nil, quorumError(ctx, err, ok, "%d of %d replicas answered the read", ok, quorum)
+	}
+
+	latest := latestRead(answered)
+
+	if waitAll {
+		cancel()
+		c.repairReplicas(ctx, key, answered)
+	} else {
+		// the other replicas are read and repaired after the request is answered
+		release()
+		go func(pending int) {
+			for ; pending > 0; pending-- {
+				answered = append(answered, <-reads)
+			}
+			cancel()
+			c.repairReplicas(c.ctx, key, answered)
+		}(len(p.targets) - len(answered))
+	}
+
+	return latest, nil
+}
+
+// returns a context that is cancelled along with ctx until release is
+// called, after which it is only cancelled by cancel. It lets requests to
+// the remaining replicas finish after a quorum answered.
+func detach(ctx context.Context) (context.Context, func() bool, context.CancelFunc) {
+	detached, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	release := context.AfterFunc(ctx, cancel)
+	return detached, release, cancel
+}
+
+// returns the error of a request that got answers from only some of the
+// replicas it needed, err being the last error. A timeout is reported as
+// such rather than as unavailable stores.
+func quorumError(ctx context.Context, err error, answers int, format string, args ...interface{}) error {
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if answers == 0 && err != nil {
+		return err
+	}
+	if status.Code(err) == codes.DeadlineExceeded {
+		return status.Errorf(codes.DeadlineExceeded, format, args...)
+	}
+	return status.Errorf(codes.Unavailable, format, args...)
+}
+
+// returns the answer with the highest version
+func latestRead(reads []replicaRead) *pb_store.GetResponse {
+	var latest *pb_store.GetResponse
+	for _, r := range reads {
+		if r.err == nil && (latest == nil || r.res.Version > latest.Version) {
+			latest = r.res
+		}
+	}
+	return latest
+}
+
+// sends the latest version of a key to the replicas that answered with an
+// older one, a deleted key is repaired by deleting it
+func (c *Coordinator) repairReplicas(ctx context.Context, key string, reads []replicaRead) {
+	latest := latestRead(reads)
+	if latest == nil || latest.Version == 0 {
+		// no replica has a versioned copy of the key
+		return
+	}
+
+	deleted := latest.Status != pb_store.StatusType_OK
+
+	stale := make([]*StoreClient, 0)
+	for _, r := range reads {
+		if r.err != nil || r.res.Version >= latest.Version {
+			continue
+		}
+		if deleted && r.res.Status != pb_store.StatusType_OK {
+			// the replica does not have the key either
+			continue
+		}
+		stale = append(stale, r.store)
+	}
+
+	if len(stale) == 0 {
+		return
+	}
+
+	c.repairs.divergentReads.Add(1)
+
+	for _, s := range stale {
+		callCtx, cancel := c.storeCtx(ctx)
+		done := slowlog.Track(ctx, "repair", s.name)
+
+		var err error
+		if deleted {
+			_, err = s.client.Delete(callCtx, &pb_store.DeleteRequest{Key: key, Version: latest.Version})
+		} else {
+			_, err = s.client.Put(callCtx, &pb_store.PutRequest{Key: key, Value: latest.Value, Version: latest.Version, ExpiresAtMs: latest.ExpiresAtMs})
+		}
+		done()
+		cancel()
+
+		if err != nil {
+			c.repairs.failures.Add(1)
+			logging.Logger("repair").WarnContext(ctx, "failed to repair a key", logging.Key("key", key), "store", s.name, "error", err)
+			continue
+		}
+		c.repairs.repairs.Add(1)
+	}
+}
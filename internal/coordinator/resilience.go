@@ -0,0 +1,275 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+	"github.com/priyansh32/nebula/internal/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ResilienceConfig controls how requests to a store are retried and when
+// a store that keeps failing is cut off by its circuit breaker
+type ResilienceConfig struct {
+	// MaxAttempts is the number of times a request is tried, Get and Delete
+	// are always retried, Put only when it is versioned
+	MaxAttempts int
+
+	// retries back off exponentially from BaseBackoff up to MaxBackoff,
+	// waiting a random time up to the backoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// BreakerThreshold is the number of consecutive failures that open the
+	// breaker of a store, which then fails requests right away for
+	// BreakerCooldown before letting a single request probe the store
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+func DefaultResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		MaxAttempts:      3,
+		BaseBackoff:      50 * time.Millisecond,
+		MaxBackoff:       time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  5 * time.Second,
+	}
+}
+
+func (cfg ResilienceConfig) validate() error {
+	if cfg.MaxAttempts < 1 {
+		return errors.New("max attempts must be greater than 0")
+	}
+	if cfg.MaxAttempts > 1 && (cfg.BaseBackoff <= 0 || cfg.MaxBackoff < cfg.BaseBackoff) {
+		return errors.New("retry backoff must be positive and at most the maximum backoff")
+	}
+	if cfg.BreakerThreshold < 1 {
+		return errors.New("breaker threshold must be greater than 0")
+	}
+	return nil
+}
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "CLOSED"
+	case BreakerOpen:
+		return "OPEN"
+	case BreakerHalfOpen:
+		return "HALF_OPEN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// breaker is the circuit breaker of a store
+type breaker struct {
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// reports whether a request may be sent to the store
+func (b *breaker) allow(cfg ResilienceConfig) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < cfg.BreakerCooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// records the outcome of a request sent with ctx, returning the state
+// before and after it
+func (b *breaker) record(ctx context.Context, err error, cfg ResilienceConfig) (BreakerState, BreakerState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	prev := b.state
+	b.probing = false
+
+	switch code := status.Code(err); {
+	case isFailure(ctx, err):
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= cfg.BreakerThreshold {
+			b.state = BreakerOpen
+			b.openedAt = time.Now()
+		}
+	case code == codes.Canceled || code == codes.DeadlineExceeded:
+		// the client gave up, which tells nothing about the store
+	default:
+		// the store answered, even if it rejected the request
+		b.state = BreakerClosed
+		b.failures = 0
+	}
+
+	return prev, b.state
+}
+
+func (b *breaker) snapshot() (BreakerState, int, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.failures, b.openedAt
+}
+
+// reports whether an error of a request sent with ctx means the store is
+// failing, as opposed to the request being rejected or given up by the
+// client. A deadline only counts when it is the store timeout that ran
+// out, the deadline of a client may be too short for any store.
+func isFailure(ctx context.Context, err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	case codes.DeadlineExceeded:
+		return ctx.Err() == nil || context.Cause(ctx) == errStoreTimeout
+	default:
+		return false
+	}
+}
+
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// resilientClient retries the requests to a store and guards them with
+// the store's circuit breaker
+type resilientClient struct {
+	pb_store.KeyValueStoreClient
+	name    string
+	breaker *breaker
+	cfg     ResilienceConfig
+}
+
+func (r *resilientClient) Get(ctx context.Context, in *pb_store.GetRequest, opts ...grpc.CallOption) (res *pb_store.GetResponse, err error) {
+	err = r.call(ctx, true, func() error {
+		res, err = r.KeyValueStoreClient.Get(ctx, in, opts...)
+		return err
+	})
+	return res, err
+}
+
+// a versioned put is idempotent, the store ignores it once it holds that version
+func (r *resilientClient) Put(ctx context.Context, in *pb_store.PutRequest, opts ...grpc.CallOption) (res *pb_store.PutResponse, err error) {
+	err = r.call(ctx, in.Version != 0, func() error {
+		res, err = r.KeyValueStoreClient.Put(ctx, in, opts...)
+		return err
+	})
+	return res, err
+}
+
+func (r *resilientClient) Delete(ctx context.Context, in *pb_store.DeleteRequest, opts ...grpc.CallOption) (res *pb_store.DeleteResponse, err error) {
+	err = r.call(ctx, true, func() error {
+		res, err = r.KeyValueStoreClient.Delete(ctx, in, opts...)
+		return err
+	})
+	return res, err
+}
+
+// sends a request through the breaker, retrying it with backoff if allowed
+func (r *resilientClient) call(ctx context.Context, retry bool, send func() error) error {
+	backoff := r.cfg.BaseBackoff
+	span := trace.SpanFromContext(ctx)
+
+	for attempt := 1; ; attempt++ {
+		if !r.breaker.allow(r.cfg) {
+			span.AddEvent("circuit breaker open", trace.WithAttributes(attribute.String("nebula.store", r.name)))
+			return status.Errorf(codes.Unavailable, "circuit breaker of store %s is open", r.name)
+		}
+
+		err := send()
+
+		if prev, cur := r.breaker.record(ctx, err, r.cfg); prev != cur {
+			logging.Logger("resilience").Warn("circuit breaker changed state", "store", r.name, "previous", prev.String(), "current", cur.String())
+		}
+
+		if err == nil || !retry || attempt >= r.cfg.MaxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		// full jitter keeps retries of many requests from arriving together
+		wait := time.Duration(rand.Int63n(int64(backoff)) + 1)
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("nebula.attempt", attempt+1),
+			attribute.String("nebula.backoff", wait.String()),
+			attribute.String("nebula.error", err.Error()),
+		))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > r.cfg.MaxBackoff {
+			backoff = r.cfg.MaxBackoff
+		}
+	}
+}
+
+// CircuitBreakers returns the state of the circuit breaker of every store
+func (c *Coordinator) CircuitBreakers(ctx context.Context, in *pb_coordinator.CircuitBreakersRequest) (*pb_coordinator.CircuitBreakersResponse, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	breakers := make([]*pb_coordinator.CircuitBreaker, 0, len(c.storeClients))
+	for name, s := range c.storeClients {
+		state, failures, openedAt := s.breaker.snapshot()
+
+		b := &pb_coordinator.CircuitBreaker{
+			Store:    name,
+			State:    state.String(),
+			Failures: uint32(failures),
+		}
+		if state != BreakerClosed {
+			b.OpenedAtUnixMs = openedAt.UnixMilli()
+		}
+		breakers = append(breakers, b)
+	}
+
+	sort.Slice(breakers, func(i, j int) bool {
+		return breakers[i].Store < breakers[j].Store
+	})
+
+	return &pb_coordinator.CircuitBreakersResponse{Breakers: breakers}, nil
+}
@@ -0,0 +1,154 @@
+package coordinator
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+	"github.com/priyansh32/nebula/internal/logging"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// how long WatchRing waits for a change before returning the current ring
+	ringWatchTimeout = 30 * time.Second
+
+	// how often the ranges of every store are assigned again, which catches
+	// up stores that restarted or missed an assignment
+	rangeAssignInterval = 30 * time.Second
+)
+
+// records a change of the ring and wakes up the ring watchers, c.mu must be held
+func (c *Coordinator) ringChanged() {
+	c.ringVersion++
+	close(c.ringWatch)
+	c.ringWatch = make(chan struct{})
+}
+
+// returns the ring as clients see it, c.mu must be held
+func (c *Coordinator) ringResponse() *pb_coordinator.GetRingResponse {
+	stores := make([]*pb_coordinator.RingStore, 0, len(c.storeClients))
+	for name, s := range c.storeClients {
+		stores = append(stores, &pb_coordinator.RingStore{
+			Name:      name,
+			Address:   s.address,
+			Positions: append([]uint64(nil), s.nodeKeys...),
+		})
+	}
+
+	sort.Slice(stores, func(i, j int) bool {
+		return stores[i].Name < stores[j].Name
+	})
+
+	return &pb_coordinator.GetRingResponse{
+		Version:     c.ringVersion,
+		Replicas:    uint32(c.replication.Replicas),
+		ReadQuorum:  uint32(c.replication.ReadQuorum),
+		WriteQuorum: uint32(c.replication.WriteQuorum),
+		Stores:      stores,
+	}
+}
+
+// GetRing returns the stores of the ring with their positions, which lets
+// clients send requests straight to the stores that own a key
+func (c *Coordinator) GetRing(ctx context.Context, in *pb_coordinator.GetRingRequest) (*pb_coordinator.GetRingResponse, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.ringResponse(), nil
+}
+
+// WatchRing returns the ring once its version differs from in.Version, or
+// the current ring if it did not change for a while
+func (c *Coordinator) WatchRing(ctx context.Context, in *pb_coordinator.WatchRingRequest) (*pb_coordinator.GetRingResponse, error) {
+	timeout := time.NewTimer(ringWatchTimeout)
+	defer timeout.Stop()
+
+	for {
+		c.mu.RLock()
+		ring, changed := c.ringResponse(), c.ringWatch
+		c.mu.RUnlock()
+
+		if ring.Version != in.Version {
+			return ring, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-timeout.C:
+			return ring, nil
+		case <-changed:
+		}
+	}
+}
+
+// StartRangeAssignment tells every store which ranges of the ring it holds
+// keys for, whenever the ring changes and every rangeAssignInterval until
+// ctx is done. Stores reject client requests for keys outside their ranges.
+func (c *Coordinator) StartRangeAssignment(ctx context.Context) {
+	events := c.Subscribe(64)
+
+	go func() {
+		ticker := time.NewTicker(rangeAssignInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-events:
+				// a store that comes back may have lost its assignment
+				if event.Type == StoreStateChanged && event.Current != StoreUp {
+					continue
+				}
+				c.assignRanges(ctx)
+			case <-ticker.C:
+				c.assignRanges(ctx)
+			}
+		}
+	}()
+}
+
+// sends every store the ranges it is a replica of. With raft only the
+// leader does, so that stores are not told about rings of different
+// versions by coordinators that have not applied the same changes yet.
+func (c *Coordinator) assignRanges(ctx context.Context) {
+	if !c.isLeader() {
+		return
+	}
+
+	assignments := make(map[*StoreClient][]*pb_store.KeyRange)
+
+	c.mu.RLock()
+	version := c.ringVersion
+	peers := make([]string, 0, len(c.storeClients))
+	for _, s := range c.storeClients {
+		assignments[s] = make([]*pb_store.KeyRange, 0)
+		peers = append(peers, s.address)
+	}
+	if len(c.storeClients) > 0 {
+		for _, r := range c.hashRing.Ranges(c.replication.Replicas) {
+			for _, s := range r.Stores {
+				assignments[s] = append(assignments[s], &pb_store.KeyRange{Start: r.Start, End: r.End})
+			}
+		}
+	}
+	c.mu.RUnlock()
+
+	for s, ranges := range assignments {
+		if s.State() == StoreDown {
+			continue
+		}
+
+		callCtx, cancel := c.storeCtx(ctx)
+		_, err := s.client.AssignRanges(callCtx, &pb_store.AssignRangesRequest{RingVersion: version, Ranges: ranges, Peers: peers})
+		cancel()
+
+		if err != nil {
+			logging.Logger("ring").Warn("failed to assign ranges to a store", "store", s.name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package coordinator
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// number of keys a scan examines when the request does not say
+const defaultScanCount = 10
+
+// Scan pages through the keys of the cluster in the order of their position
+// on the ring. A scan starts at cursor 0 and goes on with the cursor of each
+// response until it is 0 again. Keys that exist for the whole scan are
+// returned at least once, keys written or deleted meanwhile may not be.
+// Every page examines about in.Count keys and returns the ones that match
+// in.Match, so pages may be empty before the scan is over.
+func (c *Coordinator) Scan(ctx context.Context, in *pb_coordinator.ScanRequest) (*pb_coordinator.ScanResponse, error) {
+	count := int(in.Count)
+	if count <= 0 {
+		count = defaultScanCount
+	}
+
+	c.mu.RLock()
+	stores := make([]*StoreClient, 0, len(c.storeClients))
+	for _, s := range c.storeClients {
+		// the keys of a store that is down are read from the other replicas
+		if s.State() != StoreDown {
+			stores = append(stores, s)
+		}
+	}
+	c.mu.RUnlock()
+
+	if len(stores) == 0 {
+		return nil, status.Error(codes.Unavailable, "no stores to scan")
+	}
+
+	pages := make([][]string, len(stores))
+	errs := make([]error, len(stores))
+
+	var wg sync.WaitGroup
+	for i, s := range stores {
+		wg.Add(1)
+		go func(i int, s *StoreClient) {
+			defer wg.Done()
+
+			callCtx, cancel := c.storeCtx(ctx)
+			defer cancel()
+
+			res, err := s.client.Scan(callCtx, &pb_store.ScanRequest{Cursor: in.Cursor, Count: uint32(count)})
+			if err != nil {
+				errs[i] = status.Errorf(status.Code(err), "failed to scan store %s: %s", s.name, status.Convert(err).Message())
+				return
+			}
+			pages[i] = res.Keys
+		}(i, s)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// a store that returned a full page may have more keys past its last
+	// one, so the page only goes as far as the nearest of those
+	bound, more := uint64(math.MaxUint64), false
+	for _, keys := range pages {
+		if len(keys) >= count {
+			bound = min(bound, hashKey(keys[len(keys)-1]))
+			more = true
+		}
+	}
+
+	type scanned struct {
+		key     string
+		hash    uint64
+		holders []*StoreClient
+	}
+
+	found := make(map[string]*scanned)
+	for i, keys := range pages {
+		for _, key := range keys {
+			hash := hashKey(key)
+			if hash > bound {
+				continue
+			}
+			if found[key] == nil {
+				found[key] = &scanned{key: key, hash: hash}
+			}
+			found[key].holders = append(found[key].holders, stores[i])
+		}
+	}
+
+	page := make([]*scanned, 0, len(found))
+	for _, k := range found {
+		page = append(page, k)
+	}
+	sort.Slice(page, func(i, j int) bool {
+		if page[i].hash != page[j].hash {
+			return page[i].hash < page[j].hash
+		}
+		return page[i].key < page[j].key
+	})
+
+	res := &pb_coordinator.ScanResponse{}
+	switch {
+	case len(page) > count:
+		// keys at the same position stay on the same page
+		end := count
+		for end < len(page) && page[end].hash == page[end-1].hash {
+			end++
+		}
+		if end < len(page) {
+			res.Cursor = page[end].hash
+			page = page[:end]
+		} else if bound < math.MaxUint64 {
+			res.Cursor = bound + 1
+		}
+	case more && bound < math.MaxUint64:
+		res.Cursor = bound + 1
+	}
+
+	for _, k := range page {
+		if (in.Match == "" || matchGlob(in.Match, k.key)) && c.serves(k.key, k.holders) {
+			res.Keys = append(res.Keys, k.key)
+		}
+	}
+	return res, nil
+}
+
+// reports whether one of holders is a store reads of key are sent to, so
+// that stale copies left on other stores are not scanned
+func (c *Coordinator) serves(key string, holders []*StoreClient) bool {
+	p, err := c.place(key)
+	if err != nil {
+		return false
+	}
+	for _, t := range p.targets {
+		for _, h := range holders {
+			if t.store == h {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reports whether s matches a glob pattern the way redis matches keys: *
+// matches any run of characters, ? any character, [abc] and [a-z] a set of
+// characters, [^abc] any other character, and \ escapes the next character
+func matchGlob(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchGlob(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			end := 1
+			if end < len(pattern) && pattern[end] == '^' {
+				end++
+			}
+			// a ] right after the opening bracket is part of the set
+			if end < len(pattern) && pattern[end] == ']' {
+				end++
+			}
+			for end < len(pattern) && pattern[end] != ']' {
+				if pattern[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(pattern) {
+				// an unterminated set is matched literally
+				if s[0] != '[' {
+					return false
+				}
+				break
+			}
+			if !matchSet(pattern[1:end], s[0]) {
+				return false
+			}
+			pattern = pattern[end:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+// reports whether c is in the set of a glob pattern, without its brackets
+func matchSet(set string, c byte) bool {
+	negate := len(set) > 0 && set[0] == '^'
+	if negate {
+		set = set[1:]
+	}
+
+	match := false
+	for i := 0; i < len(set); i++ {
+		lo := set[i]
+		if lo == '\\' && i+1 < len(set) {
+			i++
+			lo = set[i]
+		}
+		hi := lo
+		if i+2 < len(set) && set[i+1] == '-' {
+			hi = set[i+2]
+			if hi == '\\' && i+3 < len(set) {
+				hi = set[i+3]
+				i++
+			}
+			i += 2
+		}
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if c >= lo && c <= hi {
+			match = true
+		}
+	}
+	return match != negate
+}
@@ -0,0 +1,131 @@
+package coordinator
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+)
+
+// SlowLog returns the latest slow requests served by the coordinator and
+// by every store along with the latency of their commands, and drops them
+// if asked to. Stores that are down or fail to answer are reported with an error.
+func (c *Coordinator) SlowLog(ctx context.Context, in *pb_coordinator.SlowLogRequest) (*pb_coordinator.SlowLogResponse, error) {
+	c.mu.RLock()
+	stores := make([]*StoreClient, 0, len(c.storeClients))
+	for _, s := range c.storeClients {
+		stores = append(stores, s)
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(stores, func(i, j int) bool {
+		return stores[i].name < stores[j].name
+	})
+
+	res := &pb_coordinator.SlowLogResponse{
+		Stores: make([]*pb_coordinator.StoreSlowLog, len(stores)),
+	}
+
+	for _, e := range c.slowlog.Entries(int(in.Count)) {
+		entry := &pb_coordinator.SlowLogEntry{
+			Id:          e.ID,
+			TimestampMs: e.Time.UnixMilli(),
+			Command:     e.Command,
+			Key:         e.Key,
+			RequestId:   e.RequestID,
+			DurationUs:  e.Duration.Microseconds(),
+			Stores:      e.Stores(),
+		}
+		for _, p := range e.Phases {
+			entry.Phases = append(entry.Phases, &pb_coordinator.SlowLogPhase{
+				Name:       p.Name,
+				Store:      p.Store,
+				DurationUs: p.Duration.Microseconds(),
+			})
+		}
+		res.Entries = append(res.Entries, entry)
+	}
+
+	for _, l := range c.slowlog.Latencies() {
+		res.Latencies = append(res.Latencies, &pb_coordinator.CommandLatency{
+			Command: l.Command,
+			Calls:   l.Calls,
+			Slow:    l.Slow,
+			TotalUs: l.Total.Microseconds(),
+			MaxUs:   l.Max.Microseconds(),
+		})
+	}
+
+	if in.Clear {
+		c.slowlog.Reset()
+	}
+
+	var wg sync.WaitGroup
+	for i, s := range stores {
+		wg.Add(1)
+		go func(i int, s *StoreClient) {
+			defer wg.Done()
+			res.Stores[i] = c.storeSlowLog(ctx, s, in)
+		}(i, s)
+	}
+	wg.Wait()
+
+	return res, nil
+}
+
+// returns the slow log of a single store
+func (c *Coordinator) storeSlowLog(ctx context.Context, s *StoreClient, in *pb_coordinator.SlowLogRequest) *pb_coordinator.StoreSlowLog {
+	slow := &pb_coordinator.StoreSlowLog{
+		Name:    s.name,
+		Address: s.address,
+		State:   s.State().String(),
+	}
+
+	if s.State() == StoreDown {
+		slow.Error = "store is down"
+		return slow
+	}
+
+	callCtx, cancel := c.storeCtx(ctx)
+	defer cancel()
+
+	res, err := s.client.SlowLog(callCtx, &pb_store.SlowLogRequest{Count: in.Count, Clear: in.Clear})
+	if err != nil {
+		slow.Error = err.Error()
+		return slow
+	}
+
+	for _, e := range res.Entries {
+		entry := &pb_coordinator.SlowLogEntry{
+			Id:          e.Id,
+			TimestampMs: e.TimestampMs,
+			Command:     e.Command,
+			Key:         e.Key,
+			RequestId:   e.RequestId,
+			DurationUs:  e.DurationUs,
+			Stores:      e.Stores,
+		}
+		for _, p := range e.Phases {
+			entry.Phases = append(entry.Phases, &pb_coordinator.SlowLogPhase{
+				Name:       p.Name,
+				Store:      p.Store,
+				DurationUs: p.DurationUs,
+			})
+		}
+		slow.Entries = append(slow.Entries, entry)
+	}
+
+	for _, l := range res.Latencies {
+		slow.Latencies = append(slow.Latencies, &pb_coordinator.CommandLatency{
+			Command: l.Command,
+			Calls:   l.Calls,
+			Slow:    l.Slow,
+			TotalUs: l.TotalUs,
+			MaxUs:   l.MaxUs,
+		})
+	}
+
+	return slow
+}
@@ -0,0 +1,114 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/priyansh32/nebula/internal/logging"
+)
+
+// version of the state file format, bumped on incompatible changes
+const stateFileVersion = 1
+
+// persistedState is the content of a coordinator's state file
+type persistedState struct {
+	Version           int           `json:"version"`
+	ReplicationFactor int           `json:"replication_factor"`
+	Stores            []storeRecord `json:"stores"`
+}
+
+// LoadState restores the ring from a state file and keeps the file up to
+// date with every later membership change. A missing file is not an error,
+// it is created on the first change. Stores keep the positions they had,
+// so keys map to the same stores as before the restart.
+func (c *Coordinator) LoadState(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stateFile = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state persistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("corrupt state file %s: %w", path, err)
+	}
+
+	if state.Version != stateFileVersion {
+		return fmt.Errorf("unsupported state file version %d, expected %d", state.Version, stateFileVersion)
+	}
+
+	if state.ReplicationFactor != c.hashRing.replicationFactor {
+		logging.Logger("coordinator").Warn("the state file was written with another replication factor, new stores will use the current one",
+			"path", path, "state", state.ReplicationFactor, "current", c.hashRing.replicationFactor)
+	}
+
+	c.restoreStores(state.Stores)
+	logging.Logger("coordinator").Info("restored the ring from the state file", "path", path, "stores", len(state.Stores))
+
+	return nil
+}
+
+// writes the state file atomically, c.mu must be held
+func (c *Coordinator) saveState() error {
+	data, err := json.MarshalIndent(persistedState{
+		Version:           stateFileVersion,
+		ReplicationFactor: c.hashRing.replicationFactor,
+		Stores:            c.storeRecords(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(c.stateFile, data)
+}
+
+// writes a file atomically: the data is written to a temporary file that
+// replaces the old one only once it is safely on disk, so a crash never
+// leaves a partial file
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// persists the renames made in a directory
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
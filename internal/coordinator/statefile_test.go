@@ -0,0 +1,147 @@
+package coordinator
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadState(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		ok         bool
+		wantStores int
+	}{
+		{
+			name: "missing file",
+			ok:   true,
+		},
+		{
+			name:    "corrupt file",
+			content: `{"version": 1, "stores": [`,
+		},
+		{
+			name:    "unsupported version",
+			content: `{"version": 2, "replication_factor": 1, "stores": []}`,
+		},
+		{
+			name:       "stores",
+			content:    `{"version": 1, "replication_factor": 1, "stores": [{"name": "a", "address": "127.0.0.1:1", "positions": [1]}, {"name": "b", "address": "127.0.0.1:2", "positions": [2]}]}`,
+			ok:         true,
+			wantStores: 2,
+		},
+		{
+			name:       "another replication factor",
+			content:    `{"version": 1, "replication_factor": 3, "stores": [{"name": "a", "address": "127.0.0.1:1", "positions": [1, 2, 3]}]}`,
+			ok:         true,
+			wantStores: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c := newTestCoordinator(t)
+			err := c.LoadState(path)
+			if (err == nil) != tt.ok {
+				t.Fatalf("LoadState() = %v, want ok %v", err, tt.ok)
+			}
+
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if len(c.storeClients) != tt.wantStores {
+				t.Errorf("%d stores restored, want %d", len(c.storeClients), tt.wantStores)
+			}
+		})
+	}
+}
+
+func TestSaveState(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	c := newTestCoordinator(t)
+	if err := c.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := []membershipChange{
+		{Op: opAddStore, Name: "a", Address: "127.0.0.1:1", Positions: []uint64{10, 20}},
+		{Op: opAddStore, Name: "b", Address: "127.0.0.1:2", Positions: []uint64{30}, Leased: true, Owner: "b"},
+		{Op: opAddStore, Name: "c", Address: "127.0.0.1:3", Positions: []uint64{40}},
+		{Op: opRemoveStore, Name: "c"},
+	}
+	for _, change := range changes {
+		if err := c.applyChange(change); err != nil {
+			t.Fatalf("applyChange(%s %s) = %v", change.Op, change.Name, err)
+		}
+	}
+
+	// stores learnt through gossip are not saved
+	c.mu.Lock()
+	_, err := c.addStore("gossip", "127.0.0.1:4", []uint64{50})
+	if err == nil {
+		c.gossipStores["gossip"] = true
+		err = c.saveState()
+	}
+	c.mu.Unlock()
+	if err != nil {
+		t.Fatalf("saveState() = %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files left in the state directory, want only the state file", len(entries))
+	}
+
+	restored := newTestCoordinator(t)
+	if err := restored.LoadState(path); err != nil {
+		t.Fatalf("LoadState() = %v", err)
+	}
+
+	restored.mu.Lock()
+	defer restored.mu.Unlock()
+
+	tests := []struct {
+		name      string
+		address   string
+		positions []uint64
+		leased    bool
+		owner     string
+	}{
+		{name: "a", address: "127.0.0.1:1", positions: []uint64{10, 20}},
+		{name: "b", address: "127.0.0.1:2", positions: []uint64{30}, leased: true, owner: "b"},
+	}
+
+	if len(restored.storeClients) != len(tests) {
+		t.Fatalf("%d stores restored, want %d", len(restored.storeClients), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := restored.storeClients[tt.name]
+			if s == nil {
+				t.Fatalf("store %s was not restored", tt.name)
+			}
+			if s.address != tt.address {
+				t.Errorf("address = %s, want %s", s.address, tt.address)
+			}
+			if !slices.Equal(s.nodeKeys, tt.positions) {
+				t.Errorf("positions = %v, want %v", s.nodeKeys, tt.positions)
+			}
+			if s.leaseHeld() != tt.leased || s.leaseOwner() != tt.owner {
+				t.Errorf("lease held %v by %q, want %v by %q", s.leaseHeld(), s.leaseOwner(), tt.leased, tt.owner)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package coordinator
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	pb_store "github.com/priyansh32/nebula/internal/api/store"
+)
+
+// ClusterStats asks every store for its stats and returns them along with
+// their sum, stores that are down or fail to answer are reported with an error
+func (c *Coordinator) ClusterStats(ctx context.Context, in *pb_coordinator.ClusterStatsRequest) (*pb_coordinator.ClusterStatsResponse, error) {
+	c.mu.RLock()
+	stores := make([]*StoreClient, 0, len(c.storeClients))
+	for _, s := range c.storeClients {
+		stores = append(stores, s)
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(stores, func(i, j int) bool {
+		return stores[i].name < stores[j].name
+	})
+
+	res := &pb_coordinator.ClusterStatsResponse{
+		Total:  &pb_coordinator.StoreStats{},
+		Stores: make([]*pb_coordinator.StoreStats, len(stores)),
+	}
+
+	var wg sync.WaitGroup
+	for i, s := range stores {
+		wg.Add(1)
+		go func(i int, s *StoreClient) {
+			defer wg.Done()
+			res.Stores[i] = c.storeStats(ctx, s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	for _, s := range res.Stores {
+		if s.Error != "" {
+			continue
+		}
+		res.Total.Entries += s.Entries
+		res.Total.Tombstones += s.Tombstones
+		res.Total.MemoryBytes += s.MemoryBytes
+		res.Total.Capacity += s.Capacity
+		res.Total.Hits += s.Hits
+		res.Total.Misses += s.Misses
+		res.Total.Evictions += s.Evictions
+		res.Total.Expirations += s.Expirations
+		res.Total.Puts += s.Puts
+		res.Total.Deletes += s.Deletes
+		res.Total.OpsPerSec += s.OpsPerSec
+	}
+
+	return res, nil
+}
+
+// returns the stats of a single store
+func (c *Coordinator) storeStats(ctx context.Context, s *StoreClient) *pb_coordinator.StoreStats {
+	stats := &pb_coordinator.StoreStats{
+		Name:    s.name,
+		Address: s.address,
+		State:   s.State().String(),
+	}
+
+	if s.State() == StoreDown {
+		stats.Error = "store is down"
+		return stats
+	}
+
+	callCtx, cancel := c.storeCtx(ctx)
+	defer cancel()
+
+	res, err := s.client.Stats(callCtx, &pb_store.StatsRequest{})
+	if err != nil {
+		stats.Error = err.Error()
+		return stats
+	}
+
+	stats.Entries = res.Entries
+	stats.Tombstones = res.Tombstones
+	stats.MemoryBytes = res.MemoryBytes
+	stats.Capacity = res.Capacity
+	stats.Hits = res.Hits
+	stats.Misses = res.Misses
+	stats.Evictions = res.Evictions
+	stats.Expirations = res.Expirations
+	stats.Puts = res.Puts
+	stats.Deletes = res.Deletes
+	stats.OpsPerSec = res.OpsPerSec
+	stats.UptimeMs = res.UptimeMs
+	return stats
+}
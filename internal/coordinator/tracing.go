@@ -0,0 +1,48 @@
+package coordinator
+
+import (
+	"context"
+
+	"github.com/priyansh32/nebula/internal/slowlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/priyansh32/nebula/internal/coordinator")
+
+// starts the span of a request to the store of a target
+func startReplicaSpan(ctx context.Context, name string, t target) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("nebula.store", t.store.name),
+		attribute.String("nebula.replica", t.replica.name),
+	))
+}
+
+// finds the stores that serve the given key, see place, in a span of ctx
+func (c *Coordinator) lookup(ctx context.Context, key string) (*placement, error) {
+	_, span := tracer.Start(ctx, "ring lookup")
+	defer slowlog.Track(ctx, "ring lookup", "")()
+
+	p, err := c.place(key)
+	if err == nil {
+		stores := make([]string, 0, len(p.targets))
+		for _, t := range p.targets {
+			stores = append(stores, t.store.name)
+		}
+		span.SetAttributes(attribute.StringSlice("nebula.stores", stores))
+	}
+
+	endSpan(span, err)
+	return p, err
+}
+
+// ends a span, recording err if there is one
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
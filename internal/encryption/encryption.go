@@ -0,0 +1,315 @@
+// Package encryption encrypts the files nebula keeps on disk with AES-GCM.
+// A file starts with an authenticated header naming the key it is
+// encrypted with, followed by records sealed one by one so that they can
+// be appended. Every record is bound to its file and its position in it,
+// so records that are changed, reordered, copied from another file or
+// removed from the middle of it fail to decrypt. A trailer kept next to
+// the file authenticates how many records it holds, so that records cut
+// off its end are noticed too once the trailer is rewritten after them.
+// Putting back an older copy of both the file and its trailer goes
+// unnoticed. Only the coordinator keeps files, stores persist nothing.
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// format of the files, authenticated along with every header and record
+const format = "nebula/v1"
+
+// Config selects the keys files are encrypted with, files are not
+// encrypted if it has none. Keys are given as id:key pairs separated by
+// commas or newlines, where key is a base64 AES key of 16, 24 or 32 bytes.
+// The first key encrypts new files and every key decrypts, so keys are
+// rotated by putting a new key first and dropping the old one once the
+// files encrypted with it have been rewritten.
+type Config struct {
+	// KeysFile is a file holding the keys, lines starting with # are ignored
+	KeysFile string
+
+	// Keys holds the keys themselves, such as from an environment variable.
+	// They come before the keys of KeysFile.
+	Keys string
+}
+
+// Enabled reports whether files are encrypted
+func (cfg Config) Enabled() bool {
+	return cfg.KeysFile != "" || cfg.Keys != ""
+}
+
+// Keyring holds the keys of a process, a nil *Keyring does not encrypt
+type Keyring struct {
+	keys []key
+}
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Load reads the keys of cfg, it returns a nil keyring if encryption is
+// not enabled
+func Load(cfg Config) (*Keyring, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	text := cfg.Keys
+	if cfg.KeysFile != "" {
+		data, err := os.ReadFile(cfg.KeysFile)
+		if err != nil {
+			return nil, err
+		}
+		text += "\n" + string(data)
+	}
+
+	k := &Keyring{}
+	seen := make(map[string]bool)
+	for _, entry := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		id, encoded, found := strings.Cut(entry, ":")
+		if !found || id == "" || strings.ContainsAny(id, " \t\x00") {
+			return nil, errors.New("encryption keys must be given as id:base64-key")
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("encryption key %s is given twice", id)
+		}
+		seen[id] = true
+
+		secret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s is not base64: %w", id, err)
+		}
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s must be 16, 24 or 32 bytes long", id)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.keys = append(k.keys, key{id: id, aead: aead})
+	}
+
+	if len(k.keys) == 0 {
+		return nil, errors.New("no encryption keys given")
+	}
+	return k, nil
+}
+
+// PrimaryID returns the id of the key new files are encrypted with
+func (k *Keyring) PrimaryID() string {
+	return k.keys[0].id
+}
+
+func (k *Keyring) find(id string) (key, bool) {
+	for _, key := range k.keys {
+		if key.id == id {
+			return key, true
+		}
+	}
+	return key{}, false
+}
+
+// header is the first line of an encrypted file
+type header struct {
+	Format  string `json:"encrypted"`
+	Purpose string `json:"purpose"`
+	FileID  string `json:"file"`
+	KeyID   string `json:"key"`
+
+	// Tag authenticates the other fields with the key, as the base64
+	// nonce and tag of sealing nothing
+	Tag string `json:"tag"`
+}
+
+func (h header) aad() []byte {
+	return []byte(strings.Join([]string{h.Format, h.Purpose, h.FileID, h.KeyID}, "\x00"))
+}
+
+// IsHeader reports whether line is the header of an encrypted file
+func IsHeader(line []byte) bool {
+	var h header
+	return json.Unmarshal(line, &h) == nil && h.Format != ""
+}
+
+// File seals or unseals the records of one encrypted file in order
+type File struct {
+	aead    cipher.AEAD
+	keyID   string
+	purpose string
+	fileID  string
+	seq     uint64
+}
+
+// Create starts a new file encrypted with the primary key, purpose tells
+// apart the kinds of files so that one cannot be passed for another. It
+// returns the header line to write first, without a newline.
+func (k *Keyring) Create(purpose string) (*File, []byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, nil, err
+	}
+
+	primary := k.keys[0]
+	h := header{Format: format, Purpose: purpose, FileID: hex.EncodeToString(id), KeyID: primary.id}
+
+	nonce := make([]byte, primary.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	h.Tag = base64.StdEncoding.EncodeToString(primary.aead.Seal(nonce, nonce, nil, h.aad()))
+
+	line, err := json.Marshal(h)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &File{aead: primary.aead, keyID: primary.id, purpose: purpose, fileID: h.FileID}, line, nil
+}
+
+// Open checks the header line of a file against the keyring and returns
+// the file to unseal its records with
+func (k *Keyring) Open(purpose string, line []byte) (*File, error) {
+	var h header
+	if err := json.Unmarshal(line, &h); err != nil || h.Format == "" {
+		return nil, errors.New("the file is not encrypted or its header is corrupt")
+	}
+	if h.Format != format {
+		return nil, fmt.Errorf("unknown encrypted file format %s", h.Format)
+	}
+	if h.Purpose != purpose {
+		return nil, fmt.Errorf("the file holds %s, not %s", h.Purpose, purpose)
+	}
+
+	key, ok := k.find(h.KeyID)
+	if !ok {
+		return nil, fmt.Errorf("the file is encrypted with key %s, which is not given", h.KeyID)
+	}
+
+	tag, err := base64.StdEncoding.DecodeString(h.Tag)
+	if err != nil || len(tag) < key.aead.NonceSize() {
+		return nil, errors.New("the header of the file is corrupt")
+	}
+	nonce := tag[:key.aead.NonceSize()]
+	if _, err := key.aead.Open(nil, nonce, tag[len(nonce):], h.aad()); err != nil {
+		return nil, errors.New("the header of the file was tampered with or key " + h.KeyID + " is wrong")
+	}
+
+	return &File{aead: key.aead, keyID: key.id, purpose: purpose, fileID: h.FileID}, nil
+}
+
+// KeyID returns the id of the key the file is encrypted with
+func (f *File) KeyID() string {
+	return f.keyID
+}
+
+// ID returns the random id the header, records and trailer of the file
+// are bound to
+func (f *File) ID() string {
+	return f.fileID
+}
+
+// Records returns the number of records sealed or unsealed so far
+func (f *File) Records() uint64 {
+	return f.seq
+}
+
+// returns the data every record is authenticated with, binding it to the
+// file and to its position in it
+func (f *File) aad(seq uint64) []byte {
+	aad := []byte(strings.Join([]string{format, f.purpose, f.fileID, ""}, "\x00"))
+	return binary.BigEndian.AppendUint64(aad, seq)
+}
+
+// Seal encrypts the next record of the file and returns it as a base64
+// line, without a newline
+func (f *File) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, f.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	f.seq++
+	sealed := f.aead.Seal(nonce, nonce, plaintext, f.aad(f.seq))
+
+	line := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(line, sealed)
+	return line, nil
+}
+
+// Unseal decrypts the next record of the file from its line, it fails if
+// the record is not the one that was sealed at this position of the file
+func (f *File) Unseal(line []byte) ([]byte, error) {
+	line = bytes.TrimSpace(line)
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(line)))
+	n, err := base64.StdEncoding.Decode(sealed, line)
+	if err != nil || n < f.aead.NonceSize() {
+		return nil, fmt.Errorf("record %d is corrupt", f.seq+1)
+	}
+	sealed = sealed[:n]
+
+	nonce := sealed[:f.aead.NonceSize()]
+	plaintext, err := f.aead.Open(nil, nonce, sealed[len(nonce):], f.aad(f.seq+1))
+	if err != nil {
+		return nil, fmt.Errorf("record %d was tampered with, removed or moved", f.seq+1)
+	}
+
+	f.seq++
+	return plaintext, nil
+}
+
+// returns the data the trailer of the file is authenticated with
+func (f *File) trailerAAD() []byte {
+	return []byte(strings.Join([]string{format, f.purpose, f.fileID, "trailer"}, "\x00"))
+}
+
+// SealTrailer returns a line authenticating the number of records sealed
+// so far, without a newline. It is meant to be kept apart from the file
+// and rewritten once appended records are on disk.
+func (f *File) SealTrailer() ([]byte, error) {
+	nonce := make([]byte, f.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	count := binary.BigEndian.AppendUint64(nil, f.seq)
+	sealed := f.aead.Seal(nonce, nonce, count, f.trailerAAD())
+
+	line := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(line, sealed)
+	return line, nil
+}
+
+// OpenTrailer returns the number of records the trailer of the file
+// authenticates, it fails if the trailer belongs to another file
+func (f *File) OpenTrailer(line []byte) (uint64, error) {
+	line = bytes.TrimSpace(line)
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(line)))
+	n, err := base64.StdEncoding.Decode(sealed, line)
+	if err != nil || n < f.aead.NonceSize() {
+		return 0, errors.New("the trailer is corrupt")
+	}
+	sealed = sealed[:n]
+
+	nonce := sealed[:f.aead.NonceSize()]
+	count, err := f.aead.Open(nil, nonce, sealed[len(nonce):], f.trailerAAD())
+	if err != nil || len(count) != 8 {
+		return 0, errors.New("the trailer was tampered with or belongs to another file")
+	}
+	return binary.BigEndian.Uint64(count), nil
+}
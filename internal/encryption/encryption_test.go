@@ -0,0 +1,195 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// returns a keyring of the given ids, each key derived from its id and seed
+func keyring(t *testing.T, seed string, ids ...string) *Keyring {
+	t.Helper()
+
+	entries := make([]string, len(ids))
+	for i, id := range ids {
+		secret := bytes.Repeat([]byte(seed+id), 32)[:32]
+		entries[i] = id + ":" + base64.StdEncoding.EncodeToString(secret)
+	}
+	k, err := Load(Config{Keys: strings.Join(entries, ",")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// an encrypted file as its header, records and trailer
+type sealed struct {
+	header  []byte
+	records [][]byte
+	trailer []byte
+}
+
+func seal(t *testing.T, k *Keyring, records ...string) sealed {
+	t.Helper()
+
+	f, header, err := k.Create("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sealed{header: header}
+	for _, r := range records {
+		line, err := f.Seal([]byte(r))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.records = append(s.records, line)
+	}
+	if s.trailer, err = f.SealTrailer(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// reads back the records of s, checking them against its trailer
+func (s sealed) open(k *Keyring) ([]string, error) {
+	f, err := k.Open("test", s.header)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]string, 0, len(s.records))
+	for _, line := range s.records {
+		plaintext, err := f.Unseal(line)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, string(plaintext))
+	}
+
+	count, err := f.OpenTrailer(s.trailer)
+	if err != nil {
+		return nil, err
+	}
+	if f.Records() != count {
+		return nil, fmt.Errorf("read %d records, the trailer counts %d", f.Records(), count)
+	}
+	return records, nil
+}
+
+func TestRecords(t *testing.T) {
+	k := keyring(t, "seed", "k1")
+	other := seal(t, k, "elsewhere", "else")
+
+	tests := []struct {
+		name   string
+		tamper func(s *sealed)
+		ok     bool
+	}{
+		{
+			name:   "round trip",
+			tamper: func(s *sealed) {},
+			ok:     true,
+		},
+		{
+			name: "flipped byte",
+			tamper: func(s *sealed) {
+				raw, _ := base64.StdEncoding.DecodeString(string(s.records[1]))
+				raw[len(raw)-1] ^= 1
+				s.records[1] = []byte(base64.StdEncoding.EncodeToString(raw))
+			},
+		},
+		{
+			name: "reordered records",
+			tamper: func(s *sealed) {
+				s.records[0], s.records[1] = s.records[1], s.records[0]
+			},
+		},
+		{
+			name: "record removed from the middle",
+			tamper: func(s *sealed) {
+				s.records = append(s.records[:1], s.records[2:]...)
+			},
+		},
+		{
+			name: "records cut off the end",
+			tamper: func(s *sealed) {
+				s.records = s.records[:2]
+			},
+		},
+		{
+			name: "record from another file",
+			tamper: func(s *sealed) {
+				s.records[1] = other.records[1]
+			},
+		},
+		{
+			name: "trailer of another file",
+			tamper: func(s *sealed) {
+				s.records = s.records[:2]
+				s.trailer = other.trailer
+			},
+		},
+		{
+			name: "tampered header",
+			tamper: func(s *sealed) {
+				s.header = bytes.Replace(s.header, []byte(`"purpose":"test"`), []byte(`"purpose":"else"`), 1)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := seal(t, k, "one", "two", "three")
+			tt.tamper(&s)
+
+			records, err := s.open(k)
+			if (err == nil) != tt.ok {
+				t.Fatalf("open() = %v, want ok %v", err, tt.ok)
+			}
+			if tt.ok && strings.Join(records, ",") != "one,two,three" {
+				t.Errorf("open() = %v, want [one two three]", records)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	written := seal(t, keyring(t, "seed", "k1"), "one", "two")
+
+	tests := []struct {
+		name    string
+		keys    *Keyring
+		ok      bool
+		primary string
+	}{
+		{name: "same key", keys: keyring(t, "seed", "k1"), ok: true, primary: "k1"},
+		{name: "new key first", keys: keyring(t, "seed", "k2", "k1"), ok: true, primary: "k2"},
+		{name: "old key dropped", keys: keyring(t, "seed", "k2")},
+		{name: "another key with the same id", keys: keyring(t, "other", "k1")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := written.open(tt.keys); (err == nil) != tt.ok {
+				t.Fatalf("open() = %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+
+			// files are rewritten with the primary key
+			f, header, err := tt.keys.Create("test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.KeyID() != tt.primary {
+				t.Errorf("new files use key %s, want %s", f.KeyID(), tt.primary)
+			}
+			if _, err := keyring(t, "seed", "k1").Open("test", header); (err == nil) != (tt.primary == "k1") {
+				t.Errorf("the old key alone opens a rewritten file: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package gossip
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+type broadcast struct {
+	member    Member
+	transmits int
+}
+
+// broadcastQueue holds member updates waiting to be piggybacked on
+// outgoing messages, each update is sent a limited number of times
+// that grows with the log of the cluster size
+type broadcastQueue struct {
+	mu         sync.Mutex
+	broadcasts map[string]*broadcast
+}
+
+func newBroadcastQueue() *broadcastQueue {
+	return &broadcastQueue{
+		broadcasts: make(map[string]*broadcast),
+	}
+}
+
+// queues an update, replacing any older update about the same member
+func (q *broadcastQueue) push(m Member) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.broadcasts[m.Name] = &broadcast{member: m}
+}
+
+// returns up to max updates, least transmitted first
+func (q *broadcastQueue) take(max int, clusterSize int, retransmitMult int) []Member {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	limit := retransmitMult * int(math.Ceil(math.Log10(float64(clusterSize+1))))
+
+	pending := make([]*broadcast, 0, len(q.broadcasts))
+	for _, b := range q.broadcasts {
+		pending = append(pending, b)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].transmits < pending[j].transmits
+	})
+
+	updates := make([]Member, 0, max)
+	for _, b := range pending {
+		if len(updates) == max {
+			break
+		}
+
+		updates = append(updates, b.member)
+		b.transmits++
+		if b.transmits >= limit {
+			delete(q.broadcasts, b.member.Name)
+		}
+	}
+
+	return updates
+}
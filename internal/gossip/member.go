@@ -0,0 +1,90 @@
+package gossip
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+)
+
+// State is the liveness of a member as known by the cluster
+type State uint8
+
+const (
+	StateAlive State = iota
+	StateSuspect
+	StateDead
+	StateLeft
+)
+
+func (s State) String() string {
+	switch s {
+	case StateAlive:
+		return "ALIVE"
+	case StateSuspect:
+		return "SUSPECT"
+	case StateDead:
+		return "DEAD"
+	case StateLeft:
+		return "LEFT"
+	}
+	return "UNKNOWN"
+}
+
+// Role tells whether a member serves data or only routes requests
+type Role uint8
+
+const (
+	RoleStore Role = iota
+	RoleCoordinator
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleStore:
+		return "STORE"
+	case RoleCoordinator:
+		return "COORDINATOR"
+	}
+	return "UNKNOWN"
+}
+
+// Member is a node of the cluster as disseminated by gossip
+// StoreAddress and Tokens describe the member's share of the ring and are
+// only set for stores
+type Member struct {
+	Name         string
+	Address      string
+	Role         Role
+	StoreAddress string
+	Tokens       []uint64
+	State        State
+	Incarnation  uint64
+}
+
+// reports whether m carries newer information about a member than cur
+// a higher incarnation always wins, for the same incarnation the
+// state further from alive wins so that suspicions can only be
+// refuted by the member itself
+func (m Member) overrides(cur Member) bool {
+	if m.Incarnation != cur.Incarnation {
+		return m.Incarnation > cur.Incarnation
+	}
+	return m.State > cur.State
+}
+
+// NewTokens derives n ring positions for a store from its name, so that
+// a store restarted under the same name keeps its share of the ring
+func NewTokens(name string, n int) []uint64 {
+	tokens := make([]uint64, 0, n)
+	for i := 0; i < n; i++ {
+		tokens = append(tokens, hashKey(name+"-"+strconv.Itoa(i)))
+	}
+	return tokens
+}
+
+// hashKey hashes the key to determine its position on the ring
+// must match the hashing used by the coordinator
+func hashKey(key string) uint64 {
+	hashBytes := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(hashBytes[:8])
+}
@@ -0,0 +1,100 @@
+package gossip
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+type messageType uint8
+
+const (
+	pingMsg messageType = iota
+	pingReqMsg
+	ackMsg
+	// pushes the sender's full member list and asks for the receiver's
+	syncMsg
+	// answers a sync with the receiver's full member list
+	syncReplyMsg
+)
+
+// authenticated along with every sealed message
+const sealedFormat = "nebula/gossip/v1"
+
+// message is the single packet format exchanged between nodes
+// every message piggybacks pending member updates
+type message struct {
+	Type     messageType
+	Seq      uint64
+	From     string
+	FromAddr string
+	// set on ping and ping-req to the member being probed
+	Target     string
+	TargetAddr string
+	Updates    []Member
+	Members    []Member
+}
+
+// codec turns messages into packets. With a key, packets are sealed with
+// AES-GCM so that only the nodes holding the key read and forge them.
+type codec struct {
+	aead cipher.AEAD
+}
+
+// returns a codec sealing packets with key, a base64 AES key, or one
+// sending them in the clear if key is empty
+func newCodec(key string) (*codec, error) {
+	if key == "" {
+		return &codec{}, nil
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, errors.New("the gossip key must be base64")
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, errors.New("the gossip key must be 16, 24 or 32 bytes long")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &codec{aead: aead}, nil
+}
+
+func (c *codec) encode(msg *message) ([]byte, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil || c.aead == nil {
+		return payload, err
+	}
+
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(payload)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, payload, []byte(sealedFormat)), nil
+}
+
+func (c *codec) decode(payload []byte) (*message, error) {
+	if c.aead != nil {
+		if len(payload) < c.aead.NonceSize() {
+			return nil, errors.New("packet too short to be sealed")
+		}
+		nonce, sealed := payload[:c.aead.NonceSize()], payload[c.aead.NonceSize():]
+		plaintext, err := c.aead.Open(nil, nonce, sealed, []byte(sealedFormat))
+		if err != nil {
+			return nil, errors.New("packet not sealed with the gossip key")
+		}
+		payload = plaintext
+	}
+
+	msg := &message{}
+	if err := json.Unmarshal(payload, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,617 @@
+package gossip
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/priyansh32/nebula/internal/logging"
+)
+
+// number of rounds in which a joining node contacts its seeds
+const joinAttempts = 3
+
+// Config describes a gossip node and tunes the failure detector
+type Config struct {
+	Name string
+	Role Role
+
+	// BindAddress is the UDP address listened on by Start
+	// AdvertiseAddress is the address other nodes use to reach this node,
+	// it defaults to the address of the transport
+	BindAddress      string
+	AdvertiseAddress string
+
+	// StoreAddress and Tokens are the gRPC address and ring positions of a store
+	StoreAddress string
+	Tokens       []uint64
+
+	// Seeds are the gossip addresses of nodes to join through
+	Seeds []string
+
+	// Key, if set, is a base64 AES key of 16, 24 or 32 bytes shared by
+	// every node of the cluster. Messages are then encrypted and
+	// authenticated with it, and the ones that are not are dropped.
+	Key string
+
+	ProbeInterval    time.Duration
+	ProbeTimeout     time.Duration
+	IndirectProbes   int
+	SuspicionTimeout time.Duration
+	SyncInterval     time.Duration
+	RetransmitMult   int
+	MaxPiggyback     int
+}
+
+func (cfg Config) validate() error {
+	if cfg.ProbeInterval <= 0 || cfg.SyncInterval <= 0 || cfg.SuspicionTimeout <= 0 {
+		return errors.New("gossip intervals and timeouts must be greater than 0")
+	}
+	// a probe waits for direct acks and then for indirect ones within the
+	// same interval
+	if cfg.ProbeTimeout <= 0 || cfg.ProbeTimeout >= cfg.ProbeInterval {
+		return errors.New("gossip probe timeout must be greater than 0 and less than the probe interval")
+	}
+	if _, err := newCodec(cfg.Key); err != nil {
+		return err
+	}
+	return nil
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ProbeInterval:    time.Second,
+		ProbeTimeout:     500 * time.Millisecond,
+		IndirectProbes:   3,
+		SuspicionTimeout: 5 * time.Second,
+		SyncInterval:     30 * time.Second,
+		RetransmitMult:   4,
+		MaxPiggyback:     8,
+	}
+}
+
+// EventType tells how a member changed
+type EventType uint8
+
+const (
+	MemberJoined EventType = iota
+	MemberUpdated
+	MemberSuspected
+	MemberFailed
+	MemberLeft
+)
+
+func (e EventType) String() string {
+	switch e {
+	case MemberJoined:
+		return "JOINED"
+	case MemberUpdated:
+		return "UPDATED"
+	case MemberSuspected:
+		return "SUSPECTED"
+	case MemberFailed:
+		return "FAILED"
+	case MemberLeft:
+		return "LEFT"
+	}
+	return "UNKNOWN"
+}
+
+// Event is emitted whenever this node learns about a change to another member
+type Event struct {
+	Type   EventType
+	Member Member
+}
+
+// Node takes part in the SWIM protocol: it probes a member every
+// interval, asks other members to probe on its behalf when a probe fails,
+// suspects members that stay silent and declares them dead once the
+// suspicion times out. Membership changes are piggybacked on probes and
+// periodically reconciled through full state syncs.
+type Node struct {
+	cfg        Config
+	transport  Transport
+	codec      *codec
+	broadcasts *broadcastQueue
+	events     chan Event
+	synced     chan struct{}
+
+	mu            sync.RWMutex
+	self          *Member
+	members       map[string]*Member
+	suspectTimers map[string]*time.Timer
+	probeOrder    []string
+	leaving       bool
+
+	seq   uint64
+	ackMu sync.Mutex
+	acks  map[uint64]func()
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewNode returns a node gossiping over transport, it panics if cfg.Key is
+// not a valid key, which Start checks
+func NewNode(cfg Config, transport Transport) *Node {
+	codec, err := newCodec(cfg.Key)
+	if err != nil {
+		panic(err)
+	}
+
+	if cfg.AdvertiseAddress == "" {
+		cfg.AdvertiseAddress = transport.Addr()
+	}
+
+	self := &Member{
+		Name:         cfg.Name,
+		Address:      cfg.AdvertiseAddress,
+		Role:         cfg.Role,
+		StoreAddress: cfg.StoreAddress,
+		Tokens:       cfg.Tokens,
+		State:        StateAlive,
+	}
+
+	// stores do not follow membership, nothing would read their events
+	var events chan Event
+	if cfg.Role != RoleStore {
+		events = make(chan Event, 1024)
+	}
+
+	return &Node{
+		cfg:           cfg,
+		transport:     transport,
+		codec:         codec,
+		broadcasts:    newBroadcastQueue(),
+		events:        events,
+		synced:        make(chan struct{}, 1),
+		self:          self,
+		members:       map[string]*Member{self.Name: self},
+		suspectTimers: make(map[string]*time.Timer),
+		acks:          make(map[uint64]func()),
+		stop:          make(chan struct{}),
+	}
+}
+
+// Start listens on cfg.BindAddress over UDP and joins the cluster through cfg.Seeds
+func Start(cfg Config) (*Node, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	transport, err := NewUDPTransport(cfg.BindAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	n := NewNode(cfg, transport)
+	n.Start()
+
+	if len(cfg.Seeds) > 0 {
+		if err := n.Join(cfg.Seeds); err != nil {
+			n.Shutdown()
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+// Start begins receiving packets and probing members
+func (n *Node) Start() {
+	n.wg.Add(3)
+	go n.receive()
+	go n.loop(n.cfg.ProbeInterval, n.probe)
+	go n.loop(n.cfg.SyncInterval, n.syncRandom)
+}
+
+// Join exchanges full state with the seeds, it succeeds once any seed answers
+func (n *Node) Join(seeds []string) error {
+	for attempt := 0; attempt < joinAttempts; attempt++ {
+		for _, seed := range seeds {
+			if seed != n.cfg.AdvertiseAddress {
+				n.send(seed, &message{Type: syncMsg, Members: n.Members()})
+			}
+		}
+
+		select {
+		case <-n.synced:
+			return nil
+		case <-time.After(n.cfg.ProbeInterval):
+		case <-n.stop:
+			return errors.New("node is shut down")
+		}
+	}
+
+	return errors.New("no seed answered the join request")
+}
+
+// Leave tells the cluster this node is going away and waits for the
+// news to spread before returning
+func (n *Node) Leave(timeout time.Duration) {
+	n.mu.Lock()
+	n.leaving = true
+	n.self.Incarnation++
+	n.self.State = StateLeft
+	self := *n.self
+	n.mu.Unlock()
+
+	n.broadcasts.push(self)
+
+	for _, m := range n.Members() {
+		if m.Name != self.Name && m.State <= StateSuspect {
+			n.send(m.Address, &message{Type: syncMsg, Members: []Member{self}})
+		}
+	}
+
+	select {
+	case <-time.After(timeout):
+	case <-n.stop:
+	}
+}
+
+// Shutdown stops the node without telling the cluster
+func (n *Node) Shutdown() {
+	n.stopOnce.Do(func() {
+		close(n.stop)
+		n.transport.Close()
+
+		n.mu.Lock()
+		for _, t := range n.suspectTimers {
+			t.Stop()
+		}
+		n.mu.Unlock()
+	})
+	n.wg.Wait()
+}
+
+// Events returns the channel membership changes are delivered on, it is
+// nil for stores
+func (n *Node) Events() <-chan Event {
+	return n.events
+}
+
+// Members returns every member known to this node, including itself and
+// members that failed or left
+func (n *Node) Members() []Member {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	members := make([]Member, 0, len(n.members))
+	for _, m := range n.members {
+		members = append(members, *m)
+	}
+	return members
+}
+
+// LocalMember returns this node's own member record
+func (n *Node) LocalMember() Member {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return *n.self
+}
+
+func (n *Node) loop(interval time.Duration, fn func()) {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
+
+func (n *Node) receive() {
+	defer n.wg.Done()
+
+	for payload := range n.transport.Packets() {
+		msg, err := n.codec.decode(payload)
+		if err != nil {
+			logging.Logger("gossip").Warn("dropping a malformed packet", "error", err)
+			continue
+		}
+		n.handle(msg)
+	}
+}
+
+func (n *Node) handle(msg *message) {
+	for _, m := range msg.Updates {
+		n.apply(m)
+	}
+
+	switch msg.Type {
+	case pingMsg:
+		// the address may have been reused by another node
+		if msg.Target != n.cfg.Name {
+			return
+		}
+		n.send(msg.FromAddr, &message{Type: ackMsg, Seq: msg.Seq})
+
+	case pingReqMsg:
+		// probe the target on behalf of the sender and relay its ack
+		seq := n.nextSeq()
+		origin, originSeq := msg.FromAddr, msg.Seq
+		n.onAck(seq, func() {
+			n.send(origin, &message{Type: ackMsg, Seq: originSeq})
+		})
+		time.AfterFunc(n.cfg.ProbeTimeout, func() { n.clearAck(seq) })
+		n.send(msg.TargetAddr, &message{Type: pingMsg, Seq: seq, Target: msg.Target, TargetAddr: msg.TargetAddr})
+
+	case ackMsg:
+		n.ackMu.Lock()
+		fn := n.acks[msg.Seq]
+		n.ackMu.Unlock()
+		if fn != nil {
+			fn()
+		}
+
+	case syncMsg:
+		for _, m := range msg.Members {
+			n.apply(m)
+		}
+		n.send(msg.FromAddr, &message{Type: syncReplyMsg, Members: n.Members()})
+
+	case syncReplyMsg:
+		for _, m := range msg.Members {
+			n.apply(m)
+		}
+		select {
+		case n.synced <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// merges an update about a member into the local state
+func (n *Node) apply(m Member) {
+	n.mu.Lock()
+
+	if m.Name == n.cfg.Name {
+		// refute anything but our own view of ourselves by outliving it,
+		// this also takes over records left behind by an earlier run
+		stale := m.Incarnation > n.self.Incarnation ||
+			(m.Incarnation == n.self.Incarnation && m.State != StateAlive)
+		if !n.leaving && stale {
+			n.self.Incarnation = m.Incarnation + 1
+			n.broadcasts.push(*n.self)
+		}
+		n.mu.Unlock()
+		return
+	}
+
+	cur, known := n.members[m.Name]
+	if known && !m.overrides(*cur) {
+		n.mu.Unlock()
+		return
+	}
+
+	var prev Member
+	if known {
+		prev = *cur
+	}
+
+	updated := m
+	n.members[m.Name] = &updated
+	n.broadcasts.push(m)
+
+	if m.State == StateSuspect {
+		n.startSuspicion(m)
+	} else if t, ok := n.suspectTimers[m.Name]; ok {
+		t.Stop()
+		delete(n.suspectTimers, m.Name)
+	}
+
+	n.mu.Unlock()
+
+	wasLive := known && prev.State <= StateSuspect
+	var eventType EventType
+
+	switch {
+	case m.State <= StateSuspect && !wasLive:
+		eventType = MemberJoined
+	case m.State == StateSuspect && prev.State == StateAlive:
+		eventType = MemberSuspected
+	case m.State == StateAlive:
+		eventType = MemberUpdated
+	case m.State == StateDead && wasLive:
+		eventType = MemberFailed
+	case m.State == StateLeft && wasLive:
+		eventType = MemberLeft
+	default:
+		return
+	}
+
+	n.emit(Event{Type: eventType, Member: m})
+}
+
+// declares a suspect dead unless it refutes the suspicion in time, n.mu must be held
+func (n *Node) startSuspicion(m Member) {
+	if _, ok := n.suspectTimers[m.Name]; ok {
+		return
+	}
+
+	n.suspectTimers[m.Name] = time.AfterFunc(n.cfg.SuspicionTimeout, func() {
+		n.mu.Lock()
+		delete(n.suspectTimers, m.Name)
+		cur, ok := n.members[m.Name]
+		stillSuspect := ok && cur.State == StateSuspect
+		var dead Member
+		if stillSuspect {
+			dead = *cur
+			dead.State = StateDead
+		}
+		n.mu.Unlock()
+
+		if stillSuspect {
+			n.apply(dead)
+		}
+	})
+}
+
+func (n *Node) emit(e Event) {
+	if n.events == nil {
+		return
+	}
+	select {
+	case n.events <- e:
+	default:
+		logging.Logger("gossip").Warn("event queue full, dropping an event", "event", e.Type.String(), "member", e.Member.Name)
+	}
+}
+
+// probes the next member in round robin order
+func (n *Node) probe() {
+	target, ok := n.nextProbeTarget()
+	if !ok {
+		return
+	}
+
+	seq := n.nextSeq()
+	acked := make(chan struct{}, 1)
+	n.onAck(seq, func() {
+		select {
+		case acked <- struct{}{}:
+		default:
+		}
+	})
+	defer n.clearAck(seq)
+
+	n.send(target.Address, &message{Type: pingMsg, Seq: seq, Target: target.Name, TargetAddr: target.Address})
+
+	select {
+	case <-acked:
+		return
+	case <-time.After(n.cfg.ProbeTimeout):
+	case <-n.stop:
+		return
+	}
+
+	// the direct probe failed, ask other members to try so that a bad
+	// link between us and the target does not get it declared dead
+	for _, peer := range n.randomMembers(n.cfg.IndirectProbes, target.Name) {
+		n.send(peer.Address, &message{Type: pingReqMsg, Seq: seq, Target: target.Name, TargetAddr: target.Address})
+	}
+
+	select {
+	case <-acked:
+		return
+	case <-time.After(n.cfg.ProbeInterval - n.cfg.ProbeTimeout):
+	case <-n.stop:
+		return
+	}
+
+	suspect := target
+	suspect.State = StateSuspect
+	n.apply(suspect)
+}
+
+// picks members in a shuffled round robin, so that every member is
+// probed within a bounded number of intervals
+func (n *Node) nextProbeTarget() (Member, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for attempts := 0; attempts < 2; attempts++ {
+		for len(n.probeOrder) > 0 {
+			name := n.probeOrder[0]
+			n.probeOrder = n.probeOrder[1:]
+
+			if m, ok := n.members[name]; ok && m.State <= StateSuspect {
+				return *m, true
+			}
+		}
+
+		for name, m := range n.members {
+			if name != n.cfg.Name && m.State <= StateSuspect {
+				n.probeOrder = append(n.probeOrder, name)
+			}
+		}
+		rand.Shuffle(len(n.probeOrder), func(i, j int) {
+			n.probeOrder[i], n.probeOrder[j] = n.probeOrder[j], n.probeOrder[i]
+		})
+	}
+
+	return Member{}, false
+}
+
+// picks up to k random live members other than this node and exclude
+func (n *Node) randomMembers(k int, exclude string) []Member {
+	n.mu.RLock()
+	candidates := make([]Member, 0, len(n.members))
+	for name, m := range n.members {
+		if name != n.cfg.Name && name != exclude && m.State == StateAlive {
+			candidates = append(candidates, *m)
+		}
+	}
+	n.mu.RUnlock()
+
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return candidates
+}
+
+// pushes our full state to a random member and pulls theirs
+func (n *Node) syncRandom() {
+	peers := n.randomMembers(1, "")
+	if len(peers) == 0 {
+		return
+	}
+	n.send(peers[0].Address, &message{Type: syncMsg, Members: n.Members()})
+}
+
+func (n *Node) nextSeq() uint64 {
+	return atomic.AddUint64(&n.seq, 1)
+}
+
+func (n *Node) onAck(seq uint64, fn func()) {
+	n.ackMu.Lock()
+	defer n.ackMu.Unlock()
+	n.acks[seq] = fn
+}
+
+func (n *Node) clearAck(seq uint64) {
+	n.ackMu.Lock()
+	defer n.ackMu.Unlock()
+	delete(n.acks, seq)
+}
+
+// sends a message with pending updates piggybacked on it
+func (n *Node) send(addr string, msg *message) {
+	select {
+	case <-n.stop:
+		return
+	default:
+	}
+
+	n.mu.RLock()
+	msg.From = n.cfg.Name
+	msg.FromAddr = n.cfg.AdvertiseAddress
+	clusterSize := len(n.members)
+	n.mu.RUnlock()
+
+	msg.Updates = n.broadcasts.take(n.cfg.MaxPiggyback, clusterSize, n.cfg.RetransmitMult)
+
+	payload, err := n.codec.encode(msg)
+	if err != nil {
+		logging.Logger("gossip").Error("failed to encode a message", "error", err)
+		return
+	}
+
+	if err := n.transport.Send(addr, payload); err != nil {
+		logging.Logger("gossip").Warn("failed to send a message", "address", addr, "error", err)
+	}
+}
@@ -0,0 +1,253 @@
+package gossip
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func testConfig(name string, role Role) Config {
+	cfg := DefaultConfig()
+	cfg.Name = name
+	cfg.Role = role
+	cfg.ProbeInterval = 50 * time.Millisecond
+	cfg.ProbeTimeout = 20 * time.Millisecond
+	cfg.SuspicionTimeout = 500 * time.Millisecond
+	cfg.SyncInterval = 200 * time.Millisecond
+	if role == RoleStore {
+		cfg.StoreAddress = name + ":50000"
+		cfg.Tokens = NewTokens(name, 8)
+	}
+	return cfg
+}
+
+// waits until cond holds, failing the test after timeout
+func eventually(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// reports whether n sees every member of want in a state accepted by ok
+func sees(n *Node, want []string, ok func(State) bool) bool {
+	members := make(map[string]State)
+	for _, m := range n.Members() {
+		members[m.Name] = m.State
+	}
+	for _, name := range want {
+		s, found := members[name]
+		if !found || !ok(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// suspects are still live, lossy links keep raising and refuting suspicions
+func live(s State) bool { return s <= StateSuspect }
+
+func dead(s State) bool { return s == StateDead }
+
+// reports whether every node routes keys the same way
+func routeAlike(nodes []*Node) bool {
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		want := nodes[0].Owners(key, 2)
+		if len(want) != 2 {
+			return false
+		}
+		for _, n := range nodes[1:] {
+			got := n.Owners(key, 2)
+			if len(got) != len(want) || got[0].Name != want[0].Name || got[1].Name != want[1].Name {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestConvergence(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes int
+		loss  float64
+	}{
+		{name: "no loss", nodes: 5, loss: 0},
+		{name: "10% loss", nodes: 5, loss: 0.1},
+		{name: "30% loss", nodes: 8, loss: 0.3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			network := NewMemNetwork(tt.loss, 1)
+
+			names := make([]string, tt.nodes)
+			nodes := make([]*Node, tt.nodes)
+			for i := range nodes {
+				names[i] = fmt.Sprintf("node-%d", i)
+				role := RoleStore
+				if i == 0 {
+					role = RoleCoordinator
+				}
+				nodes[i] = NewNode(testConfig(names[i], role), network.NewTransport(names[i]))
+				nodes[i].Start()
+				defer nodes[i].Shutdown()
+			}
+
+			// joins can be lost too, nodes retry until a seed answers
+			for _, n := range nodes[1:] {
+				for attempt := 0; n.Join([]string{names[0]}) != nil; attempt++ {
+					if attempt == 10 {
+						t.Fatalf("%s failed to join", n.cfg.Name)
+					}
+				}
+			}
+
+			// every node routes keys the same way once converged
+			eventually(t, 10*time.Second, "every node to see every other one and route alike", func() bool {
+				for _, n := range nodes {
+					if !sees(n, names, live) {
+						return false
+					}
+				}
+				return routeAlike(nodes)
+			})
+
+			// a node that stops answering is declared dead by the others
+			failed := nodes[len(nodes)-1]
+			failed.Shutdown()
+
+			eventually(t, 10*time.Second, "the failed node to be declared dead", func() bool {
+				for _, n := range nodes[:len(nodes)-1] {
+					if !sees(n, []string{failed.cfg.Name}, dead) {
+						return false
+					}
+				}
+				return true
+			})
+
+			// only the coordinator follows membership
+			if nodes[1].Events() != nil {
+				t.Errorf("stores have an event channel")
+			}
+			select {
+			case <-nodes[0].Events():
+			default:
+				t.Errorf("the coordinator got no membership events")
+			}
+		})
+	}
+}
+
+// returns a gossip key derived from seed
+func testKey(seed string) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte(seed), 32)[:32])
+}
+
+func TestGossipKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		seed   string
+		joiner string
+		joins  bool
+	}{
+		{name: "same key", seed: testKey("a"), joiner: testKey("a"), joins: true},
+		{name: "other key", seed: testKey("a"), joiner: testKey("b")},
+		{name: "joiner without a key", seed: testKey("a")},
+		{name: "seed without a key", joiner: testKey("a")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			network := NewMemNetwork(0, 1)
+
+			cfg := testConfig("seed", RoleCoordinator)
+			cfg.Key = tt.seed
+			seed := NewNode(cfg, network.NewTransport("seed"))
+			seed.Start()
+			defer seed.Shutdown()
+
+			cfg = testConfig("joiner", RoleStore)
+			cfg.Key = tt.joiner
+			joiner := NewNode(cfg, network.NewTransport("joiner"))
+			joiner.Start()
+			defer joiner.Shutdown()
+
+			if err := joiner.Join([]string{"seed"}); (err == nil) != tt.joins {
+				t.Fatalf("Join() = %v, want joined %v", err, tt.joins)
+			}
+
+			// messages that are not sealed with the key change nothing
+			time.Sleep(5 * cfg.ProbeInterval)
+			if got := sees(seed, []string{"joiner"}, live); got != tt.joins {
+				t.Errorf("the seed sees the joiner: %v, want %v", got, tt.joins)
+			}
+		})
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		valid  bool
+	}{
+		{name: "default", modify: func(*Config) {}, valid: true},
+		{name: "timeout equals interval", modify: func(c *Config) { c.ProbeTimeout = c.ProbeInterval }},
+		{name: "timeout above interval", modify: func(c *Config) { c.ProbeTimeout = 2 * c.ProbeInterval }},
+		{name: "no timeout", modify: func(c *Config) { c.ProbeTimeout = 0 }},
+		{name: "no sync interval", modify: func(c *Config) { c.SyncInterval = 0 }},
+		{name: "key", modify: func(c *Config) { c.Key = testKey("a") }, valid: true},
+		{name: "key not base64", modify: func(c *Config) { c.Key = "not base64!" }},
+		{name: "key of the wrong size", modify: func(c *Config) { c.Key = base64.StdEncoding.EncodeToString([]byte("short")) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.modify(&cfg)
+			if err := cfg.validate(); (err == nil) != tt.valid {
+				t.Errorf("validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestTokensSurviveRestarts(t *testing.T) {
+	tests := []struct {
+		name   string
+		vnodes int
+	}{
+		{name: "one", vnodes: 1},
+		{name: "eight", vnodes: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, second := NewTokens("alpha", tt.vnodes), NewTokens("alpha", tt.vnodes)
+			if len(first) != tt.vnodes {
+				t.Fatalf("got %d tokens, want %d", len(first), tt.vnodes)
+			}
+			seen := make(map[uint64]bool)
+			for i := range first {
+				if first[i] != second[i] {
+					t.Errorf("token %d changed from %d to %d", i, first[i], second[i])
+				}
+				if seen[first[i]] {
+					t.Errorf("token %d is repeated", first[i])
+				}
+				seen[first[i]] = true
+			}
+			if NewTokens("beta", 1)[0] == first[0] {
+				t.Errorf("different stores got the same token")
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package gossip
+
+import "sort"
+
+// Owners returns up to count distinct live stores for the key in ring
+// order, computed from the tokens every store disseminates. Any node of
+// the cluster computes the same owners once gossip has converged.
+func (n *Node) Owners(key string, count int) []Member {
+	type token struct {
+		position uint64
+		store    *Member
+	}
+
+	n.mu.RLock()
+	tokens := make([]token, 0)
+	for _, m := range n.members {
+		if m.Role != RoleStore || m.State > StateSuspect {
+			continue
+		}
+		store := *m
+		for _, t := range m.Tokens {
+			tokens = append(tokens, token{position: t, store: &store})
+		}
+	}
+	n.mu.RUnlock()
+
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].position < tokens[j].position
+	})
+
+	hash := hashKey(key)
+	index := sort.Search(len(tokens), func(i int) bool {
+		return tokens[i].position >= hash
+	})
+
+	owners := make([]Member, 0, count)
+	seen := make(map[string]bool)
+
+	for i := 0; i < len(tokens) && len(owners) < count; i++ {
+		s := tokens[(index+i)%len(tokens)].store
+		if seen[s.Name] {
+			continue
+		}
+		seen[s.Name] = true
+		owners = append(owners, *s)
+	}
+
+	return owners
+}
+
+// Owner returns the live store that owns the key
+func (n *Node) Owner(key string) (Member, bool) {
+	owners := n.Owners(key, 1)
+	if len(owners) == 0 {
+		return Member{}, false
+	}
+	return owners[0], true
+}
@@ -0,0 +1,190 @@
+package gossip
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+)
+
+// maximum size of a gossip packet, the largest payload of a UDP datagram
+const maxPacketSize = 65507
+
+// Transport delivers unreliable, unordered packets between nodes
+type Transport interface {
+	// Addr is the address other nodes use to reach this transport
+	Addr() string
+	Send(addr string, payload []byte) error
+	Packets() <-chan []byte
+	Close() error
+}
+
+// UDPTransport sends gossip packets over UDP
+type UDPTransport struct {
+	conn    *net.UDPConn
+	packets chan []byte
+}
+
+func NewUDPTransport(bind string) (*UDPTransport, error) {
+	addr, err := net.ResolveUDPAddr("udp", bind)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &UDPTransport{
+		conn:    conn,
+		packets: make(chan []byte, 1024),
+	}
+	go t.read()
+
+	return t, nil
+}
+
+func (t *UDPTransport) read() {
+	defer close(t.packets)
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := t.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		packet := make([]byte, n)
+		copy(packet, buf[:n])
+
+		// drop the packet rather than block the socket, gossip tolerates loss
+		select {
+		case t.packets <- packet:
+		default:
+		}
+	}
+}
+
+func (t *UDPTransport) Addr() string {
+	return t.conn.LocalAddr().String()
+}
+
+func (t *UDPTransport) Send(addr string, payload []byte) error {
+	if len(payload) > maxPacketSize {
+		return errors.New("gossip packet too large")
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.conn.WriteToUDP(payload, udpAddr)
+	return err
+}
+
+func (t *UDPTransport) Packets() <-chan []byte {
+	return t.packets
+}
+
+func (t *UDPTransport) Close() error {
+	return t.conn.Close()
+}
+
+// MemNetwork connects in-process transports and drops a configurable
+// fraction of the packets sent between them, for running clusters in tests
+type MemNetwork struct {
+	mu         sync.Mutex
+	transports map[string]*memTransport
+	lossRate   float64
+	rand       *rand.Rand
+}
+
+func NewMemNetwork(lossRate float64, seed int64) *MemNetwork {
+	return &MemNetwork{
+		transports: make(map[string]*memTransport),
+		lossRate:   lossRate,
+		rand:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+// SetLossRate changes the fraction of packets dropped, between 0 and 1
+func (n *MemNetwork) SetLossRate(rate float64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.lossRate = rate
+}
+
+// NewTransport attaches a transport with the given address to the network
+func (n *MemNetwork) NewTransport(addr string) Transport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	t := &memTransport{
+		network: n,
+		addr:    addr,
+		packets: make(chan []byte, 1024),
+	}
+	n.transports[addr] = t
+
+	return t
+}
+
+func (n *MemNetwork) deliver(addr string, payload []byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	t, ok := n.transports[addr]
+	if !ok {
+		// like UDP, sending to a missing node silently goes nowhere
+		return nil
+	}
+
+	if n.rand.Float64() < n.lossRate {
+		return nil
+	}
+
+	packet := make([]byte, len(payload))
+	copy(packet, payload)
+
+	select {
+	case t.packets <- packet:
+	default:
+	}
+
+	return nil
+}
+
+type memTransport struct {
+	network *MemNetwork
+	addr    string
+	packets chan []byte
+}
+
+func (t *memTransport) Addr() string {
+	return t.addr
+}
+
+func (t *memTransport) Send(addr string, payload []byte) error {
+	return t.network.deliver(addr, payload)
+}
+
+func (t *memTransport) Packets() <-chan []byte {
+	return t.packets
+}
+
+func (t *memTransport) Close() error {
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+
+	if t.network.transports[t.addr] == t {
+		delete(t.network.transports, t.addr)
+		close(t.packets)
+	}
+
+	return nil
+}
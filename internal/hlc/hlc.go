@@ -0,0 +1,34 @@
+// Package hlc issues the versions of writes from a hybrid logical clock.
+// Versions follow the wall clock in nanoseconds, but never go backwards
+// and always exceed every version the clock has observed, so that a write
+// made after reading or losing to a version from a node whose clock runs
+// ahead still wins over it.
+package hlc
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock issues increasing versions, the zero value is ready to use
+type Clock struct {
+	mu   sync.Mutex
+	last int64
+}
+
+// Now returns a version greater than every version issued or observed so far
+func (c *Clock) Now() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.last = max(time.Now().UnixNano(), c.last+1)
+	return c.last
+}
+
+// Observe records a version seen from another node
+func (c *Clock) Observe(version int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.last = max(c.last, version)
+}
@@ -0,0 +1,226 @@
+// Package logging sets up the structured logs of nebula processes. Logs
+// are leveled per component, keys are hashed and values redacted unless
+// asked for, hot paths are sampled and every record of a request carries
+// its request id.
+package logging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config controls the logs of a process
+type Config struct {
+	// Component names the process in every record, such as "store"
+	Component string
+
+	// Level is the minimum level of records, Levels overrides it for the
+	// loggers of single components
+	Level  slog.Level
+	Levels map[string]slog.Level
+
+	// JSON writes records as JSON lines instead of text
+	JSON bool
+
+	// Values logs keys and their values, keys are hashed and values
+	// redacted otherwise
+	Values bool
+
+	// SampleEvery keeps one in SampleEvery records of each message logged
+	// on hot paths, all of them are kept if it is 1 or less
+	SampleEvery uint64
+}
+
+func DefaultConfig() Config {
+	return Config{Level: slog.LevelInfo, SampleEvery: 1}
+}
+
+// SetLevels sets the levels from a spec such as "info" or "warn,requests=debug",
+// the default level followed by the levels of single components
+func (cfg *Config) SetLevels(spec string) error {
+	level := slog.LevelInfo
+	levels := make(map[string]slog.Level)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		component, name, found := strings.Cut(part, "=")
+		if !found {
+			component, name = "", part
+		}
+
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(name)); err != nil {
+			return fmt.Errorf("invalid log level %q", name)
+		}
+
+		if component == "" {
+			level = l
+		} else {
+			levels[component] = l
+		}
+	}
+
+	cfg.Level, cfg.Levels = level, levels
+	return nil
+}
+
+// SetFormat sets the format of records, "text" or "json"
+func (cfg *Config) SetFormat(format string) error {
+	switch format {
+	case "text":
+		cfg.JSON = false
+	case "json":
+		cfg.JSON = true
+	default:
+		return fmt.Errorf("invalid log format %q, use text or json", format)
+	}
+	return nil
+}
+
+var (
+	config atomic.Pointer[Config]
+
+	// the logger of the process before a component is named
+	base atomic.Pointer[slog.Logger]
+)
+
+// Setup makes the logs of cfg the default of both slog and the log package
+func Setup(cfg Config) error {
+	if cfg.Component == "" {
+		return errors.New("logs need a component")
+	}
+	config.Store(&cfg)
+
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+
+	var h slog.Handler
+	if cfg.JSON {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		h = slog.NewTextHandler(os.Stderr, opts)
+	}
+
+	l := slog.New(&handler{inner: h, level: cfg.Level})
+	base.Store(l)
+	slog.SetDefault(l.With("component", cfg.Component))
+	return nil
+}
+
+// Logger returns the logger of a component of the process
+func Logger(component string) *slog.Logger {
+	l := base.Load()
+	if l == nil {
+		l = slog.Default()
+	}
+	return l.With("component", component)
+}
+
+// Hot returns the logger of a component for records logged on every
+// request, only a sample of which are kept
+func Hot(component string) *slog.Logger {
+	l := Logger(component)
+	return slog.New(&sampler{inner: l.Handler(), counts: new(sync.Map)})
+}
+
+// Key returns an attribute holding a key of the store, hashed unless
+// values are logged so that records of the same key can still be matched
+func Key(name string, key string) slog.Attr {
+	if cfg := config.Load(); cfg != nil && cfg.Values {
+		return slog.String(name, key)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return slog.String(name, "sha256:"+hex.EncodeToString(sum[:8]))
+}
+
+// Value returns an attribute holding a value of the store, redacted
+// unless values are logged
+func Value(key string, value string) slog.Attr {
+	if cfg := config.Load(); cfg != nil && cfg.Values {
+		return slog.String(key, value)
+	}
+	return slog.String(key, fmt.Sprintf("[redacted %d bytes]", len(value)))
+}
+
+// handler filters records by the level of their component and adds the
+// request and trace ids of their context
+type handler struct {
+	inner slog.Handler
+	level slog.Level
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	level := h.level
+	if cfg := config.Load(); cfg != nil {
+		for _, a := range attrs {
+			if l, ok := cfg.Levels[a.Value.String()]; ok && a.Key == "component" {
+				level = l
+			}
+		}
+	}
+	return &handler{inner: h.inner.WithAttrs(attrs), level: level}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{inner: h.inner.WithGroup(name), level: h.level}
+}
+
+// sampler keeps one in SampleEvery records of each message
+type sampler struct {
+	inner  slog.Handler
+	counts *sync.Map
+}
+
+func (s *sampler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.inner.Enabled(ctx, level)
+}
+
+func (s *sampler) Handle(ctx context.Context, r slog.Record) error {
+	every := uint64(1)
+	if cfg := config.Load(); cfg != nil && cfg.SampleEvery > 1 {
+		every = cfg.SampleEvery
+	}
+
+	if every > 1 {
+		n, _ := s.counts.LoadOrStore(r.Message, new(atomic.Uint64))
+		if n.(*atomic.Uint64).Add(1)%every != 1 {
+			return nil
+		}
+	}
+	return s.inner.Handle(ctx, r)
+}
+
+func (s *sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sampler{inner: s.inner.WithAttrs(attrs), counts: s.counts}
+}
+
+func (s *sampler) WithGroup(name string) slog.Handler {
+	return &sampler{inner: s.inner.WithGroup(name), counts: s.counts}
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadata key the request id travels in between processes
+const requestIDKey = "x-request-id"
+
+type requestIDContextKey struct{}
+
+// RequestID returns the id of the request ctx belongs to, empty if none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// WithRequestID returns a context for the request with the given id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// ServerOption gives every request served the id sent by its caller, or a
+// new one, and logs it once answered on the hot path of the component
+func ServerOption(component string) grpc.ServerOption {
+	requests := Hot(component)
+
+	return grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id := ""
+		if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(requestIDKey)) > 0 {
+			id = md.Get(requestIDKey)[0]
+		}
+		if id == "" {
+			id = uuid.NewString()
+		}
+		ctx = WithRequestID(ctx, id)
+
+		start := time.Now()
+		res, err := handler(ctx, req)
+
+		requests.DebugContext(ctx, "request",
+			slog.String("method", path.Base(info.FullMethod)),
+			slog.String("code", status.Code(err).String()),
+			slog.Duration("duration", time.Since(start)),
+		)
+		return res, err
+	})
+}
+
+// DialOption passes the request id of the context on to the requests sent
+func DialOption() grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := RequestID(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, requestIDKey, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	})
+}
@@ -0,0 +1,173 @@
+// Package metrics exposes prometheus metrics of nebula processes over HTTP
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/priyansh32/nebula/internal/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// NewRegistry returns a registry with the go runtime and process collectors
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector())
+	reg.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return reg
+}
+
+// Serve serves the metrics of reg at /metrics on address in the
+// background, it returns an error if address cannot be listened on
+func Serve(address string, reg *prometheus.Registry) error {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		logging.Logger("metrics").Info("serving metrics", "address", address)
+		if err := server.Serve(lis); err != nil {
+			logging.Logger("metrics").Error("metrics server stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// latency buckets from 100µs to about 1.6s, requests are served from memory
+var latencyBuckets = prometheus.ExponentialBuckets(0.0001, 2, 15)
+
+// ServerMetrics counts the requests served by a gRPC server and their latency
+type ServerMetrics struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// NewServerMetrics registers the request metrics of the server of a
+// component, such as "store" or "coordinator", with reg
+func NewServerMetrics(reg prometheus.Registerer, component string) *ServerMetrics {
+	m := &ServerMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "nebula",
+			Subsystem: component,
+			Name:      "requests_total",
+			Help:      "Requests served, by RPC, gRPC code and the status in the response.",
+		}, []string{"method", "code", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "nebula",
+			Subsystem: component,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of the requests served, by RPC and gRPC code.",
+			Buckets:   latencyBuckets,
+		}, []string{"method", "code"}),
+	}
+	reg.MustRegister(m.requests, m.latency)
+	return m
+}
+
+// UnaryInterceptor records every request served
+func (m *ServerMetrics) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+
+		method := path.Base(info.FullMethod)
+		code := status.Code(err).String()
+		m.requests.WithLabelValues(method, code, responseStatus(res)).Inc()
+		m.latency.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+
+		return res, err
+	}
+}
+
+// ClientMetrics counts the requests a process sends to each of its peers
+type ClientMetrics struct {
+	peer     string
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// NewClientMetrics registers the metrics of the requests a component sends
+// to peers of the given kind, such as "store", with reg
+func NewClientMetrics(reg prometheus.Registerer, component string, peer string) *ClientMetrics {
+	m := &ClientMetrics{
+		peer: peer,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "nebula",
+			Subsystem: component,
+			Name:      peer + "_requests_total",
+			Help:      "Requests sent, by " + peer + ", RPC and gRPC code.",
+		}, []string{peer, "method", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "nebula",
+			Subsystem: component,
+			Name:      peer + "_request_duration_seconds",
+			Help:      "Latency of the requests sent, by " + peer + " and RPC.",
+			Buckets:   latencyBuckets,
+		}, []string{peer, "method"}),
+	}
+	reg.MustRegister(m.requests, m.latency)
+	return m
+}
+
+// UnaryInterceptor records every request sent to the named peer
+func (m *ClientMetrics) UnaryInterceptor(name string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		method = path.Base(method)
+		m.requests.WithLabelValues(name, method, status.Code(err).String()).Inc()
+		m.latency.WithLabelValues(name, method).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+// Forget drops the metrics of a peer that is gone
+func (m *ClientMetrics) Forget(name string) {
+	m.requests.DeletePartialMatch(prometheus.Labels{m.peer: name})
+	m.latency.DeletePartialMatch(prometheus.Labels{m.peer: name})
+}
+
+// returns the name of the status enum of a response, empty if it has none
+func responseStatus(res interface{}) string {
+	msg, ok := res.(proto.Message)
+	if !ok || msg == nil {
+		return ""
+	}
+
+	r := msg.ProtoReflect()
+	if !r.IsValid() {
+		return ""
+	}
+
+	field := r.Descriptor().Fields().ByName("status")
+	if field == nil || field.Kind() != protoreflect.EnumKind {
+		return ""
+	}
+
+	value := field.Enum().Values().ByNumber(r.Get(field).Enum())
+	if value == nil {
+		return ""
+	}
+	return string(value.Name())
+}
@@ -0,0 +1,228 @@
+// Package resp reads the commands of redis clients and writes the replies
+// to them in the redis serialization protocol, RESP2 or RESP3, so that
+// nebula can be reached with redis client libraries.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// limits on the commands read, bulk strings larger than the 4MB messages
+// of gRPC could not be stored anyway
+const (
+	maxArgs    = 1024 * 1024
+	maxBulk    = 4 * 1024 * 1024
+	maxInline  = 64 * 1024
+	maxCommand = 64 * 1024 * 1024
+)
+
+// bulk strings are read in chunks of this size, so that the memory taken
+// by a command grows with the data actually received
+const bulkChunk = 64 * 1024
+
+// ProtocolError is a command that does not follow the protocol, the
+// connection it was read from cannot be read any further
+type ProtocolError string
+
+func (e ProtocolError) Error() string {
+	return "Protocol error: " + string(e)
+}
+
+// Reader reads the commands of a client
+type Reader struct {
+	r     *bufio.Reader
+	limit int
+
+	// left is what remains of the limit for the command being read
+	left int
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, maxInline), limit: maxCommand}
+}
+
+// SetLimit limits the size of the next commands to n bytes, such as the
+// ones of clients that did not authenticate yet. The limit cannot be raised
+// past the default of 64MB, which zero restores.
+func (r *Reader) SetLimit(n int) {
+	if n <= 0 {
+		n = maxCommand
+	}
+	r.limit = min(n, maxCommand)
+}
+
+// Buffered reports whether more commands were already received, such as
+// the next ones of a pipeline
+func (r *Reader) Buffered() bool {
+	return r.r.Buffered() > 0
+}
+
+// ReadCommand reads the next command, either an array of bulk strings or
+// an inline command of words separated by spaces. It returns no arguments
+// for empty commands, which are skipped by redis.
+func (r *Reader) ReadCommand() ([]string, error) {
+	r.left = r.limit
+
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxArgs {
+		return nil, ProtocolError("invalid multibulk length")
+	}
+
+	args := make([]string, 0, min(max(n, 0), 1024))
+	for i := 0; i < n; i++ {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, ProtocolError(fmt.Sprintf("expected '$', got '%.1s'", line))
+		}
+
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulk {
+			return nil, ProtocolError("invalid bulk length")
+		}
+		if err := r.take(size + 2); err != nil {
+			return nil, err
+		}
+
+		data, err := r.readBulk(size + 2)
+		if err != nil {
+			return nil, err
+		}
+		if data[size] != '\r' || data[size+1] != '\n' {
+			return nil, ProtocolError("bulk string is not terminated by CRLF")
+		}
+		args = append(args, string(data[:size]))
+	}
+	return args, nil
+}
+
+// counts n bytes of the command against its limit
+func (r *Reader) take(n int) error {
+	if n > r.left {
+		return ProtocolError("too big command")
+	}
+	r.left -= n
+	return nil
+}
+
+// reads n bytes, growing the buffer as they arrive rather than allocating
+// the size announced by the client up front
+func (r *Reader) readBulk(n int) ([]byte, error) {
+	data := make([]byte, 0, min(n, bulkChunk))
+	for len(data) < n {
+		chunk := min(n-len(data), bulkChunk)
+		data = slices.Grow(data, chunk)[:len(data)+chunk]
+		if _, err := io.ReadFull(r.r, data[len(data)-chunk:]); err != nil {
+			return nil, unexpected(err)
+		}
+	}
+	return data, nil
+}
+
+// reads a line without its terminator
+func (r *Reader) readLine() (string, error) {
+	line, err := r.r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return "", ProtocolError("too big inline request")
+	}
+	if err != nil {
+		if len(line) > 0 {
+			return "", unexpected(err)
+		}
+		return "", err
+	}
+	if err := r.take(len(line)); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(line[:len(line)-1]), "\r"), nil
+}
+
+// a connection closed in the middle of a command
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Writer writes the replies to a client, in RESP2 unless Version is 3.
+// Replies are buffered until Flush, errors are returned by Flush.
+type Writer struct {
+	w       *bufio.Writer
+	Version int
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), Version: 2}
+}
+
+// SimpleString writes a status reply such as OK
+func (w *Writer) SimpleString(s string) {
+	w.line('+', strings.NewReplacer("\r", " ", "\n", " ").Replace(s))
+}
+
+// Error writes an error reply, msg starts with its code such as ERR
+func (w *Writer) Error(msg string) {
+	w.line('-', strings.NewReplacer("\r", " ", "\n", " ").Replace(msg))
+}
+
+func (w *Writer) Integer(n int64) {
+	w.line(':', strconv.FormatInt(n, 10))
+}
+
+func (w *Writer) Bulk(s string) {
+	w.line('$', strconv.Itoa(len(s)))
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+// Null writes a missing value, the null bulk string of RESP2
+func (w *Writer) Null() {
+	if w.Version == 3 {
+		w.w.WriteString("_\r\n")
+		return
+	}
+	w.w.WriteString("$-1\r\n")
+}
+
+// Array starts an array of n elements, which are written next
+func (w *Writer) Array(n int) {
+	w.line('*', strconv.Itoa(n))
+}
+
+// Map starts a map of n pairs, whose keys and values are written next. It
+// is a flat array of 2n elements in RESP2.
+func (w *Writer) Map(n int) {
+	if w.Version == 3 {
+		w.line('%', strconv.Itoa(n))
+		return
+	}
+	w.Array(2 * n)
+}
+
+func (w *Writer) line(kind byte, s string) {
+	w.w.WriteByte(kind)
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+// Flush sends the replies written so far
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,291 @@
+// Package slowlog keeps the operations of a nebula process that took
+// longer than a threshold, along with the time spent in each of their
+// phases, and tracks the latency of every command.
+package slowlog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/priyansh32/nebula/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Config controls which operations are kept and how many
+type Config struct {
+	// Threshold is the duration above which an operation is slow, every
+	// operation is kept if it is zero and none if it is negative
+	Threshold time.Duration
+
+	// Size is the number of slow operations kept, the oldest are dropped
+	Size int
+
+	// HashKeys keeps a hash of the keys of slow operations instead of the keys
+	HashKeys bool
+}
+
+func DefaultConfig() Config {
+	return Config{Threshold: 10 * time.Millisecond, Size: 128}
+}
+
+func (cfg Config) validate() error {
+	if cfg.Size < 1 {
+		return errors.New("slow log size must be greater than 0")
+	}
+	return nil
+}
+
+// Phase is the time an operation spent on one of its steps, on a store if
+// the step involved one
+type Phase struct {
+	Name     string
+	Store    string
+	Duration time.Duration
+}
+
+// Entry is a slow operation
+type Entry struct {
+	ID        uint64
+	Time      time.Time
+	Command   string
+	Key       string
+	RequestID string
+	Duration  time.Duration
+	Phases    []Phase
+}
+
+// Stores returns the stores involved in the operation, in order of their
+// first phase
+func (e Entry) Stores() []string {
+	stores := make([]string, 0)
+	for _, p := range e.Phases {
+		if p.Store != "" && !slices.Contains(stores, p.Store) {
+			stores = append(stores, p.Store)
+		}
+	}
+	return stores
+}
+
+// Latency sums up the latency of the operations of a command
+type Latency struct {
+	Command string
+	Calls   uint64
+	Slow    uint64
+	Total   time.Duration
+	Max     time.Duration
+}
+
+// Log keeps the latest slow operations in a ring buffer
+type Log struct {
+	cfg Config
+
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+	lastID  uint64
+	latency map[string]*Latency
+}
+
+// New returns an empty slow log
+func New(cfg Config) (*Log, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &Log{
+		cfg:     cfg,
+		entries: make([]Entry, cfg.Size),
+		latency: make(map[string]*Latency),
+	}, nil
+}
+
+// Enabled reports whether any operation is kept
+func (l *Log) Enabled() bool {
+	return l.cfg.Threshold >= 0
+}
+
+// records an operation, keeping it if it is slow
+func (l *Log) record(command string, key string, requestID string, start time.Time, duration time.Duration, phases []Phase) {
+	slow := duration >= l.cfg.Threshold
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lat := l.latency[command]
+	if lat == nil {
+		lat = &Latency{Command: command}
+		l.latency[command] = lat
+	}
+	lat.Calls++
+	lat.Total += duration
+	lat.Max = max(lat.Max, duration)
+
+	if !slow {
+		return
+	}
+	lat.Slow++
+
+	if l.cfg.HashKeys {
+		key = hashKey(key)
+	}
+
+	l.lastID++
+	l.entries[l.next] = Entry{
+		ID:        l.lastID,
+		Time:      start,
+		Command:   command,
+		Key:       key,
+		RequestID: requestID,
+		Duration:  duration,
+		Phases:    phases,
+	}
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Entries returns up to n of the latest slow operations, newest first,
+// all of them if n is zero or less
+func (l *Log) Entries(n int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := l.next
+	if l.full {
+		count = len(l.entries)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+
+	entries := make([]Entry, 0, n)
+	for i := 1; i <= n; i++ {
+		entries = append(entries, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return entries
+}
+
+// Latencies returns the latency of every command served so far, by command
+func (l *Log) Latencies() []Latency {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	latencies := make([]Latency, 0, len(l.latency))
+	for _, lat := range l.latency {
+		latencies = append(latencies, *lat)
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i].Command < latencies[j].Command
+	})
+	return latencies
+}
+
+// Reset drops the slow operations and the latency of commands
+func (l *Log) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = make([]Entry, len(l.entries))
+	l.next = 0
+	l.full = false
+	l.latency = make(map[string]*Latency)
+}
+
+// ServerOption times every request for a key served, such as a GET, and
+// keeps the slow ones
+func (l *Log) ServerOption() grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(l.UnaryInterceptor())
+}
+
+// UnaryInterceptor is the interceptor of ServerOption
+func (l *Log) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key, ok := requestKey(req)
+		if !ok || !l.Enabled() {
+			return handler(ctx, req)
+		}
+
+		o := &op{}
+		start := time.Now()
+		res, err := handler(context.WithValue(ctx, opContextKey{}, o), req)
+		duration := time.Since(start)
+
+		command := strings.ToUpper(path.Base(info.FullMethod))
+		l.record(command, key, logging.RequestID(ctx), start, duration, o.done())
+		return res, err
+	}
+}
+
+// op collects the phases of an operation in progress, which may run
+// concurrently such as requests to several replicas
+type op struct {
+	mu       sync.Mutex
+	phases   []Phase
+	finished bool
+}
+
+// returns the phases of the operation, phases ending later are dropped
+func (o *op) done() []Phase {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.finished = true
+	return o.phases
+}
+
+type opContextKey struct{}
+
+// Track starts a phase of the operation ctx belongs to, named after what
+// it does and the store it involves if any, and returns the function that
+// ends it. It does nothing if the operation is not timed.
+func Track(ctx context.Context, name string, store string) func() {
+	o, ok := ctx.Value(opContextKey{}).(*op)
+	if !ok {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		duration := time.Since(start)
+
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		if !o.finished {
+			o.phases = append(o.phases, Phase{Name: name, Store: store, Duration: duration})
+		}
+	}
+}
+
+// returns the key of a request, if it has a key field
+func requestKey(req interface{}) (string, bool) {
+	msg, ok := req.(proto.Message)
+	if !ok || msg == nil {
+		return "", false
+	}
+
+	r := msg.ProtoReflect()
+	field := r.Descriptor().Fields().ByName("key")
+	if field == nil || field.Kind() != protoreflect.StringKind || field.IsList() {
+		return "", false
+	}
+	return r.Get(field).String(), true
+}
+
+// hashes a key so that it can be matched against other keys without
+// being revealed
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
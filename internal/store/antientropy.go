@@ -0,0 +1,246 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	pb "github.com/priyansh32/nebula/internal/api/store"
+	"github.com/priyansh32/nebula/internal/auth"
+	"github.com/priyansh32/nebula/internal/logging"
+	"github.com/priyansh32/nebula/internal/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// a missing range is the whole ring
+func rangeOf(r *pb.KeyRange) keyRange {
+	return keyRange{start: r.GetStart(), end: r.GetEnd()}
+}
+
+func checkNodes(nodes []uint32, leaves bool) error {
+	for _, n := range nodes {
+		if n < 1 || n >= 2<<merkleDepth || (leaves && !isLeaf(int(n))) {
+			return status.Errorf(codes.InvalidArgument, "no merkle tree node %d", n)
+		}
+	}
+	return nil
+}
+
+// MerkleDigests returns the digests of tree nodes over the keys in a range
+// replicas compare them to find the parts of the range where they differ
+func (s *store) MerkleDigests(ctx context.Context, in *pb.MerkleDigestsRequest) (*pb.MerkleDigestsResponse, error) {
+	if err := checkNodes(in.Nodes, false); err != nil {
+		return nil, err
+	}
+
+	r := rangeOf(in.Range)
+	digests := make([][]byte, 0, len(in.Nodes))
+
+	s.mu.Lock()
+	for _, n := range in.Nodes {
+		d := s.cache.tree.digest(int(n), r)
+		digests = append(digests, d[:])
+	}
+	s.mu.Unlock()
+
+	return &pb.MerkleDigestsResponse{Digests: digests}, nil
+}
+
+// MerkleEntries returns the entries under leaves of the tree that are in a range
+func (s *store) MerkleEntries(ctx context.Context, in *pb.MerkleEntriesRequest) (*pb.MerkleEntriesResponse, error) {
+	if err := checkNodes(in.Nodes, true); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	local := s.entries(in.Nodes, rangeOf(in.Range))
+	s.mu.Unlock()
+
+	entries := make([]*pb.Entry, 0, len(local))
+	for _, p := range local {
+		entries = append(entries, &pb.Entry{Key: p.key, Value: p.value, Version: p.version, Deleted: p.deleted, ExpiresAtMs: p.expires})
+	}
+
+	return &pb.MerkleEntriesResponse{Entries: entries}, nil
+}
+
+// SyncRanges compares ranges with a peer replica and exchanges the keys
+// that differ, each side ends up with the latest version of every key
+func (s *store) SyncRanges(ctx context.Context, in *pb.SyncRangesRequest) (*pb.SyncRangesResponse, error) {
+	peer, err := s.peer(in.Peer)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pb.SyncRangesResponse{Status: pb.StatusType_OK}
+	for _, r := range in.Ranges {
+		pulled, pushed, err := s.syncRange(ctx, peer, r)
+		res.Pulled += pulled
+		res.Pushed += pushed
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if res.Pulled > 0 || res.Pushed > 0 {
+		logging.Logger("antientropy").InfoContext(ctx, "synced with a peer", "peer", in.Peer, "pulled", res.Pulled, "pushed", res.Pushed)
+	}
+
+	return res, nil
+}
+
+// compares the tree of a range with the peer's from the root down, only
+// descending into nodes whose digests differ, then exchanges the keys
+// under the leaves that differ
+func (s *store) syncRange(ctx context.Context, peer pb.KeyValueStoreClient, kr *pb.KeyRange) (uint64, uint64, error) {
+	r := rangeOf(kr)
+
+	nodes := []uint32{1}
+	leaves := make([]uint32, 0)
+	for len(nodes) > 0 {
+		res, err := peer.MerkleDigests(ctx, &pb.MerkleDigestsRequest{Range: kr, Nodes: nodes})
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(res.Digests) != len(nodes) {
+			return 0, 0, errors.New("peer did not return a digest for every node")
+		}
+
+		next := make([]uint32, 0)
+		s.mu.Lock()
+		for i, n := range nodes {
+			d := s.cache.tree.digest(int(n), r)
+			if bytes.Equal(d[:], res.Digests[i]) {
+				continue
+			}
+			if isLeaf(int(n)) {
+				leaves = append(leaves, n)
+			} else {
+				next = append(next, 2*n, 2*n+1)
+			}
+		}
+		s.mu.Unlock()
+		nodes = next
+	}
+
+	if len(leaves) == 0 {
+		return 0, 0, nil
+	}
+
+	res, err := peer.MerkleEntries(ctx, &pb.MerkleEntriesRequest{Range: kr, Nodes: leaves})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var pulled uint64
+	remote := make(map[string]*pb.Entry, len(res.Entries))
+	push := make([]Pair, 0)
+
+	s.mu.Lock()
+	local := s.entries(leaves, r)
+	for _, e := range res.Entries {
+		remote[e.Key] = e
+		if l, ok := local[e.Key]; ok && l.version >= e.Version {
+			continue
+		}
+		if e.Deleted {
+			s.cache.RemoveVersion(e.Key, e.Version)
+		} else {
+			s.cache.PutVersion(e.Key, e.Value, e.Version, e.ExpiresAtMs)
+		}
+		pulled++
+	}
+	for key, l := range local {
+		if e, ok := remote[key]; !ok || l.version > e.Version {
+			push = append(push, l)
+		}
+	}
+	s.mu.Unlock()
+
+	var pushed uint64
+	for _, p := range push {
+		var err error
+		if p.deleted {
+			_, err = peer.Delete(ctx, &pb.DeleteRequest{Key: p.key, Version: p.version})
+		} else {
+			_, err = peer.Put(ctx, &pb.PutRequest{Key: p.key, Value: p.value, Version: p.version, ExpiresAtMs: p.expires})
+		}
+		if err != nil {
+			return pulled, pushed, err
+		}
+		pushed++
+	}
+
+	return pulled, pushed, nil
+}
+
+// returns the entries under leaves that are in r, s.mu must be held
+func (s *store) entries(leaves []uint32, r keyRange) map[string]Pair {
+	entries := make(map[string]Pair)
+	for _, n := range leaves {
+		for _, key := range s.cache.tree.keys(int(n), r) {
+			if p, ok := s.cache.peek(key); ok {
+				entries[key] = p
+			}
+		}
+	}
+	return entries
+}
+
+// connections to peers that were not used for this long are closed
+const peerIdleTimeout = 10 * time.Minute
+
+// peerConn is a connection to another store kept for later syncs
+type peerConn struct {
+	conn *grpc.ClientConn
+	used time.Time
+}
+
+// returns a client for another store of the ring, connections are kept
+// for later syncs until they are idle or the store leaves the ring
+func (s *store) peer(address string) (pb.KeyValueStoreClient, error) {
+	s.rangesMu.Lock()
+	known := s.members[address]
+	s.rangesMu.Unlock()
+
+	// the peer comes from the request, only the stores the coordinator
+	// assigned are dialed
+	if !known {
+		return nil, status.Errorf(codes.FailedPrecondition, "%s is not a store of the ring", address)
+	}
+
+	now := time.Now()
+	s.closePeers(func(_ string, p *peerConn) bool { return now.Sub(p.used) > peerIdleTimeout })
+
+	s.peersMu.Lock()
+	defer s.peersMu.Unlock()
+
+	p, ok := s.peers[address]
+	if !ok {
+		conn, err := grpc.Dial(address, s.creds.DialOption(), auth.TokenDialOption(s.authToken), tracing.DialOption(), logging.DialOption())
+		if err != nil {
+			return nil, err
+		}
+		p = &peerConn{conn: conn}
+		s.peers[address] = p
+	}
+	p.used = now
+
+	return pb.NewKeyValueStoreClient(p.conn), nil
+}
+
+// closes the connections to the peers that match
+func (s *store) closePeers(match func(address string, p *peerConn) bool) {
+	s.peersMu.Lock()
+	defer s.peersMu.Unlock()
+
+	for address, p := range s.peers {
+		if match(address, p) {
+			p.conn.Close()
+			delete(s.peers, address)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	pb "github.com/priyansh32/nebula/internal/api/store"
+	"github.com/priyansh32/nebula/internal/auth"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// AuthConfig controls who may send which requests to the store
+type AuthConfig struct {
+	// RulesFile, if set, is the file of the roles and users allowed to
+	// send requests, see auth.Rules. Requests are not authenticated if it
+	// is empty.
+	RulesFile string
+
+	// Token is the bearer token the store sends to the coordinator and to
+	// other stores
+	Token string
+}
+
+// the access every request to the store needs, requests that are not
+// listed need admin access
+var methodAccess = map[string]auth.Access{
+	// clients routing reads themselves read their keys
+	storeMethod("Get"):  auth.Read,
+	storeMethod("Scan"): auth.Read,
+
+	// the coordinator and other stores write, replicate, repair and place
+	// keys, clients write through a coordinator
+	storeMethod("Put"):           auth.Cluster,
+	storeMethod("Delete"):        auth.Cluster,
+	storeMethod("MerkleDigests"): auth.Cluster,
+	storeMethod("MerkleEntries"): auth.Cluster,
+	storeMethod("SyncRanges"):    auth.Cluster,
+	storeMethod("AssignRanges"):  auth.Cluster,
+
+	// the slow log and the hot keys reveal the keys of other users
+	storeMethod("Stats"):   auth.Cluster,
+	storeMethod("SlowLog"): auth.Cluster,
+	storeMethod("HotKeys"): auth.Cluster,
+
+	"/" + healthpb.Health_ServiceDesc.ServiceName + "/Check": auth.Authenticated,
+}
+
+// returns the full name of a method of the store API
+func storeMethod(name string) string {
+	return "/" + pb.KeyValueStore_ServiceDesc.ServiceName + "/" + name
+}
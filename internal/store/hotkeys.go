@@ -0,0 +1,204 @@
+package store
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"hash/maphash"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/priyansh32/nebula/internal/api/store"
+)
+
+// HotKeysConfig controls how the accesses of keys are counted
+type HotKeysConfig struct {
+	// TopK is the number of most accessed keys tracked
+	TopK int
+
+	// HalfLife is the period after which past accesses count half, so
+	// that keys that were hot long ago leave the top keys
+	HalfLife time.Duration
+
+	// Width and Depth size the count-min sketch estimating the accesses
+	// of every key, larger sketches overestimate less
+	Width int
+	Depth int
+}
+
+func DefaultHotKeysConfig() HotKeysConfig {
+	return HotKeysConfig{
+		TopK:     32,
+		HalfLife: time.Minute,
+		Width:    4096,
+		Depth:    4,
+	}
+}
+
+func (cfg HotKeysConfig) validate() error {
+	if cfg.TopK < 1 {
+		return errors.New("the number of hot keys must be greater than 0")
+	}
+	if cfg.HalfLife <= 0 {
+		return errors.New("the half life of key accesses must be greater than 0")
+	}
+	if cfg.Width < 1 || cfg.Depth < 1 {
+		return errors.New("the sketch width and depth must be greater than 0")
+	}
+	return nil
+}
+
+// hotKeys estimates the accesses of every key with a count-min sketch and
+// keeps the most accessed ones in a min heap
+type hotKeys struct {
+	cfg HotKeysConfig
+
+	mu       sync.Mutex
+	seeds    []maphash.Seed
+	counts   [][]uint32
+	top      topKeys
+	index    map[string]*hotKey
+	lastHalf time.Time
+}
+
+// hotKey is a key of the top keys
+type hotKey struct {
+	key   string
+	count uint32
+	pos   int
+}
+
+func newHotKeys(cfg HotKeysConfig) (*hotKeys, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	h := &hotKeys{
+		cfg:      cfg,
+		seeds:    make([]maphash.Seed, cfg.Depth),
+		counts:   make([][]uint32, cfg.Depth),
+		index:    make(map[string]*hotKey),
+		lastHalf: time.Now(),
+	}
+	for i := range h.counts {
+		h.seeds[i] = maphash.MakeSeed()
+		h.counts[i] = make([]uint32, cfg.Width)
+	}
+	return h, nil
+}
+
+// counts an access of key
+func (h *hotKeys) record(key string, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if halves := now.Sub(h.lastHalf) / h.cfg.HalfLife; halves > 0 {
+		h.decay(uint(min(halves, 32)))
+		h.lastHalf = h.lastHalf.Add(halves * h.cfg.HalfLife)
+	}
+
+	// the estimate is the smallest of the counters of the key
+	estimate := ^uint32(0)
+	for i, row := range h.counts {
+		j := maphash.String(h.seeds[i], key) % uint64(len(row))
+		if row[j] < ^uint32(0) {
+			row[j]++
+		}
+		estimate = min(estimate, row[j])
+	}
+
+	if k, ok := h.index[key]; ok {
+		k.count = estimate
+		heap.Fix(&h.top, k.pos)
+		return
+	}
+
+	if len(h.top) < h.cfg.TopK {
+		k := &hotKey{key: key, count: estimate}
+		heap.Push(&h.top, k)
+		h.index[key] = k
+		return
+	}
+
+	// the key replaces the least accessed of the top keys
+	if coldest := h.top[0]; estimate > coldest.count {
+		delete(h.index, coldest.key)
+		coldest.key, coldest.count = key, estimate
+		h.index[key] = coldest
+		heap.Fix(&h.top, 0)
+	}
+}
+
+// halves every counter the given number of times so that past accesses
+// weigh less, h.mu must be held
+func (h *hotKeys) decay(halves uint) {
+	for _, row := range h.counts {
+		for j := range row {
+			row[j] >>= halves
+		}
+	}
+	for _, k := range h.top {
+		k.count >>= halves
+	}
+}
+
+// returns up to n of the most accessed keys with their estimated
+// accesses, most accessed first, all tracked keys if n is zero or less
+func (h *hotKeys) hottest(n int) []hotKey {
+	h.mu.Lock()
+	keys := make([]hotKey, 0, len(h.top))
+	for _, k := range h.top {
+		if k.count > 0 {
+			keys = append(keys, *k)
+		}
+	}
+	h.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].count != keys[j].count {
+			return keys[i].count > keys[j].count
+		}
+		return keys[i].key < keys[j].key
+	})
+
+	if n > 0 && n < len(keys) {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// topKeys is a min heap of keys by count
+type topKeys []*hotKey
+
+func (t topKeys) Len() int           { return len(t) }
+func (t topKeys) Less(i, j int) bool { return t[i].count < t[j].count }
+
+func (t topKeys) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+	t[i].pos = i
+	t[j].pos = j
+}
+
+func (t *topKeys) Push(x interface{}) {
+	k := x.(*hotKey)
+	k.pos = len(*t)
+	*t = append(*t, k)
+}
+
+func (t *topKeys) Pop() interface{} {
+	old := *t
+	k := old[len(old)-1]
+	*t = old[:len(old)-1]
+	return k
+}
+
+// HotKeys returns the most accessed keys of the store with an estimate of
+// their recent accesses
+func (s *store) HotKeys(ctx context.Context, in *pb.HotKeysRequest) (*pb.HotKeysResponse, error) {
+	res := &pb.HotKeysResponse{HalfLifeMs: s.hotKeys.cfg.HalfLife.Milliseconds()}
+	for _, k := range s.hotKeys.hottest(int(in.Count)) {
+		res.Keys = append(res.Keys, &pb.HotKey{Key: k.key, Count: uint64(k.count)})
+	}
+	return res, nil
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHotKeysConfigValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *HotKeysConfig)
+		ok     bool
+	}{
+		{name: "default", modify: func(cfg *HotKeysConfig) {}, ok: true},
+		{name: "no top keys", modify: func(cfg *HotKeysConfig) { cfg.TopK = 0 }},
+		{name: "no half life", modify: func(cfg *HotKeysConfig) { cfg.HalfLife = 0 }},
+		{name: "no width", modify: func(cfg *HotKeysConfig) { cfg.Width = 0 }},
+		{name: "no depth", modify: func(cfg *HotKeysConfig) { cfg.Depth = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultHotKeysConfig()
+			tt.modify(&cfg)
+			if _, err := newHotKeys(cfg); (err == nil) != tt.ok {
+				t.Errorf("newHotKeys() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestHotKeys(t *testing.T) {
+	// access is a number of accesses of a key, after a number of half lives
+	type access struct {
+		key    string
+		times  int
+		halves int
+	}
+
+	tests := []struct {
+		name     string
+		topK     int
+		width    int
+		accesses []access
+		n        int
+		want     []hotKey
+	}{
+		{
+			name:     "most accessed first",
+			accesses: []access{{key: "a", times: 2}, {key: "b", times: 5}, {key: "c", times: 2}},
+			want:     []hotKey{{key: "b", count: 5}, {key: "a", count: 2}, {key: "c", count: 2}},
+		},
+		{
+			name:     "limited to n keys",
+			accesses: []access{{key: "a", times: 2}, {key: "b", times: 5}, {key: "c", times: 1}},
+			n:        2,
+			want:     []hotKey{{key: "b", count: 5}, {key: "a", count: 2}},
+		},
+		{
+			name:     "only the top keys are tracked",
+			topK:     2,
+			accesses: []access{{key: "a", times: 5}, {key: "b", times: 3}, {key: "c", times: 1}},
+			want:     []hotKey{{key: "a", count: 5}, {key: "b", count: 3}},
+		},
+		{
+			name:     "a key overtaking the coldest top key",
+			topK:     2,
+			accesses: []access{{key: "a", times: 5}, {key: "b", times: 3}, {key: "c", times: 4}},
+			want:     []hotKey{{key: "a", count: 5}, {key: "c", count: 4}},
+		},
+		{
+			name:     "past accesses count half",
+			accesses: []access{{key: "a", times: 8}, {key: "b", times: 1, halves: 1}},
+			want:     []hotKey{{key: "a", count: 4}, {key: "b", count: 1}},
+		},
+		{
+			name:     "keys hot long ago leave",
+			topK:     1,
+			accesses: []access{{key: "a", times: 4}, {key: "b", times: 1, halves: 3}},
+			want:     []hotKey{{key: "b", count: 1}},
+		},
+		{
+			// every key shares the counters of a sketch one counter wide
+			name:     "colliding keys are overestimated",
+			width:    1,
+			accesses: []access{{key: "a", times: 3}, {key: "b", times: 2}},
+			want:     []hotKey{{key: "b", count: 5}, {key: "a", count: 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultHotKeysConfig()
+			if tt.topK > 0 {
+				cfg.TopK = tt.topK
+			}
+			if tt.width > 0 {
+				cfg.Width = tt.width
+			}
+			h, err := newHotKeys(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := h.lastHalf
+			for _, a := range tt.accesses {
+				now := start.Add(time.Duration(a.halves) * cfg.HalfLife)
+				for i := 0; i < a.times; i++ {
+					h.record(a.key, now)
+				}
+			}
+
+			got := h.hottest(tt.n)
+			if len(got) != len(tt.want) {
+				t.Fatalf("hottest(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+			for i := range got {
+				if got[i].key != tt.want[i].key || got[i].count != tt.want[i].count {
+					t.Fatalf("hottest(%d) = %v, want %v", tt.n, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -1,60 +1,239 @@
 package store
 
 import (
+	"container/heap"
 	"container/list"
 	"errors"
+	"time"
 )
 
 type LRUCache struct {
 	capacity uint32
 	cache    map[string]*list.Element
 	list     *list.List
+
+	// digests the cached keys for anti-entropy
+	tree *merkleTree
+
+	// bookkeeping for stats
+	bytes      uint64
+	tombstones uint32
+	evictions  uint64
+
+	// values that have yet to expire, the first to expire on top
+	expiring   expiringValues
+	expiringOf map[string]*expiringValue
+
+	// values that expired and are still kept, and every value that
+	// expired, counted once when it is reaped or dropped
+	expired     uint32
+	expirations uint64
 }
 
+// rough memory held by an entry besides its key and value: the map entry,
+// the list element, the pair and its merkle leaf
+const entryOverhead = 160
+
+// Pair is a cached key, a deleted key is kept as a tombstone so that its
+// version is remembered
 type Pair struct {
-	key   string
-	value string
+	key     string
+	value   string
+	version int64
+	deleted bool
+
+	// expires is when the value expires in unix milliseconds, 0 if never.
+	// An expired value is kept like a tombstone until it is overwritten or
+	// evicted, so that replicas agree on it.
+	expires int64
+}
+
+// reports whether the value of the pair has expired at now
+func (p Pair) expired(now time.Time) bool {
+	return p.expires != 0 && now.UnixMilli() >= p.expires
 }
 
 func LRUConstructor(capacity uint32) *LRUCache {
 	return &LRUCache{
-		capacity: capacity,
-		cache:    make(map[string]*list.Element),
-		list:     list.New(),
+		capacity:   capacity,
+		cache:      make(map[string]*list.Element),
+		list:       list.New(),
+		tree:       newMerkleTree(),
+		expiringOf: make(map[string]*expiringValue),
 	}
 }
 
 func (lr *LRUCache) Get(key string) (string, error) {
+	if pair, ok := lr.Lookup(key); ok && !pair.deleted && !pair.expired(time.Now()) {
+		return pair.value, nil
+	}
+	return "", errors.New("cache miss")
+}
+
+// Lookup returns the entry of a key, which may be a tombstone
+func (lr *LRUCache) Lookup(key string) (Pair, bool) {
 	if elem, ok := lr.cache[key]; ok {
 		lr.list.MoveToFront(elem)
-		return elem.Value.(Pair).value, nil
+		return elem.Value.(Pair), true
 	}
-	return "", errors.New("cache miss")
+	return Pair{}, false
+}
+
+// returns the entry of a key without marking it as recently used
+func (lr *LRUCache) peek(key string) (Pair, bool) {
+	if elem, ok := lr.cache[key]; ok {
+		return elem.Value.(Pair), true
+	}
+	return Pair{}, false
 }
 
 func (lr *LRUCache) Put(key string, value string) {
+	lr.set(Pair{key: key, value: value})
+}
+
+// PutVersion puts the value unless the cache holds a newer version of the key
+// a version of 0 always overwrites, it reports whether the value was put.
+// The value expires at expires in unix milliseconds, never if it is 0.
+func (lr *LRUCache) PutVersion(key string, value string, version int64, expires int64) bool {
+	if lr.newer(key, version) {
+		return false
+	}
+	lr.set(Pair{key: key, value: value, version: version, expires: expires})
+	return true
+}
+
+func (lr *LRUCache) Remove(key string) {
 	if elem, ok := lr.cache[key]; ok {
-		elem.Value = Pair{key, value}
+		lr.unlink(elem)
+	}
+}
+
+// RemoveVersion replaces the key with a tombstone unless the cache holds
+// a newer version of it, a version of 0 removes the key outright
+func (lr *LRUCache) RemoveVersion(key string, version int64) bool {
+	if version == 0 {
+		lr.Remove(key)
+		return true
+	}
+	if lr.newer(key, version) {
+		return false
+	}
+	lr.set(Pair{key: key, version: version, deleted: true})
+	return true
+}
+
+// reports whether the cache holds a version of key newer than version
+func (lr *LRUCache) newer(key string, version int64) bool {
+	if version == 0 {
+		return false
+	}
+	elem, ok := lr.cache[key]
+	return ok && elem.Value.(Pair).version > version
+}
+
+func (lr *LRUCache) set(pair Pair) {
+	if elem, ok := lr.cache[pair.key]; ok {
+		lr.forget(elem.Value.(Pair))
+		elem.Value = pair
 		lr.list.MoveToFront(elem)
 	} else {
 		if uint32(len(lr.cache)) >= lr.capacity {
 			// Remove the least recently used item
 			tail := lr.list.Back()
 			if tail != nil {
-				delete(lr.cache, tail.Value.(Pair).key)
-				lr.list.Remove(tail)
+				lr.unlink(tail)
+				lr.evictions++
 			}
 		}
 
 		// Add the new item to the front of the list
-		newElem := lr.list.PushFront(Pair{key, value})
-		lr.cache[key] = newElem
+		newElem := lr.list.PushFront(pair)
+		lr.cache[pair.key] = newElem
 	}
+	lr.count(pair)
+	lr.tree.update(pair)
 }
 
-func (lr *LRUCache) Remove(key string) {
-	if elem, ok := lr.cache[key]; ok {
-		delete(lr.cache, key)
-		lr.list.Remove(elem)
+// removes an entry from the cache
+func (lr *LRUCache) unlink(elem *list.Element) {
+	pair := elem.Value.(Pair)
+	delete(lr.cache, pair.key)
+	lr.list.Remove(elem)
+	lr.tree.remove(pair.key)
+	lr.forget(pair)
+}
+
+// adds an entry to the stats
+func (lr *LRUCache) count(pair Pair) {
+	lr.bytes += uint64(len(pair.key) + len(pair.value) + entryOverhead)
+	if pair.deleted {
+		lr.tombstones++
+	} else if pair.expires != 0 {
+		v := &expiringValue{key: pair.key, expires: pair.expires}
+		heap.Push(&lr.expiring, v)
+		lr.expiringOf[pair.key] = v
+	}
+}
+
+// removes an entry from the stats
+func (lr *LRUCache) forget(pair Pair) {
+	lr.bytes -= uint64(len(pair.key) + len(pair.value) + entryOverhead)
+	if pair.deleted {
+		lr.tombstones--
+	} else if pair.expires != 0 {
+		v, ok := lr.expiringOf[pair.key]
+		if !ok {
+			// reaped already
+			lr.expired--
+			return
+		}
+		heap.Remove(&lr.expiring, v.pos)
+		delete(lr.expiringOf, pair.key)
+		if pair.expired(time.Now()) {
+			lr.expirations++
+		}
 	}
 }
+
+// counts the values that expired at now, which are kept like tombstones
+// until they are overwritten or evicted
+func (lr *LRUCache) reap(now time.Time) {
+	for len(lr.expiring) > 0 && now.UnixMilli() >= lr.expiring[0].expires {
+		v := heap.Pop(&lr.expiring).(*expiringValue)
+		delete(lr.expiringOf, v.key)
+		lr.expired++
+		lr.expirations++
+	}
+}
+
+// expiringValue is when the value of a key expires, in unix milliseconds
+type expiringValue struct {
+	key     string
+	expires int64
+	pos     int
+}
+
+// expiringValues is a min heap of values by when they expire
+type expiringValues []*expiringValue
+
+func (e expiringValues) Len() int           { return len(e) }
+func (e expiringValues) Less(i, j int) bool { return e[i].expires < e[j].expires }
+
+func (e expiringValues) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+	e[i].pos = i
+	e[j].pos = j
+}
+
+func (e *expiringValues) Push(x interface{}) {
+	v := x.(*expiringValue)
+	v.pos = len(*e)
+	*e = append(*e, v)
+}
+
+func (e *expiringValues) Pop() interface{} {
+	old := *e
+	v := old[len(old)-1]
+	*e = old[:len(old)-1]
+	return v
+}
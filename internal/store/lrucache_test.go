@@ -0,0 +1,90 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpirations(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Second).UnixMilli()
+	future := now.Add(time.Hour).UnixMilli()
+
+	tests := []struct {
+		name            string
+		writes          func(lr *LRUCache)
+		wantExpired     uint32
+		wantExpirations uint64
+	}{
+		{
+			name: "values that have yet to expire",
+			writes: func(lr *LRUCache) {
+				lr.PutVersion("a", "v", 1, future)
+				lr.PutVersion("b", "v", 1, 0)
+			},
+		},
+		{
+			name: "expired values are reaped once",
+			writes: func(lr *LRUCache) {
+				lr.PutVersion("a", "v", 1, past)
+				lr.PutVersion("b", "v", 1, past)
+				lr.PutVersion("c", "v", 1, future)
+				lr.reap(now)
+			},
+			wantExpired:     2,
+			wantExpirations: 2,
+		},
+		{
+			name: "overwritten before it expired",
+			writes: func(lr *LRUCache) {
+				lr.PutVersion("a", "v", 1, future)
+				lr.PutVersion("a", "w", 2, 0)
+			},
+		},
+		{
+			name: "overwritten after it expired",
+			writes: func(lr *LRUCache) {
+				lr.PutVersion("a", "v", 1, past)
+				lr.PutVersion("a", "w", 2, 0)
+			},
+			wantExpirations: 1,
+		},
+		{
+			name: "deleted after it was reaped",
+			writes: func(lr *LRUCache) {
+				lr.PutVersion("a", "v", 1, past)
+				lr.reap(now)
+				lr.RemoveVersion("a", 2)
+			},
+			wantExpirations: 1,
+		},
+		{
+			name: "evicted after it expired",
+			writes: func(lr *LRUCache) {
+				lr.PutVersion("a", "v", 1, past)
+				lr.PutVersion("b", "v", 1, 0)
+				lr.PutVersion("c", "v", 1, 0)
+				lr.PutVersion("d", "v", 1, 0)
+			},
+			wantExpirations: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lr := LRUConstructor(3)
+			tt.writes(lr)
+			lr.reap(now)
+
+			if lr.expired != tt.wantExpired {
+				t.Errorf("%d expired values kept, want %d", lr.expired, tt.wantExpired)
+			}
+			if lr.expirations != tt.wantExpirations {
+				t.Errorf("%d expirations, want %d", lr.expirations, tt.wantExpirations)
+			}
+			if len(lr.expiring) != len(lr.expiringOf) {
+				t.Errorf("%d values expiring, %d by key", len(lr.expiring), len(lr.expiringOf))
+			}
+		})
+	}
+}
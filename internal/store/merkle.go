@@ -0,0 +1,231 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// depth of the merkle tree, it has 1 << merkleDepth leaves
+const merkleDepth = 10
+
+type digest [sha256.Size]byte
+
+// merkleTree digests the keys of a store by their position on the ring
+// nodes are numbered from 1 at the root, the children of node n are 2n and
+// 2n+1, and each leaf covers an equal slice of the ring. A leaf digest is
+// the xor of the digests of its keys, so that it is updated in place.
+type merkleTree struct {
+	nodes  []digest
+	leaves []map[string]leafEntry
+}
+
+type leafEntry struct {
+	hash   uint64
+	digest digest
+}
+
+func newMerkleTree() *merkleTree {
+	t := &merkleTree{
+		nodes:  make([]digest, 2<<merkleDepth),
+		leaves: make([]map[string]leafEntry, 1<<merkleDepth),
+	}
+	for i := range t.leaves {
+		t.leaves[i] = make(map[string]leafEntry)
+	}
+	return t
+}
+
+// keyRange is the part of the ring (start, end], it wraps around when
+// start >= end and covers the whole ring when they are equal
+type keyRange struct {
+	start, end uint64
+}
+
+func (r keyRange) contains(hash uint64) bool {
+	if r.start < r.end {
+		return hash > r.start && hash <= r.end
+	}
+	return hash > r.start || hash <= r.end
+}
+
+// reports whether the range covers all of [lo, hi] and whether it covers any of it
+func (r keyRange) overlap(lo, hi uint64) (all bool, any bool) {
+	if r.start == r.end {
+		return true, true
+	}
+	if r.start < r.end {
+		return lo > r.start && hi <= r.end, hi > r.start && lo <= r.end
+	}
+	// the range is (start, max] and [0, end]
+	all = lo > r.start || hi <= r.end
+	any = hi > r.start || lo <= r.end
+	return all, any
+}
+
+// hashKey hashes a key to its position on the ring, like the coordinator does
+func hashKey(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func entryDigest(p Pair) digest {
+	h := sha256.New()
+	h.Write([]byte(p.key))
+	h.Write([]byte{0})
+	h.Write([]byte(p.value))
+	binary.Write(h, binary.BigEndian, p.version)
+	if p.deleted {
+		h.Write([]byte{1})
+	}
+	if p.expires != 0 {
+		binary.Write(h, binary.BigEndian, p.expires)
+	}
+
+	var d digest
+	h.Sum(d[:0])
+	return d
+}
+
+func leafOf(hash uint64) int {
+	return int(hash >> (64 - merkleDepth))
+}
+
+// returns the part of the ring [lo, hi] covered by a node
+func bounds(node int) (uint64, uint64) {
+	level := bits.Len(uint(node)) - 1
+	if level == 0 {
+		return 0, math.MaxUint64
+	}
+	width := uint64(1) << (64 - level)
+	lo := uint64(node-1<<level) * width
+	return lo, lo + width - 1
+}
+
+func isLeaf(node int) bool {
+	return node >= 1<<merkleDepth
+}
+
+// sets the entry of a key
+func (t *merkleTree) update(p Pair) {
+	hash := hashKey(p.key)
+	leaf := leafOf(hash)
+
+	d := entryDigest(p)
+	if old, ok := t.leaves[leaf][p.key]; ok {
+		t.xor(leaf, old.digest)
+	}
+	t.leaves[leaf][p.key] = leafEntry{hash: hash, digest: d}
+	t.xor(leaf, d)
+}
+
+// removes the entry of a key
+func (t *merkleTree) remove(key string) {
+	leaf := leafOf(hashKey(key))
+	if old, ok := t.leaves[leaf][key]; ok {
+		delete(t.leaves[leaf], key)
+		t.xor(leaf, old.digest)
+	}
+}
+
+// folds d into a leaf and recomputes the nodes above it
+func (t *merkleTree) xor(leaf int, d digest) {
+	node := 1<<merkleDepth + leaf
+	for i := range d {
+		t.nodes[node][i] ^= d[i]
+	}
+	for node > 1 {
+		node /= 2
+		t.nodes[node] = combine(t.nodes[2*node], t.nodes[2*node+1])
+	}
+}
+
+func combine(left, right digest) digest {
+	var d digest
+	if left == d && right == d {
+		return d
+	}
+	h := sha256.New()
+	h.Write(left[:])
+	h.Write(right[:])
+	h.Sum(d[:0])
+	return d
+}
+
+// returns the digest of a node counting only the keys in r
+func (t *merkleTree) digest(node int, r keyRange) digest {
+	lo, hi := bounds(node)
+	all, any := r.overlap(lo, hi)
+	if all {
+		return t.nodes[node]
+	}
+	if !any {
+		return digest{}
+	}
+
+	if isLeaf(node) {
+		var d digest
+		for _, e := range t.leaves[node-1<<merkleDepth] {
+			if r.contains(e.hash) {
+				for i := range d {
+					d[i] ^= e.digest[i]
+				}
+			}
+		}
+		return d
+	}
+
+	return combine(t.digest(2*node, r), t.digest(2*node+1, r))
+}
+
+// returns the keys under a leaf that are in r
+func (t *merkleTree) keys(node int, r keyRange) []string {
+	keys := make([]string, 0)
+	for key, e := range t.leaves[node-1<<merkleDepth] {
+		if r.contains(e.hash) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// returns up to n of the keys that match from position cursor of the ring
+// onwards, in ring order. The keys at the position of the last key are all
+// returned, even past n, so that a page never ends between them.
+func (t *merkleTree) scan(cursor uint64, n int, match func(key string) bool) []string {
+	type position struct {
+		key  string
+		hash uint64
+	}
+
+	found := make([]position, 0, n)
+	for leaf := leafOf(cursor); leaf < len(t.leaves) && len(found) < n; leaf++ {
+		start := len(found)
+		for key, e := range t.leaves[leaf] {
+			if e.hash >= cursor && match(key) {
+				found = append(found, position{key: key, hash: e.hash})
+			}
+		}
+
+		page := found[start:]
+		sort.Slice(page, func(i, j int) bool {
+			if page[i].hash != page[j].hash {
+				return page[i].hash < page[j].hash
+			}
+			return page[i].key < page[j].key
+		})
+	}
+
+	end := min(n, len(found))
+	for end > 0 && end < len(found) && found[end].hash == found[end-1].hash {
+		end++
+	}
+
+	keys := make([]string, 0, end)
+	for _, p := range found[:end] {
+		keys = append(keys, p.key)
+	}
+	return keys
+}
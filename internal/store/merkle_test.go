@@ -0,0 +1,288 @@
+package store
+
+import (
+	"context"
+	"math"
+	"net"
+	"slices"
+	"testing"
+
+	pb "github.com/priyansh32/nebula/internal/api/store"
+	"google.golang.org/grpc"
+)
+
+func TestKeyRange(t *testing.T) {
+	tests := []struct {
+		name         string
+		r            keyRange
+		hash         uint64
+		contains     bool
+		lo, hi       uint64
+		all, overlap bool
+	}{
+		{
+			name: "inside",
+			r:    keyRange{start: 10, end: 20}, hash: 15, contains: true,
+			lo: 11, hi: 20, all: true, overlap: true,
+		},
+		{
+			name: "start is excluded",
+			r:    keyRange{start: 10, end: 20}, hash: 10,
+			lo: 10, hi: 20, overlap: true,
+		},
+		{
+			name: "end is included",
+			r:    keyRange{start: 10, end: 20}, hash: 20, contains: true,
+			lo: 20, hi: 30, overlap: true,
+		},
+		{
+			name: "outside",
+			r:    keyRange{start: 10, end: 20}, hash: 30,
+			lo: 21, hi: 30,
+		},
+		{
+			name: "wrapping past the end of the ring",
+			r:    keyRange{start: math.MaxUint64 - 10, end: 10}, hash: math.MaxUint64, contains: true,
+			lo: math.MaxUint64 - 5, hi: math.MaxUint64, all: true, overlap: true,
+		},
+		{
+			name: "wrapping from the start of the ring",
+			r:    keyRange{start: math.MaxUint64 - 10, end: 10}, hash: 0, contains: true,
+			lo: 0, hi: 20, overlap: true,
+		},
+		{
+			name: "outside a wrapping range",
+			r:    keyRange{start: math.MaxUint64 - 10, end: 10}, hash: 11,
+			lo: 11, hi: math.MaxUint64 - 10,
+		},
+		{
+			name: "whole ring",
+			r:    keyRange{start: 5, end: 5}, hash: 5, contains: true,
+			lo: 0, hi: math.MaxUint64, all: true, overlap: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.contains(tt.hash); got != tt.contains {
+				t.Errorf("contains(%d) = %v, want %v", tt.hash, got, tt.contains)
+			}
+			all, overlap := tt.r.overlap(tt.lo, tt.hi)
+			if all != tt.all || overlap != tt.overlap {
+				t.Errorf("overlap(%d, %d) = %v, %v, want %v, %v", tt.lo, tt.hi, all, overlap, tt.all, tt.overlap)
+			}
+		})
+	}
+}
+
+// returns the leaves whose digests over r differ between a and b, found
+// from the root down like replicas do when they sync
+func diffLeaves(a, b *merkleTree, r keyRange) []int {
+	leaves := make([]int, 0)
+	nodes := []int{1}
+	for len(nodes) > 0 {
+		next := make([]int, 0)
+		for _, n := range nodes {
+			if a.digest(n, r) == b.digest(n, r) {
+				continue
+			}
+			if isLeaf(n) {
+				leaves = append(leaves, n)
+			} else {
+				next = append(next, 2*n, 2*n+1)
+			}
+		}
+		nodes = next
+	}
+	slices.Sort(leaves)
+	return leaves
+}
+
+// returns the leaf node holding key
+func leafNode(key string) int {
+	return 1<<merkleDepth + leafOf(hashKey(key))
+}
+
+func TestMerkleDiff(t *testing.T) {
+	whole := keyRange{}
+	pairs := []Pair{
+		{key: "a", value: "1", version: 1},
+		{key: "b", value: "2", version: 1},
+		{key: "c", value: "3", version: 1},
+	}
+
+	tests := []struct {
+		name   string
+		local  func(t *merkleTree)
+		remote func(t *merkleTree)
+		r      keyRange
+		want   []int
+	}{
+		{
+			name: "same keys written in another order",
+			local: func(t *merkleTree) {
+				for _, p := range pairs {
+					t.update(p)
+				}
+			},
+			remote: func(t *merkleTree) {
+				for i := len(pairs) - 1; i >= 0; i-- {
+					t.update(pairs[i])
+				}
+			},
+			r:    whole,
+			want: []int{},
+		},
+		{
+			name:   "missing key",
+			local:  func(t *merkleTree) { t.update(pairs[0]); t.update(pairs[1]) },
+			remote: func(t *merkleTree) { t.update(pairs[0]) },
+			r:      whole,
+			want:   []int{leafNode("b")},
+		},
+		{
+			name:   "older version",
+			local:  func(t *merkleTree) { t.update(pairs[0]) },
+			remote: func(t *merkleTree) { t.update(Pair{key: "a", value: "1", version: 0}) },
+			r:      whole,
+			want:   []int{leafNode("a")},
+		},
+		{
+			name:   "tombstone",
+			local:  func(t *merkleTree) { t.update(pairs[0]) },
+			remote: func(t *merkleTree) { t.update(Pair{key: "a", value: "1", version: 1, deleted: true}) },
+			r:      whole,
+			want:   []int{leafNode("a")},
+		},
+		{
+			name:  "overwritten then restored",
+			local: func(t *merkleTree) { t.update(pairs[0]) },
+			remote: func(t *merkleTree) {
+				t.update(Pair{key: "a", value: "x", version: 2})
+				t.update(pairs[0])
+			},
+			r:    whole,
+			want: []int{},
+		},
+		{
+			name:  "removed on one side",
+			local: func(t *merkleTree) { t.update(pairs[0]) },
+			remote: func(t *merkleTree) {
+				t.update(pairs[0])
+				t.update(pairs[1])
+				t.remove("b")
+			},
+			r:    whole,
+			want: []int{},
+		},
+		{
+			name:   "difference outside the range",
+			local:  func(t *merkleTree) { t.update(pairs[0]); t.update(pairs[1]) },
+			remote: func(t *merkleTree) { t.update(pairs[0]) },
+			r:      keyRange{start: hashKey("b"), end: hashKey("b") - 1},
+			want:   []int{},
+		},
+		{
+			name:   "difference inside the range",
+			local:  func(t *merkleTree) { t.update(pairs[0]); t.update(pairs[1]) },
+			remote: func(t *merkleTree) { t.update(pairs[0]) },
+			r:      keyRange{start: hashKey("b") - 1, end: hashKey("b")},
+			want:   []int{leafNode("b")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, remote := newMerkleTree(), newMerkleTree()
+			tt.local(local)
+			tt.remote(remote)
+
+			if got := diffLeaves(local, remote, tt.r); !slices.Equal(got, tt.want) {
+				t.Errorf("leaves that differ = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// serves s and returns its address
+func serveStore(t *testing.T, s *store) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterKeyValueStoreServer(server, s)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	return lis.Addr().String()
+}
+
+func TestSyncRanges(t *testing.T) {
+	local, remote := NewStore(16), NewStore(16)
+	t.Cleanup(func() { local.closePeers(func(string, *peerConn) bool { return true }) })
+
+	local.cache.PutVersion("both", "v", 1, 0)
+	local.cache.PutVersion("newer locally", "new", 2, 0)
+	local.cache.PutVersion("newer remotely", "old", 1, 0)
+	local.cache.PutVersion("only local", "v", 1, 0)
+	local.cache.RemoveVersion("deleted locally", 2)
+
+	remote.cache.PutVersion("both", "v", 1, 0)
+	remote.cache.PutVersion("newer locally", "old", 1, 0)
+	remote.cache.PutVersion("newer remotely", "new", 2, 0)
+	remote.cache.PutVersion("only remote", "v", 1, 0)
+	remote.cache.PutVersion("deleted locally", "v", 1, 0)
+
+	address := serveStore(t, remote)
+	if _, err := local.AssignRanges(context.Background(), &pb.AssignRangesRequest{Peers: []string{address}}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	res, err := local.SyncRanges(ctx, &pb.SyncRangesRequest{Peer: address, Ranges: []*pb.KeyRange{{}}})
+	if err != nil {
+		t.Fatalf("SyncRanges() = %v", err)
+	}
+	if res.Pulled != 2 || res.Pushed != 3 {
+		t.Errorf("SyncRanges() pulled %d and pushed %d keys, want 2 and 3", res.Pulled, res.Pushed)
+	}
+
+	if diff := diffLeaves(local.cache.tree, remote.cache.tree, keyRange{}); len(diff) > 0 {
+		t.Errorf("the trees still differ under leaves %v", diff)
+	}
+
+	tests := []struct {
+		key     string
+		value   string
+		deleted bool
+	}{
+		{key: "both", value: "v"},
+		{key: "newer locally", value: "new"},
+		{key: "newer remotely", value: "new"},
+		{key: "only local", value: "v"},
+		{key: "only remote", value: "v"},
+		{key: "deleted locally", deleted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			for name, s := range map[string]*store{"local": local, "remote": remote} {
+				p, ok := s.cache.peek(tt.key)
+				if !ok || p.deleted != tt.deleted || (!tt.deleted && p.value != tt.value) {
+					t.Errorf("the %s store holds %+v, want %q deleted %v", name, p, tt.value, tt.deleted)
+				}
+			}
+		})
+	}
+
+	// a second sync finds nothing to exchange
+	res, err = local.SyncRanges(ctx, &pb.SyncRangesRequest{Peer: address, Ranges: []*pb.KeyRange{{}}})
+	if err != nil {
+		t.Fatalf("SyncRanges() = %v", err)
+	}
+	if res.Pulled != 0 || res.Pushed != 0 {
+		t.Errorf("the second SyncRanges() pulled %d and pushed %d keys, want none", res.Pulled, res.Pushed)
+	}
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// registers the cache and request counters of the store with reg, they are
+// read when scraped
+func (s *store) registerMetrics(reg prometheus.Registerer) {
+	cache := func(read func(*LRUCache) float64) func() float64 {
+		return func() float64 {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return read(s.cache)
+		}
+	}
+
+	opts := func(name string, help string) prometheus.GaugeOpts {
+		return prometheus.GaugeOpts{Namespace: "nebula", Subsystem: "store", Name: name, Help: help}
+	}
+	counter := func(name string, help string) prometheus.CounterOpts {
+		return prometheus.CounterOpts(opts(name, help))
+	}
+
+	reg.MustRegister(
+		prometheus.NewGaugeFunc(opts("entries", "Keys held, without tombstones."), cache(func(c *LRUCache) float64 {
+			return float64(uint32(len(c.cache)) - c.tombstones)
+		})),
+		prometheus.NewGaugeFunc(opts("tombstones", "Deleted keys remembered for their version."), cache(func(c *LRUCache) float64 {
+			return float64(c.tombstones)
+		})),
+		prometheus.NewGaugeFunc(opts("memory_bytes", "Approximate memory held by the cache."), cache(func(c *LRUCache) float64 {
+			return float64(c.bytes)
+		})),
+		prometheus.NewGaugeFunc(opts("capacity", "Maximum number of keys held."), cache(func(c *LRUCache) float64 {
+			return float64(c.capacity)
+		})),
+		prometheus.NewCounterFunc(counter("evictions_total", "Keys evicted to make room for others."), cache(func(c *LRUCache) float64 {
+			return float64(c.evictions)
+		})),
+		prometheus.NewCounterFunc(counter("cache_hits_total", "Reads that found their key."), func() float64 {
+			return float64(s.stats.hits.Load())
+		}),
+		prometheus.NewCounterFunc(counter("cache_misses_total", "Reads that did not find their key."), func() float64 {
+			return float64(s.stats.misses.Load())
+		}),
+	)
+}
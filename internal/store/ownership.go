@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+
+	pb "github.com/priyansh32/nebula/internal/api/store"
+	"github.com/priyansh32/nebula/internal/auth"
+	"github.com/priyansh32/nebula/internal/logging"
+)
+
+// AssignRanges tells the store which ranges of the ring it holds keys for
+// requests routed by clients are rejected for keys outside of them
+func (s *store) AssignRanges(ctx context.Context, in *pb.AssignRangesRequest) (*pb.AssignRangesResponse, error) {
+	ranges := make([]keyRange, 0, len(in.Ranges))
+	for _, r := range in.Ranges {
+		ranges = append(ranges, rangeOf(r))
+	}
+
+	members := make(map[string]bool, len(in.Peers))
+	for _, address := range in.Peers {
+		members[address] = true
+	}
+
+	s.rangesMu.Lock()
+	changed := s.ringVersion != in.RingVersion
+	s.ranges = ranges
+	s.ringVersion = in.RingVersion
+	s.members = members
+	s.rangesMu.Unlock()
+
+	s.closePeers(func(address string, _ *peerConn) bool { return !members[address] })
+
+	if changed {
+		logging.Logger("ring").InfoContext(ctx, "assigned ranges", "ranges", len(ranges), "ring_version", in.RingVersion)
+	}
+
+	return &pb.AssignRangesResponse{Status: pb.StatusType_OK}, nil
+}
+
+// reports whether a request for key may be served. Requests without a ring
+// version come from coordinators and other stores, which may place keys
+// on any store, for instance while a replica is down. When requests are
+// authenticated they need cluster access, so that clients cannot skip the
+// check by leaving the ring version out. Requests routed by clients are
+// refused until the coordinator assigned the store its ranges.
+func (s *store) owns(ctx context.Context, key string, ringVersion uint64) bool {
+	if ringVersion == 0 {
+		return s.authorizer == nil || s.authorizer.Allows(ctx, auth.Cluster, key)
+	}
+
+	s.rangesMu.Lock()
+	defer s.rangesMu.Unlock()
+
+	if s.ranges == nil {
+		// no ranges were assigned yet, the client goes through a coordinator
+		return false
+	}
+
+	hash := hashKey(key)
+	for _, r := range s.ranges {
+		if r.contains(hash) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/priyansh32/nebula/internal/api/store"
+)
+
+func TestOwns(t *testing.T) {
+	hash := hashKey("key")
+
+	tests := []struct {
+		name        string
+		ranges      []*pb.KeyRange
+		ringVersion uint64
+		want        bool
+	}{
+		{
+			name: "coordinator before ranges were assigned",
+			want: true,
+		},
+		{
+			name:        "client before ranges were assigned",
+			ringVersion: 1,
+		},
+		{
+			name:   "coordinator outside the ranges",
+			ranges: []*pb.KeyRange{{Start: hash, End: hash + 1}},
+			want:   true,
+		},
+		{
+			name:        "client inside the ranges",
+			ranges:      []*pb.KeyRange{{Start: hash + 1, End: hash + 2}, {Start: hash - 1, End: hash}},
+			ringVersion: 1,
+			want:        true,
+		},
+		{
+			name:        "client outside the ranges",
+			ranges:      []*pb.KeyRange{{Start: hash, End: hash + 1}},
+			ringVersion: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore(16)
+			if tt.ranges != nil {
+				if _, err := s.AssignRanges(context.Background(), &pb.AssignRangesRequest{RingVersion: 1, Ranges: tt.ranges}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := s.owns(context.Background(), "key", tt.ringVersion); got != tt.want {
+				t.Errorf("owns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
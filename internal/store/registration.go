@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	pb_coordinator "github.com/priyansh32/nebula/internal/api/coordinator"
+	"github.com/priyansh32/nebula/internal/auth"
+	"github.com/priyansh32/nebula/internal/logging"
+	"github.com/priyansh32/nebula/internal/tlsconfig"
+	"github.com/priyansh32/nebula/internal/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const registerRetryInterval = time.Second
+
+// Registration tells a store which coordinator to join and under which name
+// Address is the address the coordinator should use to reach this store
+type Registration struct {
+	Coordinator string
+	Name        string
+	Address     string
+
+	// Token is the bearer token the store authenticates with, if the
+	// coordinator authenticates requests
+	Token string
+}
+
+type registrar struct {
+	reg    Registration
+	conn   *grpc.ClientConn
+	client pb_coordinator.CoordinatorAPIClient
+}
+
+func newRegistrar(reg Registration, creds *tlsconfig.Credentials) (*registrar, error) {
+	conn, err := grpc.Dial(reg.Coordinator, creds.DialOption(), auth.TokenDialOption(reg.Token), tracing.DialOption(), logging.DialOption())
+	if err != nil {
+		return nil, err
+	}
+
+	return &registrar{
+		reg:    reg,
+		conn:   conn,
+		client: pb_coordinator.NewCoordinatorAPIClient(conn),
+	}, nil
+}
+
+// registers the store and keeps its lease alive until ctx is done
+func (r *registrar) run(ctx context.Context) {
+	ttl := r.register(ctx)
+
+	for ctx.Err() == nil {
+		// heartbeat well within the lease so that a single lost heartbeat is harmless
+		interval := ttl / 3
+		if interval <= 0 {
+			interval = registerRetryInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		_, err := r.client.Heartbeat(ctx, &pb_coordinator.HeartbeatRequest{Name: r.reg.Name})
+		if status.Code(err) == codes.NotFound {
+			// the coordinator restarted or expired our lease
+			ttl = r.register(ctx)
+		} else if err != nil {
+			logging.Logger("registration").Warn("heartbeat to the coordinator failed", "coordinator", r.reg.Coordinator, "error", err)
+		}
+	}
+}
+
+// registers the store, retrying until it succeeds or ctx is done
+// returns the lease ttl granted by the coordinator
+func (r *registrar) register(ctx context.Context) time.Duration {
+	for {
+		res, err := r.client.RegisterStore(ctx, &pb_coordinator.RegisterStoreRequest{
+			Name:    r.reg.Name,
+			Address: r.reg.Address,
+		})
+		if err == nil {
+			logging.Logger("registration").Info("registered with the coordinator", "name", r.reg.Name, "coordinator", r.reg.Coordinator)
+			return time.Duration(res.LeaseTtlMs) * time.Millisecond
+		}
+
+		logging.Logger("registration").Error("failed to register with the coordinator", "coordinator", r.reg.Coordinator, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-time.After(registerRetryInterval):
+		}
+	}
+}
+
+// removes the store from the ring so that the coordinator stops routing to it
+func (r *registrar) deregister(timeout time.Duration) {
+	defer r.conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := r.client.DeregisterStore(ctx, &pb_coordinator.DeregisterStoreRequest{Name: r.reg.Name})
+	if err != nil {
+		logging.Logger("registration").Error("failed to deregister from the coordinator", "coordinator", r.reg.Coordinator, "error", err)
+		return
+	}
+
+	logging.Logger("registration").Info("deregistered from the coordinator", "name", r.reg.Name, "coordinator", r.reg.Coordinator)
+}
@@ -0,0 +1,31 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/priyansh32/nebula/internal/api/store"
+)
+
+// number of keys a scan returns when the request does not say
+const defaultScanCount = 10
+
+// Scan returns the keys of the store from position in.Cursor of the ring
+// onwards in ring order, skipping deleted and expired keys. It returns up
+// to in.Count keys, more if several keys share the position of the last one.
+func (s *store) Scan(ctx context.Context, in *pb.ScanRequest) (*pb.ScanResponse, error) {
+	count := int(in.Count)
+	if count <= 0 {
+		count = defaultScanCount
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	keys := s.cache.tree.scan(in.Cursor, count, func(key string) bool {
+		p, ok := s.cache.peek(key)
+		return ok && !p.deleted && !p.expired(now)
+	})
+	s.mu.Unlock()
+
+	return &pb.ScanResponse{Keys: keys}, nil
+}
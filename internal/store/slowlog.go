@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+
+	pb "github.com/priyansh32/nebula/internal/api/store"
+)
+
+// SlowLog returns the latest slow requests served by the store and the
+// latency of every command, and drops them if asked to
+func (s *store) SlowLog(ctx context.Context, in *pb.SlowLogRequest) (*pb.SlowLogResponse, error) {
+	res := &pb.SlowLogResponse{}
+
+	for _, e := range s.slowlog.Entries(int(in.Count)) {
+		entry := &pb.SlowLogEntry{
+			Id:          e.ID,
+			TimestampMs: e.Time.UnixMilli(),
+			Command:     e.Command,
+			Key:         e.Key,
+			RequestId:   e.RequestID,
+			DurationUs:  e.Duration.Microseconds(),
+			Stores:      e.Stores(),
+		}
+		for _, p := range e.Phases {
+			entry.Phases = append(entry.Phases, &pb.SlowLogPhase{
+				Name:       p.Name,
+				Store:      p.Store,
+				DurationUs: p.Duration.Microseconds(),
+			})
+		}
+		res.Entries = append(res.Entries, entry)
+	}
+
+	for _, l := range s.slowlog.Latencies() {
+		res.Latencies = append(res.Latencies, &pb.CommandLatency{
+			Command: l.Command,
+			Calls:   l.Calls,
+			Slow:    l.Slow,
+			TotalUs: l.Total.Microseconds(),
+			MaxUs:   l.Max.Microseconds(),
+		})
+	}
+
+	if in.Clear {
+		s.slowlog.Reset()
+	}
+
+	return res, nil
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/priyansh32/nebula/internal/api/store"
+)
+
+// number of complete seconds the request rate is averaged over
+const rateWindow = 10
+
+// counters of the requests served by a store
+type stats struct {
+	started time.Time
+
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	puts    atomic.Uint64
+	deletes atomic.Uint64
+
+	ops rate
+}
+
+// rate counts events in one second buckets over the last rateWindow
+// seconds, and one more for the current second
+type rate struct {
+	mu      sync.Mutex
+	buckets [rateWindow + 1]uint64
+	seconds [rateWindow + 1]int64
+}
+
+func (r *rate) record(now time.Time) {
+	second := now.Unix()
+	i := second % (rateWindow + 1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.seconds[i] != second {
+		r.seconds[i] = second
+		r.buckets[i] = 0
+	}
+	r.buckets[i]++
+}
+
+// returns the events per second over the last complete rateWindow seconds
+func (r *rate) perSecond(now time.Time) float64 {
+	second := now.Unix()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var total uint64
+	for i := range r.buckets {
+		if age := second - r.seconds[i]; age >= 1 && age <= rateWindow {
+			total += r.buckets[i]
+		}
+	}
+	return float64(total) / rateWindow
+}
+
+// Stats reports the size of the cache and the requests the store served
+func (s *store) Stats(ctx context.Context, in *pb.StatsRequest) (*pb.StatsResponse, error) {
+	now := time.Now()
+
+	// expired values are kept like tombstones until they are overwritten
+	s.mu.Lock()
+	s.cache.reap(now)
+	gone := uint64(s.cache.tombstones) + uint64(s.cache.expired)
+	res := &pb.StatsResponse{
+		Entries:     uint64(len(s.cache.cache)) - gone,
+		Tombstones:  gone,
+		MemoryBytes: s.cache.bytes,
+		Capacity:    uint64(s.cache.capacity),
+		Evictions:   s.cache.evictions,
+		Expirations: s.cache.expirations,
+	}
+	s.mu.Unlock()
+
+	res.Hits = s.stats.hits.Load()
+	res.Misses = s.stats.misses.Load()
+	res.Puts = s.stats.puts.Load()
+	res.Deletes = s.stats.deletes.Load()
+	res.OpsPerSec = s.stats.ops.perSecond(now)
+	res.UptimeMs = now.Sub(s.stats.started).Milliseconds()
+
+	return res, nil
+}
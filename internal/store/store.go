@@ -3,67 +3,337 @@ package store
 import (
 	"context"
 	"log"
+	"log/slog"
 	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	pb "github.com/priyansh32/nebula/internal/api/store"
+	"github.com/priyansh32/nebula/internal/auth"
+	"github.com/priyansh32/nebula/internal/gossip"
+	"github.com/priyansh32/nebula/internal/logging"
+	"github.com/priyansh32/nebula/internal/metrics"
+	"github.com/priyansh32/nebula/internal/slowlog"
+	"github.com/priyansh32/nebula/internal/tlsconfig"
+	"github.com/priyansh32/nebula/internal/tracing"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 const DEFAULT_CAPACITY uint32 = 1024 * 1024
 
 type store struct {
+	// guards cache, versioned writes compare and set
+	mu    sync.Mutex
 	cache *LRUCache
+
+	// ranges of the ring the store holds keys for, and the addresses of
+	// the stores of the ring
+	rangesMu    sync.Mutex
+	ranges      []keyRange
+	ringVersion uint64
+	members     map[string]bool
+
+	// connections to other stores for anti-entropy
+	peersMu sync.Mutex
+	peers   map[string]*peerConn
+
+	stats stats
+
+	// logs every request, sampled
+	requests *slog.Logger
+
+	slowlog *slowlog.Log
+
+	// estimates the accesses of keys to find the hot ones
+	hotKeys *hotKeys
+
+	// certificates other stores are dialed with, insecure if nil
+	creds *tlsconfig.Credentials
+
+	// checks who may send requests without a ring version, every caller
+	// may if nil
+	authorizer *auth.Authorizer
+
+	// bearer token sent to other stores, if they authenticate requests
+	authToken string
+
 	pb.UnimplementedKeyValueStoreServer
 }
 
 func NewStore(capacity uint32) *store {
+	slow, _ := slowlog.New(slowlog.DefaultConfig())
+	hot, _ := newHotKeys(DefaultHotKeysConfig())
+
 	return &store{
-		cache: LRUConstructor(capacity),
+		cache:    LRUConstructor(capacity),
+		peers:    make(map[string]*peerConn),
+		stats:    stats{started: time.Now()},
+		requests: logging.Hot("requests"),
+		slowlog:  slow,
+		hotKeys:  hot,
 	}
 }
 
 func (s *store) Get(ctx context.Context, in *pb.GetRequest) (*pb.GetResponse, error) {
 
+	if !s.owns(ctx, in.Key, in.RingVersion) {
+		return &pb.GetResponse{Status: pb.StatusType_WRONG_OWNER}, nil
+	}
+
+	now := time.Now()
+	s.stats.ops.record(now)
+	s.hotKeys.record(in.Key, now)
+
 	// get the value from the cache
-	value, err := s.cache.Get(in.Key)
+	s.lock(ctx)
+	done := slowlog.Track(ctx, "cache lookup", "")
+	pair, ok := s.cache.Lookup(in.Key)
+	done()
+	s.mu.Unlock()
 
-	if err != nil {
-		log.Printf("Cache miss for key: %s\n", in.Key)
-		return &pb.GetResponse{Status: pb.StatusType_CACHE_MISS, Value: value}, nil
+	if !ok || pair.deleted || pair.expired(now) {
+		// the version of a tombstone or of an expired value tells the
+		// coordinator the key is gone
+		s.stats.misses.Add(1)
+		s.requests.DebugContext(ctx, "cache miss", logging.Key("key", in.Key))
+		return &pb.GetResponse{Status: pb.StatusType_CACHE_MISS, Version: pair.version}, nil
 	}
 
-	log.Printf("Cache hit for key: %s, value: %s\n", in.Key, value)
-	return &pb.GetResponse{Status: pb.StatusType_OK, Value: value}, nil
+	s.stats.hits.Add(1)
+	s.requests.DebugContext(ctx, "cache hit", logging.Key("key", in.Key), logging.Value("value", pair.value))
+	return &pb.GetResponse{Status: pb.StatusType_OK, Value: pair.value, Version: pair.version, ExpiresAtMs: pair.expires}, nil
 }
 
 func (s *store) Put(ctx context.Context, in *pb.PutRequest) (*pb.PutResponse, error) {
 
-	// put the value in the cache
-	s.cache.Put(in.Key, in.Value)
-	log.Printf("Cached key: %s, value: %s\n", in.Key, in.Value)
+	// writes come from coordinators and other stores, clients write
+	// through a coordinator
+	if !s.owns(ctx, in.Key, 0) {
+		return &pb.PutResponse{Status: pb.StatusType_WRONG_OWNER}, nil
+	}
+
+	now := time.Now()
+	s.stats.ops.record(now)
+	s.stats.puts.Add(1)
+	s.hotKeys.record(in.Key, now)
+
+	// put the value in the cache, unless a newer version is already there
+	s.lock(ctx)
+	done := slowlog.Track(ctx, "cache write", "")
+	applied := s.cache.PutVersion(in.Key, in.Value, in.Version, in.ExpiresAtMs)
+	current, _ := s.cache.peek(in.Key)
+	done()
+	s.mu.Unlock()
+
+	if !applied {
+		// tell the writer which version won so that it can catch up its clock
+		s.requests.DebugContext(ctx, "ignored stale write", logging.Key("key", in.Key), "version", in.Version, "current", current.version)
+		return &pb.PutResponse{Status: pb.StatusType_OK, Version: current.version}, nil
+	}
+
+	s.requests.DebugContext(ctx, "cached key", logging.Key("key", in.Key), logging.Value("value", in.Value), "version", in.Version)
 	return &pb.PutResponse{Status: pb.StatusType_OK}, nil
 }
 
 func (s *store) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.DeleteResponse, error) {
 
-	// delete the value from the cache
-	s.cache.Remove(in.Key)
-	log.Printf("Deleted key: %s\n", in.Key)
+	if !s.owns(ctx, in.Key, 0) {
+		return &pb.DeleteResponse{Status: pb.StatusType_WRONG_OWNER}, nil
+	}
+
+	now := time.Now()
+	s.stats.ops.record(now)
+	s.stats.deletes.Add(1)
+	s.hotKeys.record(in.Key, now)
+
+	// delete the value from the cache, unless a newer version is already there
+	s.lock(ctx)
+	done := slowlog.Track(ctx, "cache write", "")
+	applied := s.cache.RemoveVersion(in.Key, in.Version)
+	current, _ := s.cache.peek(in.Key)
+	done()
+	s.mu.Unlock()
+
+	if !applied {
+		// tell the writer which version won so that it can catch up its clock
+		s.requests.DebugContext(ctx, "ignored stale delete", logging.Key("key", in.Key), "version", in.Version, "current", current.version)
+		return &pb.DeleteResponse{Status: pb.StatusType_OK, Version: current.version}, nil
+	}
+
+	s.requests.DebugContext(ctx, "deleted key", logging.Key("key", in.Key), "version", in.Version)
 	return &pb.DeleteResponse{Status: pb.StatusType_OK}, nil
 }
 
-func InitStoreServer(address string, capacity uint32) {
+// locks the cache for a request, timing the wait in its slow log phases
+func (s *store) lock(ctx context.Context) {
+	done := slowlog.Track(ctx, "lock wait", "")
+	s.mu.Lock()
+	done()
+}
+
+// Config holds the optional ways a store announces itself to the cluster
+type Config struct {
+	// Registration, if set, makes the store register itself with a
+	// coordinator and deregister on shutdown
+	Registration *Registration
+
+	// Gossip, if set, makes the store join the gossip cluster with the
+	// ring positions in Gossip.Tokens. It needs a key when TLS or Auth is
+	// set.
+	Gossip *gossip.Config
+
+	// MetricsAddress, if set, is the HTTP address prometheus metrics are
+	// served on at /metrics
+	MetricsAddress string
+
+	// Tracing selects where the spans of the store are exported to
+	Tracing tracing.Config
+
+	// Logging controls the logs of the store
+	Logging logging.Config
+
+	// SlowLog selects the requests kept in the slow log, the default
+	// slow log is used if it is nil
+	SlowLog *slowlog.Config
+
+	// HotKeys controls how hot keys are found, DefaultHotKeysConfig is
+	// used if it is nil
+	HotKeys *HotKeysConfig
+
+	// TLS selects the certificates the store serves and dials the
+	// coordinator and other stores with, connections are insecure if unset
+	TLS tlsconfig.Config
+
+	// Auth controls who may send requests to the store
+	Auth AuthConfig
+}
+
+// InitStoreServer serves the store on address until the process is interrupted
+func InitStoreServer(address string, capacity uint32, cfg Config) {
+	if cfg.Logging.Component == "" {
+		cfg.Logging.Component = "store"
+	}
+	if err := logging.Setup(cfg.Logging); err != nil {
+		log.Fatalf("failed to set up logging: %s", err)
+	}
+
+	// anyone reaching the gossip address could otherwise change the ring
+	if cfg.Gossip != nil && cfg.Gossip.Key == "" && (cfg.TLS.Enabled() || cfg.Auth.RulesFile != "") {
+		log.Fatalf("a gossip key is required to gossip when TLS or auth rules are set")
+	}
+
 	lis, err := net.Listen("tcp", address)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	gRPCServer := grpc.NewServer()
-	pb.RegisterKeyValueStoreServer(gRPCServer, NewStore(capacity))
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = "nebula-store"
+	}
+	stopTracing, err := tracing.Start(cfg.Tracing)
+	if err != nil {
+		log.Fatalf("failed to start tracing: %s", err)
+	}
+
+	kvStore := NewStore(capacity)
+	if cfg.SlowLog != nil {
+		kvStore.slowlog, err = slowlog.New(*cfg.SlowLog)
+		if err != nil {
+			log.Fatalf("failed to create slow log: %s", err)
+		}
+	}
+	if cfg.HotKeys != nil {
+		kvStore.hotKeys, err = newHotKeys(*cfg.HotKeys)
+		if err != nil {
+			log.Fatalf("invalid hot keys config: %s", err)
+		}
+	}
+
+	kvStore.creds, err = tlsconfig.Load(cfg.TLS)
+	if err != nil {
+		log.Fatalf("failed to load TLS certificates: %s", err)
+	}
+	creds, err := kvStore.creds.ServerOption()
+	if err != nil {
+		log.Fatalf("failed to set up TLS: %s", err)
+	}
+
+	opts := []grpc.ServerOption{creds, tracing.ServerOption(), logging.ServerOption("requests")}
+	if cfg.MetricsAddress != "" {
+		// first so that requests that are denied or fail are counted too
+		reg := metrics.NewRegistry()
+		kvStore.registerMetrics(reg)
+		opts = append(opts, grpc.ChainUnaryInterceptor(metrics.NewServerMetrics(reg, "store").UnaryInterceptor()))
+		if err := metrics.Serve(cfg.MetricsAddress, reg); err != nil {
+			log.Fatalf("failed to serve metrics: %s", err)
+		}
+	}
+	if cfg.Auth.RulesFile != "" {
+		kvStore.authorizer, err = auth.Load(cfg.Auth.RulesFile)
+		if err != nil {
+			log.Fatalf("failed to load auth rules: %s", err)
+		}
+		opts = append(opts, kvStore.authorizer.ServerOption(methodAccess))
+	}
+	kvStore.authToken = cfg.Auth.Token
+	opts = append(opts, kvStore.slowlog.ServerOption())
+
+	gRPCServer := grpc.NewServer(opts...)
+	pb.RegisterKeyValueStoreServer(gRPCServer, kvStore)
+
+	// the coordinator probes this to detect failed stores
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(pb.KeyValueStore_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(gRPCServer, healthServer)
+
+	logging.Logger("store").Info("starting gRPC server", "address", address)
 
-	log.Printf("starting gRPC server on %s", address)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var r *registrar
+	if cfg.Registration != nil {
+		r, err = newRegistrar(*cfg.Registration, kvStore.creds)
+		if err != nil {
+			log.Fatalf("failed to connect to coordinator: %s", err)
+		}
+		go r.run(ctx)
+	}
+
+	var node *gossip.Node
+	if cfg.Gossip != nil {
+		node, err = gossip.Start(*cfg.Gossip)
+		if err != nil {
+			log.Fatalf("failed to join gossip cluster: %s", err)
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		logging.Logger("store").Info("shutting down")
+
+		healthServer.Shutdown()
+		if r != nil {
+			r.deregister(5 * time.Second)
+		}
+		if node != nil {
+			node.Leave(time.Second)
+			node.Shutdown()
+		}
+		gRPCServer.GracefulStop()
+	}()
 
 	if err := gRPCServer.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %s", err)
 	}
+
+	// flush the spans of the last requests
+	if err := stopTracing(context.Background()); err != nil {
+		logging.Logger("store").Error("failed to flush spans", "error", err)
+	}
 }
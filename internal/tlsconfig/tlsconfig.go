@@ -0,0 +1,251 @@
+// Package tlsconfig loads the TLS certificates nebula processes serve and
+// dial gRPC with, and reloads them when their files change so that they
+// can be rotated without a restart.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/priyansh32/nebula/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// files are checked for changes at most this often, on handshakes
+const reloadCheckInterval = time.Second
+
+// Config selects the certificates of a process, connections are insecure
+// if it has neither a certificate nor a CA
+type Config struct {
+	// CertFile and KeyFile hold the PEM certificate and key the process
+	// serves with, and presents to the servers it dials that verify clients
+	CertFile string
+	KeyFile  string
+
+	// CAFile holds the PEM certificates of the CAs the certificates of
+	// peers are verified with, the system CAs are used if it is empty
+	CAFile string
+
+	// ClientAuth makes servers require clients to present a certificate
+	// signed by one of the CAs of CAFile
+	ClientAuth bool
+
+	// ServerName is the name the certificates of servers are verified
+	// for, the host dialed if empty
+	ServerName string
+}
+
+// Enabled reports whether connections use TLS
+func (cfg Config) Enabled() bool {
+	return cfg.CertFile != "" || cfg.CAFile != ""
+}
+
+func (cfg Config) validate() error {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return errors.New("a TLS certificate needs both a certificate and a key file")
+	}
+	if cfg.ClientAuth && cfg.CAFile == "" {
+		return errors.New("verifying clients needs a CA file")
+	}
+	return nil
+}
+
+// Credentials are the certificates of a process, a nil *Credentials
+// serves and dials insecure connections
+type Credentials struct {
+	cfg Config
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  [3]time.Time
+	lastCheck time.Time
+}
+
+// Load reads the certificates of cfg, it returns nil credentials if TLS is
+// not enabled
+func Load(cfg Config) (*Credentials, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	c := &Credentials{cfg: cfg}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	c.lastCheck = time.Now()
+	return c, nil
+}
+
+// reads the files of the certificates, c.mu must be held or c not shared yet
+func (c *Credentials) load() error {
+	modTimes, err := c.fileTimes()
+	if err != nil {
+		return err
+	}
+
+	var cert *tls.Certificate
+	if c.cfg.CertFile != "" {
+		pair, err := tls.LoadX509KeyPair(c.cfg.CertFile, c.cfg.KeyFile)
+		if err != nil {
+			return err
+		}
+		cert = &pair
+	}
+
+	var pool *x509.CertPool
+	if c.cfg.CAFile != "" {
+		pem, err := os.ReadFile(c.cfg.CAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("no certificates found in " + c.cfg.CAFile)
+		}
+	}
+
+	c.cert, c.pool, c.modTimes = cert, pool, modTimes
+	return nil
+}
+
+// returns the modification times of the files of the certificates
+func (c *Credentials) fileTimes() ([3]time.Time, error) {
+	var times [3]time.Time
+	for i, path := range []string{c.cfg.CertFile, c.cfg.KeyFile, c.cfg.CAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return times, err
+		}
+		times[i] = info.ModTime()
+	}
+	return times, nil
+}
+
+// returns the current certificate and CAs, reloading them if their files
+// changed. The previous ones are kept if the new files cannot be loaded.
+func (c *Credentials) current() (*tls.Certificate, *x509.CertPool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastCheck) >= reloadCheckInterval {
+		c.lastCheck = now
+
+		modTimes, err := c.fileTimes()
+		if err == nil && modTimes != c.modTimes {
+			if err := c.load(); err != nil {
+				logging.Logger("tls").Error("failed to reload the TLS certificates, keeping the previous ones", "error", err)
+			} else {
+				logging.Logger("tls").Info("reloaded the TLS certificates")
+			}
+		}
+	}
+
+	return c.cert, c.pool
+}
+
+// ServerOption serves TLS with the certificate of the process, verifying
+// the certificates of clients if ClientAuth is set
+func (c *Credentials) ServerOption() (grpc.ServerOption, error) {
+	if c == nil {
+		return grpc.EmptyServerOption{}, nil
+	}
+	cfg, err := c.ServerConfig("h2")
+	if err != nil {
+		return nil, err
+	}
+	return grpc.Creds(credentials.NewTLS(cfg)), nil
+}
+
+// ServerConfig is the TLS configuration ServerOption serves with, for
+// servers other than gRPC ones, negotiating the given application protocols
+func (c *Credentials) ServerConfig(protos ...string) (*tls.Config, error) {
+	if c.cfg.CertFile == "" {
+		return nil, errors.New("serving TLS needs a certificate")
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := c.current()
+
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   protos,
+			}
+			if c.cfg.ClientAuth {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				cfg.ClientCAs = pool
+			}
+			return cfg, nil
+		},
+	}, nil
+}
+
+// DialOption dials TLS, verifying the certificates of servers with the
+// CAs and presenting the certificate of the process if it has one
+func (c *Credentials) DialOption() grpc.DialOption {
+	if c == nil {
+		return grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(c.ClientConfig()))
+}
+
+// ClientConfig is the TLS configuration DialOption dials with, for clients
+// other than gRPC ones
+func (c *Credentials) ClientConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.cfg.ServerName,
+
+		// the default verification cannot follow reloaded CAs, so the
+		// certificates of servers are verified in VerifyConnection instead
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			_, pool := c.current()
+			return verifyServer(state, pool)
+		},
+
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := c.current()
+			if cert == nil {
+				return &tls.Certificate{}, nil
+			}
+			return cert, nil
+		},
+	}
+}
+
+// verifies the certificate chain of a server for the name it was dialed
+// with, against pool or the system CAs if pool is nil
+func verifyServer(state tls.ConnectionState, pool *x509.CertPool) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("the server presented no certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       state.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err := state.PeerCertificates[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,296 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// a certificate and its key, signed by parent or self-signed if parent is nil
+type certificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func issue(t *testing.T, name string, parent *certificate) *certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &certificate{cert: cert, key: key, der: der}
+}
+
+// writes the certificate and its key as PEM files in dir, returning their paths
+func (c *certificate) write(t *testing.T, dir string, name string) (string, string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// serves the health service with creds and returns its address
+func serve(t *testing.T, creds *Credentials) string {
+	t.Helper()
+
+	opt, err := creds.ServerOption()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpc.NewServer(opt)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	return lis.Addr().String()
+}
+
+// sends a health check to address with creds
+func check(address string, creds *Credentials) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, address, creds.DialOption())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestHandshake(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := issue(t, "ca", nil)
+	caFile, _ := ca.write(t, dir, "ca")
+	otherCA := issue(t, "other-ca", nil)
+	otherCAFile, _ := otherCA.write(t, dir, "other-ca")
+
+	serverCert, serverKey := issue(t, "server", ca).write(t, dir, "server")
+	clientCert, clientKey := issue(t, "client", ca).write(t, dir, "client")
+	strangerCert, strangerKey := issue(t, "stranger", otherCA).write(t, dir, "stranger")
+
+	tests := []struct {
+		name   string
+		server Config
+		client Config
+		ok     bool
+	}{
+		{
+			name:   "tls",
+			server: Config{CertFile: serverCert, KeyFile: serverKey},
+			client: Config{CAFile: caFile},
+			ok:     true,
+		},
+		{
+			name:   "server signed by an unknown CA",
+			server: Config{CertFile: serverCert, KeyFile: serverKey},
+			client: Config{CAFile: otherCAFile},
+		},
+		{
+			name:   "server certificate for another name",
+			server: Config{CertFile: serverCert, KeyFile: serverKey},
+			client: Config{CAFile: caFile, ServerName: "elsewhere"},
+		},
+		{
+			name:   "mutual tls",
+			server: Config{CertFile: serverCert, KeyFile: serverKey, CAFile: caFile, ClientAuth: true},
+			client: Config{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile},
+			ok:     true,
+		},
+		{
+			name:   "mutual tls without a client certificate",
+			server: Config{CertFile: serverCert, KeyFile: serverKey, CAFile: caFile, ClientAuth: true},
+			client: Config{CAFile: caFile},
+		},
+		{
+			name:   "mutual tls with a client signed by an unknown CA",
+			server: Config{CertFile: serverCert, KeyFile: serverKey, CAFile: caFile, ClientAuth: true},
+			client: Config{CertFile: strangerCert, KeyFile: strangerKey, CAFile: caFile},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := Load(tt.server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := Load(tt.client)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = check(serve(t, server), client)
+			if (err == nil) != tt.ok {
+				t.Errorf("check() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// rotate replaces the files of the server and client credentials
+		// and returns whether requests succeed afterwards
+		rotate func(t *testing.T, dir string, ca *certificate) bool
+	}{
+		{
+			name: "new server certificate from the same CA",
+			rotate: func(t *testing.T, dir string, ca *certificate) bool {
+				issue(t, "server", ca).write(t, dir, "server")
+				return true
+			},
+		},
+		{
+			name: "new CA on both sides",
+			rotate: func(t *testing.T, dir string, _ *certificate) bool {
+				ca := issue(t, "new-ca", nil)
+				ca.write(t, dir, "ca")
+				issue(t, "server", ca).write(t, dir, "server")
+				issue(t, "client", ca).write(t, dir, "client")
+				return true
+			},
+		},
+		{
+			name: "server certificate from a CA the client does not know",
+			rotate: func(t *testing.T, dir string, _ *certificate) bool {
+				issue(t, "server", issue(t, "other-ca", nil)).write(t, dir, "server")
+				return false
+			},
+		},
+		{
+			name: "unreadable files keep the previous certificates",
+			rotate: func(t *testing.T, dir string, _ *certificate) bool {
+				if err := os.WriteFile(filepath.Join(dir, "server.pem"), []byte("not a certificate"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				return true
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			ca := issue(t, "ca", nil)
+			caFile, _ := ca.write(t, dir, "ca")
+			serverCert, serverKey := issue(t, "server", ca).write(t, dir, "server")
+			clientCert, clientKey := issue(t, "client", ca).write(t, dir, "client")
+
+			server, err := Load(Config{CertFile: serverCert, KeyFile: serverKey, CAFile: caFile, ClientAuth: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := Load(Config{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			address := serve(t, server)
+			if err := check(address, client); err != nil {
+				t.Fatalf("check() before rotating = %v", err)
+			}
+
+			want := tt.rotate(t, dir, ca)
+
+			// the files are written within the same second, mark them as
+			// changed and let the next handshakes look at them
+			later := time.Now().Add(time.Minute)
+			for _, path := range []string{caFile, serverCert, serverKey, clientCert, clientKey} {
+				if err := os.Chtimes(path, later, later); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, c := range []*Credentials{server, client} {
+				c.mu.Lock()
+				c.lastCheck = time.Time{}
+				c.mu.Unlock()
+			}
+
+			if err := check(address, client); (err == nil) != want {
+				t.Errorf("check() after rotating = %v, want ok %v", err, want)
+			}
+
+			// a client that only knows the rotated files sees whether the
+			// server picked them up
+			fresh, err := Load(Config{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := check(address, fresh); (err == nil) != want {
+				t.Errorf("check() with a new client = %v, want ok %v", err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Package tracing sets up opentelemetry tracing of nebula processes and
+// the propagation of trace context through their gRPC requests
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+)
+
+// Config selects where the spans of a process are exported to
+type Config struct {
+	// Exporter is "otlp", "stdout" or "file", tracing is disabled if empty
+	Exporter string
+
+	// Endpoint is the address of the OTLP gRPC collector for "otlp", the
+	// OTEL_EXPORTER_OTLP_* environment variables apply if it is empty,
+	// and the path spans are appended to for "file"
+	Endpoint string
+
+	// SampleRatio is the share of requests that start a trace, requests
+	// that are part of a sampled trace are always sampled
+	SampleRatio float64
+
+	// ServiceName names the process in the traces
+	ServiceName string
+}
+
+func DefaultConfig() Config {
+	return Config{SampleRatio: 1}
+}
+
+// Enabled reports whether spans are exported
+func (cfg Config) Enabled() bool {
+	return cfg.Exporter != ""
+}
+
+// Start installs the global tracer provider exporting spans as configured
+// and returns a function that flushes and stops it. Trace context is
+// propagated even if tracing is disabled.
+func Start(cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, errors.New("sample ratio must be between 0 and 1")
+	}
+
+	exporter, err := newExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(cfg Config) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "otlp":
+		opts := []otlptracegrpc.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint), otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(context.Background(), opts...)
+	case "stdout":
+		return stdouttrace.New()
+	case "file":
+		if cfg.Endpoint == "" {
+			return nil, errors.New("the file exporter needs a file to write spans to")
+		}
+		f, err := os.OpenFile(cfg.Endpoint, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(f))
+	}
+	return nil, errors.New("unknown trace exporter " + cfg.Exporter + ", use otlp, stdout or file")
+}
+
+// health checks are too frequent to be worth tracing
+var traced = otelgrpc.WithInterceptorFilter(filters.Not(filters.HealthCheck()))
+
+// ServerOption traces the requests served and continues the traces of their callers
+func ServerOption() grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(otelgrpc.UnaryServerInterceptor(traced))
+}
+
+// DialOption traces the requests sent and propagates their trace context
+func DialOption() grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(otelgrpc.UnaryClientInterceptor(traced))
+}